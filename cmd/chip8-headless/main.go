@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/Bit-Doctor/emulation/pkg/chip8"
+	"github.com/Bit-Doctor/emulation/pkg/chip8/netplay"
+	"github.com/Bit-Doctor/emulation/pkg/chip8/script"
+)
+
+func main() {
+	var names []string
+	for _, p := range chip8.Platforms {
+		names = append(names, p.Name)
+	}
+
+	platformName := flag.String("platform", chip8.PlatformOcto.Name, "emulated platform, one of: "+strings.Join(names, ", "))
+	cycles := flag.Int("cycles", 0, "instructions executed per frame, the default of the platform when not set")
+	seed := flag.Int64("seed", 0, "seed of the random numbers")
+	vipRandom := flag.Bool("vip-random", false, "generate the random numbers like the COSMAC VIP interpreter")
+	scriptFile := flag.String("script", "", "script of the keys pressed and of the screenshots, by frame")
+	frames := flag.Int("frames", 0, "number of frames to run, the last frame of the script or of the movie played when not set")
+	recordFile := flag.String("record", "", "record the keys pressed in a movie file")
+	hostAddr := flag.String("host", "", "host a netplay session on a UDP address like :7000, the configuration of the machine is sent to the other player")
+	joinAddr := flag.String("join", "", "join the netplay session hosted on a UDP address like 127.0.0.1:7000")
+	delay := flag.Int("delay", 2, "frames before the keys pressed are used in a hosted netplay session")
+	playFile := flag.String("play", "", "play the keys pressed and the configuration of a movie file, the keys of the script are ignored")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %v [options] <file>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	netplaying := *hostAddr != "" || *joinAddr != ""
+	if flag.NArg() != 1 || *recordFile != "" && *playFile != "" || *hostAddr != "" && *joinAddr != "" ||
+		netplaying && (*recordFile != "" || *playFile != "") {
+		flag.Usage()
+		os.Exit(-1)
+	}
+
+	platform, ok := chip8.PlatformByName(*platformName)
+	if !ok {
+		fmt.Fprintln(os.Stderr, "unknown platform: ", *platformName)
+		os.Exit(-1)
+	}
+
+	s := &script.Script{}
+	if *scriptFile != "" {
+		var err error
+		if s, err = script.Load(*scriptFile); err != nil {
+			fmt.Fprintln(os.Stderr, "cannot load the script: ", err)
+			os.Exit(-1)
+		}
+	}
+
+	data, err := ioutil.ReadFile(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "cannot", err)
+		os.Exit(-1)
+	}
+
+	var vm *chip8.Chip8
+	var machine script.Machine
+	var movie *chip8.Movie
+	var session *netplay.Session
+	if netplaying {
+		config := netplay.Config{Platform: platform, Seed: *seed, VIPRandom: *vipRandom, Cycles: *cycles, Delay: *delay}
+		if *hostAddr != "" {
+			fmt.Fprintln(os.Stderr, "waiting for the other player on", *hostAddr)
+		}
+		if session, err = netplay.Connect(*hostAddr, *joinAddr, data, config); err != nil {
+			fmt.Fprintln(os.Stderr, "cannot start netplay: ", err)
+			os.Exit(-1)
+		}
+		vm, machine = session.Machine(), session
+	} else if *playFile != "" {
+		if movie, err = chip8.LoadMovie(*playFile); err != nil {
+			fmt.Fprintln(os.Stderr, "cannot load the movie: ", err)
+			os.Exit(-1)
+		}
+		if vm, err = movie.Machine(data); err != nil {
+			fmt.Fprintln(os.Stderr, "cannot play the movie: ", err)
+			os.Exit(-1)
+		}
+		machine = playback{chip8.NewPlayer(vm, movie)}
+		if *frames == 0 {
+			*frames = len(movie.Frames)
+		}
+	} else {
+		movie = chip8.NewMovie(data, platform, *seed, *vipRandom)
+		if vm, err = movie.Machine(data); err != nil {
+			fmt.Fprintln(os.Stderr, "cannot load game data: ", err)
+			os.Exit(-1)
+		}
+		if *cycles > 0 {
+			vm.SetCyclesPerFrame(*cycles)
+		}
+		machine = vm
+		if *recordFile != "" {
+			machine = chip8.NewRecorder(vm, movie)
+		}
+	}
+
+	if *frames == 0 {
+		*frames = s.Frames()
+	}
+	if *frames <= 0 {
+		fmt.Fprintln(os.Stderr, "no frames to run, the number of frames is needed without a script")
+		os.Exit(-1)
+	}
+
+	err = s.RunFrames(vm, machine, *frames, savePNG)
+	// The movie is also saved when the program fails, to reproduce the failure
+	if *recordFile != "" {
+		if err := movie.Save(*recordFile); err != nil {
+			fmt.Fprintln(os.Stderr, "cannot save the movie: ", err)
+			os.Exit(-1)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "system errored: ", err)
+		var e *chip8.MachineError
+		if errors.As(err, &e) {
+			fmt.Fprintln(os.Stderr, e.Registers())
+		}
+		os.Exit(-1)
+	}
+
+	// Both players end in the same state, which is printed to compare them
+	if session != nil {
+		if err := session.Sync(); err != nil {
+			fmt.Fprintln(os.Stderr, "cannot synchronize the players: ", err)
+			os.Exit(-1)
+		}
+		session.Close()
+		state, err := vm.MarshalBinary()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "cannot save state: ", err)
+			os.Exit(-1)
+		}
+		fmt.Printf("%d frames played, state checksum %08X\n", session.Frame(), crc32.ChecksumIEEE(state))
+	}
+}
+
+// playback runs the frames of a movie, the keys of the script are ignored.
+type playback struct {
+	player *chip8.Player
+}
+
+func (p playback) GetNextFrame(inputs [16]bool) ([]uint32, []int16, error) {
+	return p.player.GetNextFrame()
+}
+
+// savePNG saves the screenshot of an event in a PNG file.
+func savePNG(e script.Event, img image.Image) error {
+	f, err := os.Create(e.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := png.Encode(f, img); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Bit-Doctor/emulation/pkg/chip8/asm"
+)
+
+// assemble runs the asm command, which compiles an Octo source file into a ROM and its symbol file.
+func assemble(args []string) int {
+	flags := flag.NewFlagSet("asm", flag.ExitOnError)
+	output := flags.String("o", "", "ROM file to write, the source file with the .ch8 extension when not set")
+	symbolsPath := flags.String("symbols", "", "symbol file to write, the ROM file with the .sym extension when not set")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %v asm [options] <file>\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return -1
+	}
+
+	source := flags.Arg(0)
+	if *output == "" {
+		*output = strings.TrimSuffix(source, filepath.Ext(source)) + ".ch8"
+	}
+	if *symbolsPath == "" {
+		*symbolsPath = strings.TrimSuffix(*output, filepath.Ext(*output)) + ".sym"
+	}
+
+	src, err := ioutil.ReadFile(source)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "cannot", err)
+		return -1
+	}
+
+	// The source files of the symbol file are relative to its directory
+	name, err := filepath.Rel(filepath.Dir(*symbolsPath), source)
+	if err != nil {
+		if name, err = filepath.Abs(source); err != nil {
+			fmt.Fprintln(os.Stderr, "cannot", err)
+			return -1
+		}
+	}
+
+	program, err := asm.Assemble(filepath.ToSlash(name), src)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return -1
+	}
+
+	if err := ioutil.WriteFile(*output, program.ROM, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "cannot", err)
+		return -1
+	}
+
+	f, err := os.Create(*symbolsPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "cannot", err)
+		return -1
+	}
+	defer f.Close()
+	if err := program.Symbols.Write(f); err != nil {
+		fmt.Fprintln(os.Stderr, "cannot write the symbol file: ", err)
+		return -1
+	}
+	return 0
+}
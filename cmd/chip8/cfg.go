@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/Bit-Doctor/emulation/pkg/chip8"
+	"github.com/Bit-Doctor/emulation/pkg/chip8/cfg"
+)
+
+// controlFlow runs the cfg command, which writes the control-flow graph of a ROM on the standard output.
+func controlFlow(args []string) int {
+	var names []string
+	for _, p := range chip8.Platforms {
+		names = append(names, p.Name)
+	}
+
+	flags := flag.NewFlagSet("cfg", flag.ExitOnError)
+	platformName := flags.String("platform", chip8.PlatformOcto.Name, "platform of the program, one of: "+strings.Join(names, ", "))
+	format := flags.String("format", "dot", "output format, dot or json")
+	calls := flags.Bool("calls", false, "write the call graph of the subroutines instead of the blocks, in the dot format")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %v cfg [options] <file>\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 || *format != "dot" && *format != "json" {
+		flags.Usage()
+		return -1
+	}
+
+	platform, ok := chip8.PlatformByName(*platformName)
+	if !ok {
+		fmt.Fprintln(os.Stderr, "unknown platform: ", *platformName)
+		return -1
+	}
+
+	data, err := ioutil.ReadFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "cannot", err)
+		return -1
+	}
+
+	graph := cfg.Build(data, platform.LoadAddress, platform.InstructionSet)
+	switch {
+	case *calls:
+		err = graph.WriteCallGraphDOT(os.Stdout)
+	case *format == "json":
+		err = graph.WriteJSON(os.Stdout)
+	default:
+		err = graph.WriteDOT(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "cannot write the graph: ", err)
+		return -1
+	}
+	return 0
+}
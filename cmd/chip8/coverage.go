@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/Bit-Doctor/emulation/pkg/chip8"
+	"github.com/Bit-Doctor/emulation/pkg/chip8/coverage"
+)
+
+// showCoverage runs the coverage command, which merges the coverage reports of a ROM and writes its disassembly
+// annotated with the coverage on the standard output.
+func showCoverage(args []string) int {
+	var names []string
+	for _, p := range chip8.Platforms {
+		names = append(names, p.Name)
+	}
+
+	flags := flag.NewFlagSet("coverage", flag.ExitOnError)
+	platformName := flags.String("platform", chip8.PlatformOcto.Name, "platform of the program, one of: "+strings.Join(names, ", "))
+	output := flags.String("o", "", "write the merged coverage report to a file")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %v coverage [options] <file> <report>...\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() < 2 {
+		flags.Usage()
+		return -1
+	}
+
+	platform, ok := chip8.PlatformByName(*platformName)
+	if !ok {
+		fmt.Fprintln(os.Stderr, "unknown platform: ", *platformName)
+		return -1
+	}
+
+	data, err := ioutil.ReadFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "cannot", err)
+		return -1
+	}
+
+	var report *coverage.Report
+	for _, path := range flags.Args()[1:] {
+		r, err := coverage.Load(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "cannot load the coverage: ", err)
+			return -1
+		}
+		if report == nil {
+			report = r
+		} else if err := report.Merge(r); err != nil {
+			fmt.Fprintln(os.Stderr, "cannot merge the coverage: ", err)
+			return -1
+		}
+	}
+	if err := report.Check(data, platform.LoadAddress); err != nil {
+		fmt.Fprintln(os.Stderr, "the coverage is not of this ROM on this platform: ", err)
+		return -1
+	}
+
+	if *output != "" {
+		if err := writeReport(report, *output); err != nil {
+			fmt.Fprintln(os.Stderr, "cannot write the coverage: ", err)
+			return -1
+		}
+	}
+
+	if err := report.WriteListing(os.Stdout, data, platform.InstructionSet); err != nil {
+		fmt.Fprintln(os.Stderr, "cannot write the listing: ", err)
+		return -1
+	}
+	return 0
+}
+
+// writeReport writes a coverage report to a file.
+func writeReport(report *coverage.Report, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := report.Write(f); err != nil {
+		return err
+	}
+	return f.Close()
+}
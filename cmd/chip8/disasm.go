@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/Bit-Doctor/emulation/pkg/chip8"
+	"github.com/Bit-Doctor/emulation/pkg/chip8/disasm"
+)
+
+// disassemble runs the disasm command, which writes the Octo source code of a ROM on the standard output.
+func disassemble(args []string) int {
+	var names []string
+	for _, p := range chip8.Platforms {
+		names = append(names, p.Name)
+	}
+
+	flags := flag.NewFlagSet("disasm", flag.ExitOnError)
+	platformName := flags.String("platform", chip8.PlatformOcto.Name, "platform of the program, one of: "+strings.Join(names, ", "))
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %v disasm [options] <file>\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return -1
+	}
+
+	platform, ok := chip8.PlatformByName(*platformName)
+	if !ok {
+		fmt.Fprintln(os.Stderr, "unknown platform: ", *platformName)
+		return -1
+	}
+
+	data, err := ioutil.ReadFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "cannot", err)
+		return -1
+	}
+
+	program := disasm.Disassemble(data, platform.LoadAddress, platform.InstructionSet)
+	if err := program.Write(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "cannot write the program: ", err)
+		return -1
+	}
+	return 0
+}
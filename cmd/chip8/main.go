@@ -1,14 +1,25 @@
 package main
 
 import (
+	"bufio"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"os"
 	"reflect"
+	"strconv"
+	"strings"
 	"time"
 	"unsafe"
 
 	"github.com/Bit-Doctor/emulation/pkg/chip8"
+	"github.com/Bit-Doctor/emulation/pkg/chip8/coverage"
+	"github.com/Bit-Doctor/emulation/pkg/chip8/dap"
+	"github.com/Bit-Doctor/emulation/pkg/chip8/netplay"
+	"github.com/Bit-Doctor/emulation/pkg/chip8/symbols"
 	"github.com/veandco/go-sdl2/sdl"
 )
 
@@ -31,11 +42,212 @@ var keyMap = map[sdl.Scancode]byte{
 	sdl.SCANCODE_V: 0xF,
 }
 
+// The number of save state slots.
+const slotCount = 10
+
+// The number of frames that can be rewound, 30 seconds of gameplay.
+const rewindFrames = 30 * chip8.FramePerSecond
+
 func main() {
-	if len(os.Args) != 2 {
-		fmt.Fprintf(os.Stderr, "Usage: %v <file>\n", os.Args[0])
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "asm":
+			os.Exit(assemble(os.Args[2:]))
+		case "cfg":
+			os.Exit(controlFlow(os.Args[2:]))
+		case "coverage":
+			os.Exit(showCoverage(os.Args[2:]))
+		case "disasm":
+			os.Exit(disassemble(os.Args[2:]))
+		}
+	}
+
+	var names []string
+	for _, p := range chip8.Platforms {
+		names = append(names, p.Name)
+	}
+
+	platformName := flag.String("platform", chip8.PlatformOcto.Name, "emulated platform, one of: "+strings.Join(names, ", "))
+	cycles := flag.Int("cycles", 0, "instructions executed per frame, the default of the platform when not set")
+	seed := flag.Int64("seed", 0, "seed of the random numbers, the current time when not set")
+	vipRandom := flag.Bool("vip-random", false, "generate the random numbers like the COSMAC VIP interpreter")
+	traceFile := flag.String("trace", "", "write every instruction executed and the state of the machine to a file, - for the standard error")
+	traceRange := flag.String("trace-range", "", "trace only the instructions in an address range like 0x200-0x2FF")
+	profileFile := flag.String("profile", "", "write a pprof profile of the instructions executed by subroutine to a file when quitting")
+	symbolsFile := flag.String("symbols", "", "symbol file naming the subroutines in the profile")
+	coverageFile := flag.String("coverage", "", "write the addresses executed, read and written to a coverage report when quitting, merged with the report if it exists")
+	recordFile := flag.String("record", "", "record the keys pressed in a movie file, saved when quitting")
+	playFile := flag.String("play", "", "play the keys pressed and the configuration of a movie file, then hand over to the keyboard")
+	hostAddr := flag.String("host", "", "host a netplay session on a UDP address like :7000, the configuration of the machine is sent to the other player")
+	joinAddr := flag.String("join", "", "join the netplay session hosted on a UDP address like 192.168.1.10:7000")
+	delay := flag.Int("delay", 2, "frames before the keys pressed are used in a hosted netplay session")
+	dapAddr := flag.String("dap", "", "serve the Debug Adapter Protocol on stdio or on a TCP address like 127.0.0.1:4711, the ROM and options come from the launch request")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %v [options] <file>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %v --dap stdio|<address>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %v asm [options] <file>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %v disasm [options] <file>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %v cfg [options] <file>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %v coverage [options] <file> <report>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	// The debugger, the movies and netplay cannot be used together
+	modes := 0
+	for _, f := range []string{*dapAddr, *recordFile, *playFile, *hostAddr, *joinAddr} {
+		if f != "" {
+			modes++
+		}
+	}
+	if modes > 1 || *dapAddr == "" && flag.NArg() != 1 || *dapAddr != "" && flag.NArg() != 0 {
+		flag.Usage()
 		os.Exit(-1)
 	}
+	// The rollbacks of netplay run the frames again, they would be counted twice
+	if (*hostAddr != "" || *joinAddr != "") && (*traceFile != "" || *profileFile != "" || *coverageFile != "") {
+		fmt.Fprintln(os.Stderr, "cannot trace, profile or cover a netplay session, its frames are played again on rollbacks")
+		os.Exit(-1)
+	}
+
+	var vm *chip8.Chip8
+	var platform chip8.Platform
+	var server *dap.Server
+	var recorder *chip8.Recorder
+	var player *chip8.Player
+	var session *netplay.Session
+	rom := flag.Arg(0)
+	if *dapAddr != "" {
+		conn, err := listenDAP(*dapAddr)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "cannot serve the debug adapter protocol: ", err)
+			os.Exit(-1)
+		}
+
+		server = dap.NewServer(conn)
+		if vm, platform, err = server.Launch(); err != nil {
+			fmt.Fprintln(os.Stderr, "cannot launch the program: ", err)
+			os.Exit(-1)
+		}
+		rom = server.Program()
+	} else {
+		var ok bool
+		platform, ok = chip8.PlatformByName(*platformName)
+		if !ok {
+			fmt.Fprintln(os.Stderr, "unknown platform: ", *platformName)
+			os.Exit(-1)
+		}
+
+		data, err := ioutil.ReadFile(rom)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "cannot", err)
+			os.Exit(-1)
+		}
+
+		// A recording and netplay need the seed, which is otherwise chosen by the machine
+		seedSet := false
+		flag.Visit(func(f *flag.Flag) {
+			seedSet = seedSet || f.Name == "seed"
+		})
+		if !seedSet && (*recordFile != "" || *hostAddr != "") {
+			*seed, seedSet = time.Now().UTC().UnixNano(), true
+		}
+
+		if *hostAddr != "" || *joinAddr != "" {
+			config := netplay.Config{Platform: platform, Seed: *seed, VIPRandom: *vipRandom, Cycles: *cycles, Delay: *delay}
+			if *hostAddr != "" {
+				fmt.Fprintln(os.Stderr, "waiting for the other player on", *hostAddr)
+			}
+			if session, err = netplay.Connect(*hostAddr, *joinAddr, data, config); err != nil {
+				fmt.Fprintln(os.Stderr, "cannot start netplay: ", err)
+				os.Exit(-1)
+			}
+			defer session.Close()
+			vm, platform = session.Machine(), session.Platform()
+		} else if *playFile != "" {
+			movie, err := chip8.LoadMovie(*playFile)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "cannot load the movie: ", err)
+				os.Exit(-1)
+			}
+			if vm, err = movie.Machine(data); err != nil {
+				fmt.Fprintln(os.Stderr, "cannot play the movie: ", err)
+				os.Exit(-1)
+			}
+			platform, _ = chip8.PlatformByName(movie.Platform)
+			player = chip8.NewPlayer(vm, movie)
+		} else {
+			var opts []chip8.Option
+			if seedSet {
+				opts = append(opts, chip8.WithSeed(*seed))
+			}
+			if *vipRandom {
+				opts = append(opts, chip8.WithVIPRandom())
+			}
+
+			vm = chip8.New(platform, opts...)
+			if *cycles > 0 {
+				vm.SetCyclesPerFrame(*cycles)
+			}
+			if err := vm.LoadGame(data); err != nil {
+				fmt.Fprintln(os.Stderr, "cannot load game data: ", err)
+				os.Exit(-1)
+			}
+
+			if *recordFile != "" {
+				movie := chip8.NewMovie(data, platform, *seed, *vipRandom)
+				recorder = chip8.NewRecorder(vm, movie)
+				defer func() {
+					if err := movie.Save(*recordFile); err != nil {
+						fmt.Fprintln(os.Stderr, "cannot save the movie: ", err)
+					}
+				}()
+			}
+		}
+	}
+
+	if *traceFile != "" {
+		tracer, closeTrace, err := openTrace(*traceFile, *traceRange)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "cannot trace: ", err)
+			os.Exit(-1)
+		}
+		defer func() {
+			if err := closeTrace(); err != nil {
+				fmt.Fprintln(os.Stderr, "cannot write the trace: ", err)
+			}
+		}()
+		vm.SetTracer(tracer)
+	}
+
+	if *profileFile != "" {
+		var table *symbols.Table
+		if *symbolsFile != "" {
+			var err error
+			if table, err = symbols.Load(*symbolsFile); err != nil {
+				fmt.Fprintln(os.Stderr, "cannot load the symbols: ", err)
+				os.Exit(-1)
+			}
+		}
+
+		profiler := chip8.NewProfiler()
+		vm.SetProfiler(profiler)
+		defer func() {
+			if err := writeProfile(profiler, *profileFile, table); err != nil {
+				fmt.Fprintln(os.Stderr, "cannot write the profile: ", err)
+			}
+		}()
+	}
+
+	if *coverageFile != "" {
+		cov := chip8.NewCoverage()
+		vm.SetCoverage(cov)
+		defer func() {
+			if err := writeCoverage(cov, *coverageFile, rom, platform); err != nil {
+				fmt.Fprintln(os.Stderr, "cannot write the coverage: ", err)
+			}
+		}()
+	}
 
 	if err := sdl.Init(sdl.INIT_EVERYTHING); err != nil {
 		fmt.Fprintln(os.Stderr, "cannot initialize SDL: ", err)
@@ -56,7 +268,7 @@ func main() {
 		os.Exit(-1)
 	}
 	defer renderer.Destroy()
-	renderer.SetLogicalSize(chip8.DisplayWidth, chip8.DisplayHeight)
+	renderer.SetLogicalSize(int32(platform.DisplayWidth), int32(platform.DisplayHeight))
 
 	texture, err := renderer.CreateTexture(sdl.PIXELFORMAT_ARGB8888, sdl.TEXTUREACCESS_STREAMING, chip8.DisplayWidth, chip8.DisplayHeight)
 	if err != nil {
@@ -77,19 +289,17 @@ func main() {
 	defer sdl.CloseAudioDevice(devID)
 	sdl.PauseAudioDevice(devID, false)
 
-	vm := chip8.New()
-	data, err := ioutil.ReadFile(os.Args[1])
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "cannot", err)
-		os.Exit(-1)
-	}
+	rewinder := chip8.NewRewinder(vm, rewindFrames)
 
-	if err := vm.LoadGame(data); err != nil {
-		fmt.Fprintln(os.Stderr, "cannot load game data: ", err)
-		os.Exit(-1)
+	// Going back in time would break the movies and the netplay session
+	canRewind := func() bool {
+		return recorder == nil && (player == nil || player.Done()) && session == nil
 	}
 
 	var input [16]bool
+	var slot int
+	var rewinding bool
+	var errs errorReporter
 	running := true
 	for running {
 		start := time.Now()
@@ -100,34 +310,259 @@ func main() {
 			case *sdl.KeyboardEvent:
 				if event.Keysym.Scancode == sdl.SCANCODE_ESCAPE {
 					running = false
+				} else if event.Keysym.Scancode == sdl.SCANCODE_BACKSPACE {
+					rewinding = event.Type == sdl.KEYDOWN && canRewind()
 				} else if key, ok := keyMap[event.Keysym.Scancode]; ok {
 					input[key] = event.Type == sdl.KEYDOWN
+				} else if event.Type == sdl.KEYDOWN && event.Repeat == 0 {
+					switch event.Keysym.Scancode {
+					case sdl.SCANCODE_F5:
+						if err := saveState(vm, statePath(rom, slot)); err != nil {
+							fmt.Fprintln(os.Stderr, "cannot save state: ", err)
+						} else {
+							fmt.Println("state saved to slot", slot)
+						}
+					case sdl.SCANCODE_F6:
+						slot = (slot + slotCount - 1) % slotCount
+						fmt.Println("state slot", slot)
+					case sdl.SCANCODE_F7:
+						slot = (slot + 1) % slotCount
+						fmt.Println("state slot", slot)
+					case sdl.SCANCODE_EQUALS, sdl.SCANCODE_KP_PLUS:
+						if session != nil {
+							fmt.Fprintln(os.Stderr, "cannot change the speed during netplay")
+							break
+						}
+						vm.SetCyclesPerFrame(vm.CyclesPerFrame() * 2)
+						fmt.Println("speed", vm.CyclesPerFrame(), "instructions per frame")
+					case sdl.SCANCODE_MINUS, sdl.SCANCODE_KP_MINUS:
+						if session != nil {
+							fmt.Fprintln(os.Stderr, "cannot change the speed during netplay")
+							break
+						}
+						vm.SetCyclesPerFrame(vm.CyclesPerFrame() / 2)
+						fmt.Println("speed", vm.CyclesPerFrame(), "instructions per frame")
+					case sdl.SCANCODE_F8:
+						if !canRewind() {
+							fmt.Fprintln(os.Stderr, "cannot load state while recording or playing a movie, or during netplay")
+						} else if err := loadState(vm, statePath(rom, slot)); err != nil {
+							fmt.Fprintln(os.Stderr, "cannot load state: ", err)
+						} else {
+							fmt.Println("state loaded from slot", slot)
+						}
+					}
 				}
 			}
 		}
 
-		fb, sb, err := vm.GetNextFrame(input)
-		if err != nil {
-			fmt.Fprintln(os.Stderr, "system errored: ", err)
+		var fb []uint32
+		var sb []int16
+		if server != nil {
+			// The debugger drives the machine, there is no rewind
+			fb, sb, err = server.Frame(input)
+			if err == dap.ErrDisconnected {
+				running = false
+				continue
+			}
+		} else if session != nil {
+			fb, sb, err = session.GetNextFrame(input)
+			if err == netplay.ErrDisconnected || err == netplay.ErrTimeout {
+				fmt.Fprintln(os.Stderr, "netplay ended: ", err)
+				running = false
+				continue
+			}
+		} else if player != nil && !player.Done() {
+			// The keyboard is ignored until the end of the movie
+			fb, sb, err = player.GetNextFrame()
+			if player.Done() {
+				fmt.Println("end of the movie")
+			}
+		} else if recorder != nil {
+			fb, sb, err = recorder.GetNextFrame(input)
+		} else if rewinding {
+			// The frames are played backward silently, the last one stays on screen when there is nothing left to rewind.
+			var ok bool
+			fb, ok, err = rewinder.Rewind()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "cannot rewind: ", err)
+			}
+			if !ok || err != nil {
+				time.Sleep(time.Second/chip8.FramePerSecond - time.Since(start))
+				continue
+			}
+		} else {
+			fb, sb, err = rewinder.GetNextFrame(input)
 		}
 
+		errs.report(err)
+
+		width, height := vm.Resolution()
+		screen := &sdl.Rect{W: int32(width), H: int32(height)}
 		renderer.Clear()
-		texture.UpdateRGBA(nil, fb, chip8.DisplayWidth)
-		if err := renderer.Copy(texture, nil, nil); err != nil {
+		texture.UpdateRGBA(screen, fb, width)
+		if err := renderer.Copy(texture, screen, nil); err != nil {
 			fmt.Fprintln(os.Stderr, "cannot draw frame: ", err)
 		}
 
 		renderer.Present()
 
-		var data []byte
-		sh := (*reflect.SliceHeader)(unsafe.Pointer(&data))
-		sh.Len = len(sb) * 2
-		sh.Cap = len(sb) * 2
-		sh.Data = uintptr(unsafe.Pointer(&sb[0]))
-		if err := sdl.QueueAudio(devID, data); err != nil {
-			fmt.Fprintln(os.Stderr, "cannot queue audio: ", err)
+		if len(sb) > 0 {
+			var data []byte
+			sh := (*reflect.SliceHeader)(unsafe.Pointer(&data))
+			sh.Len = len(sb) * 2
+			sh.Cap = len(sb) * 2
+			sh.Data = uintptr(unsafe.Pointer(&sb[0]))
+			if err := sdl.QueueAudio(devID, data); err != nil {
+				fmt.Fprintln(os.Stderr, "cannot queue audio: ", err)
+			}
 		}
 
 		time.Sleep(time.Second/chip8.FramePerSecond - time.Since(start))
 	}
 }
+
+// listenDAP return the connection to the debug adapter protocol client, on stdio or on the first client connecting to a TCP address.
+func listenDAP(addr string) (io.ReadWriter, error) {
+	if addr == "stdio" {
+		conn := struct {
+			io.Reader
+			io.Writer
+		}{os.Stdin, os.Stdout}
+		// The messages of the emulator must not mix with the protocol
+		os.Stdout = os.Stderr
+		return conn, nil
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Close()
+
+	fmt.Fprintln(os.Stderr, "waiting for a debugger on", l.Addr())
+	return l.Accept()
+}
+
+// openTrace return a tracer writing to a file, or to the standard error for -, restricted to an address range like
+// 0x200-0x2FF if not empty. The function returned flushes and closes the file.
+func openTrace(path, addrRange string) (*chip8.Tracer, func() error, error) {
+	start, end := uint64(0), uint64(0xFFFF)
+	if addrRange != "" {
+		bounds := strings.SplitN(addrRange, "-", 2)
+		var err1, err2 error
+		if len(bounds) == 2 {
+			start, err1 = strconv.ParseUint(bounds[0], 0, 16)
+			end, err2 = strconv.ParseUint(bounds[1], 0, 16)
+		}
+		if len(bounds) != 2 || err1 != nil || err2 != nil || start > end {
+			return nil, nil, fmt.Errorf("invalid address range: %q", addrRange)
+		}
+	}
+
+	f := os.Stderr
+	if path != "-" {
+		var err error
+		if f, err = os.Create(path); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	w := bufio.NewWriter(f)
+	tracer := chip8.NewTracer(w)
+	tracer.SetRange(uint16(start), uint16(end))
+	closeTrace := func() error {
+		if err := tracer.Err(); err != nil {
+			return err
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if f != os.Stderr {
+			return f.Close()
+		}
+		return nil
+	}
+	return tracer, closeTrace, nil
+}
+
+// writeProfile writes the profile of the execution to a file, naming the subroutines after the labels of table if
+// not nil.
+func writeProfile(profiler *chip8.Profiler, path string, table *symbols.Table) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var sym chip8.Symbolizer
+	if table != nil {
+		sym = table
+	}
+	if err := profiler.WriteProfile(f, sym); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// writeCoverage writes the coverage report of a ROM to a file, merged with the report already in the file if any.
+func writeCoverage(cov *chip8.Coverage, path, rom string, platform chip8.Platform) error {
+	data, err := ioutil.ReadFile(rom)
+	if err != nil {
+		return err
+	}
+	report := coverage.New(cov, data, platform.LoadAddress)
+	if previous, err := coverage.Load(path); err == nil {
+		if err := report.Merge(previous); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	return writeReport(report, path)
+}
+
+// statePath return the file of a save state slot, next to the ROM.
+func statePath(rom string, slot int) string {
+	return fmt.Sprintf("%s.state%d", rom, slot)
+}
+
+// saveState write a snapshot of the machine in a file.
+func saveState(vm *chip8.Chip8, path string) error {
+	state, err := vm.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, state, 0644)
+}
+
+// loadState restore a snapshot of the machine from a file.
+func loadState(vm *chip8.Chip8, path string) error {
+	state, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return vm.UnmarshalBinary(state)
+}
+
+// errorReporter prints the errors of the machine on the standard error, with the registers for a *chip8.MachineError.
+// An error repeated on the following frames, like a segmentation fault the program cannot leave, is printed once.
+type errorReporter struct {
+	last string
+}
+
+func (r *errorReporter) report(err error) {
+	if err == nil {
+		r.last = ""
+		return
+	}
+	if err.Error() == r.last {
+		return
+	}
+	r.last = err.Error()
+
+	fmt.Fprintln(os.Stderr, "system errored: ", err)
+	var e *chip8.MachineError
+	if errors.As(err, &e) {
+		fmt.Fprintln(os.Stderr, e.Registers())
+	}
+}
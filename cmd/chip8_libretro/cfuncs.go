@@ -2,6 +2,7 @@ package main
 
 /*
 #include "libretro.h"
+#include <stdlib.h>
 
 bool bridge_environment(retro_environment_t f, unsigned cmd, void *data) {
 	return f(cmd, data);
@@ -27,9 +28,17 @@ int16_t bridge_input_state(retro_input_state_t f, unsigned port, unsigned device
 	return f(port, device, index, id);
 }
 
+void bridge_log(retro_log_printf_t f, enum retro_log_level level, const char *msg) {
+	f(level, "%s\n", msg);
+}
+
 */
 import "C"
-import "unsafe"
+import (
+	"fmt"
+	"os"
+	"unsafe"
+)
 
 func environment(cmd uint, ptr unsafe.Pointer) bool {
 	if envCb != nil {
@@ -69,3 +78,14 @@ func inputState(port, device, index, id uint) int16 {
 	}
 	return 0
 }
+
+// logMessage writes a message with the log interface of the frontend, or on the standard error without one.
+func logMessage(level C.enum_retro_log_level, msg string) {
+	if logCb == nil {
+		fmt.Fprintln(os.Stderr, msg)
+		return
+	}
+	m := C.CString(msg)
+	defer C.free(unsafe.Pointer(m))
+	C.bridge_log(logCb, level, m)
+}
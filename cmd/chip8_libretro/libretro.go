@@ -13,6 +13,9 @@ typedef const char const_char;
 import "C"
 
 import (
+	"errors"
+	"strconv"
+	"strings"
 	"unsafe"
 
 	"github.com/Bit-Doctor/emulation/pkg/chip8"
@@ -25,17 +28,34 @@ var (
 	audioSampleBatchCb C.retro_audio_sample_batch_t
 	inputPollCb        C.retro_input_poll_t
 	inputStateCb       C.retro_input_state_t
+	logCb              C.retro_log_printf_t
 )
 
+// The instructions per frame proposed by the core option, besides the default of the platform.
+var cycleOptions = []string{"7", "10", "15", "20", "30", "50", "100", "200", "500", "1000", "2000", "5000", "10000", "20000", "50000"}
+
 var (
-	vm     *chip8.Chip8
-	toFree []unsafe.Pointer
+	vm       *chip8.Chip8
+	platform = chip8.PlatformOcto
+	toFree   []unsafe.Pointer
+
+	// The size of a save state doesn't change while a game is loaded, it is computed once
+	// so frontends rewinding every frame don't marshal the machine twice.
+	stateSize int
+
+	// The last error of the machine reported, an error repeated on the following frames is reported once.
+	lastError string
 )
 
 //export retro_set_environment
 func retro_set_environment(cb C.retro_environment_t) {
 	envCb = cb
 
+	var logging C.struct_retro_log_callback
+	if environment(C.RETRO_ENVIRONMENT_GET_LOG_INTERFACE, unsafe.Pointer(&logging)) {
+		logCb = logging.log
+	}
+
 	descriptors := []C.struct_retro_input_descriptor{
 		{description: C.CString("0"), device: C.RETRO_DEVICE_JOYPAD, id: C.RETRO_DEVICE_ID_JOYPAD_SELECT},
 		{description: C.CString("1"), device: C.RETRO_DEVICE_JOYPAD, id: C.RETRO_DEVICE_ID_JOYPAD_Y},
@@ -61,6 +81,26 @@ func retro_set_environment(cb C.retro_environment_t) {
 	}
 
 	environment(C.RETRO_ENVIRONMENT_SET_INPUT_DESCRIPTORS, unsafe.Pointer(&descriptors[0]))
+
+	// The default platform comes first
+	names := []string{platform.Name}
+	for _, p := range chip8.Platforms {
+		if p.Name != platform.Name {
+			names = append(names, p.Name)
+		}
+	}
+
+	variables := []C.struct_retro_variable{
+		{key: C.CString("chip8_platform"), value: C.CString("Platform (restart); " + strings.Join(names, "|"))},
+		{key: C.CString("chip8_cycles"), value: C.CString("Instructions per frame; platform|" + strings.Join(cycleOptions, "|"))},
+		{},
+	}
+
+	for _, v := range variables {
+		toFree = append(toFree, unsafe.Pointer(v.key), unsafe.Pointer(v.value))
+	}
+
+	environment(C.RETRO_ENVIRONMENT_SET_VARIABLES, unsafe.Pointer(&variables[0]))
 }
 
 //export retro_set_video_refresh
@@ -79,9 +119,7 @@ func retro_set_input_poll(cb C.retro_input_poll_t) { inputPollCb = cb }
 func retro_set_input_state(cb C.retro_input_state_t) { inputStateCb = cb }
 
 //export retro_init
-func retro_init() {
-	vm = chip8.New()
-}
+func retro_init() {}
 
 //export retro_deinit
 func retro_deinit() {
@@ -118,10 +156,11 @@ func retro_get_system_av_info(info *C.struct_retro_system_av_info) {
 			sample_rate: chip8.SamplingRate,
 		},
 		geometry: C.struct_retro_game_geometry{
-			base_width:  chip8.DisplayWidth,
-			base_height: chip8.DisplayHeight,
-			max_width:   chip8.DisplayWidth,
-			max_height:  chip8.DisplayHeight,
+			base_width:   chip8.LowResWidth,
+			base_height:  chip8.LowResHeight,
+			max_width:    C.unsigned(platform.DisplayWidth),
+			max_height:   C.unsigned(platform.DisplayHeight),
+			aspect_ratio: chip8.DisplayWidth / chip8.DisplayHeight,
 		},
 	}
 }
@@ -154,20 +193,54 @@ func retro_run() {
 		0xF: inputState(0, C.RETRO_DEVICE_JOYPAD, 0, C.RETRO_DEVICE_ID_JOYPAD_L3) == 1,
 	}
 
-	fb, sb, _ := vm.GetNextFrame(inputs)
+	var updated C.bool
+	if environment(C.RETRO_ENVIRONMENT_GET_VARIABLE_UPDATE, unsafe.Pointer(&updated)) && bool(updated) {
+		setCycles()
+	}
+
+	fb, sb, err := vm.GetNextFrame(inputs)
+	reportError(err)
+	if err != nil {
+		// The display stays as the instruction left it, the frontend still needs a frame
+		fb, sb = vm.Framebuffer(), make([]int16, chip8.SamplePerFrame*2)
+	}
 
-	videoRefresh(fb, chip8.DisplayWidth, chip8.DisplayHeight, chip8.DisplayWidth*4)
+	width, height := vm.Resolution()
+	videoRefresh(fb, uint(width), uint(height), uint(width)*4)
 	audioSampleBatch(sb)
 }
 
 //export retro_serialize_size
-func retro_serialize_size() C.size_t { return 0 }
+func retro_serialize_size() C.size_t {
+	return C.size_t(stateSize)
+}
 
 //export retro_serialize
-func retro_serialize(data unsafe.Pointer, size C.size_t) C.bool { return false }
+func retro_serialize(data unsafe.Pointer, size C.size_t) C.bool {
+	if vm == nil || int(size) < stateSize {
+		return false
+	}
+
+	state, err := vm.MarshalBinary()
+	if err != nil || len(state) != stateSize {
+		return false
+	}
+
+	C.memcpy(data, unsafe.Pointer(&state[0]), C.size_t(len(state)))
+	C.memset(unsafe.Pointer(uintptr(data)+uintptr(len(state))), 0, size-C.size_t(len(state)))
+	return true
+}
 
 //export retro_unserialize
-func retro_unserialize(data *C.const_void, size C.size_t) C.bool { return false }
+func retro_unserialize(data *C.const_void, size C.size_t) C.bool {
+	if vm == nil || int(size) < stateSize {
+		return false
+	}
+
+	// Frontends may hand back a bigger buffer than requested, only the beginning holds the state.
+	state := C.GoBytes(unsafe.Pointer(data), C.int(stateSize))
+	return vm.UnmarshalBinary(state) == nil
+}
 
 //export retro_cheat_reset
 func retro_cheat_reset() {}
@@ -181,11 +254,28 @@ func retro_load_game(info *C.const_retro_game_info) C.bool {
 		return false
 	}
 
+	if p, ok := chip8.PlatformByName(variable("chip8_platform")); ok {
+		platform = p
+	}
+
+	vm = chip8.New(platform)
+	lastError = ""
+	setCycles()
 	b := C.GoBytes(info.data, C.int(info.size))
 	if err := vm.LoadGame(b); err != nil {
 		return false
 	}
 
+	state, err := vm.MarshalBinary()
+	if err != nil {
+		return false
+	}
+	stateSize = len(state)
+
+	// The state has a fixed size and can be restored in any session, which lets the frontend rewind.
+	var quirks C.uint64_t
+	environment(C.RETRO_ENVIRONMENT_SET_SERIALIZATION_QUIRKS, unsafe.Pointer(&quirks))
+
 	return true
 }
 
@@ -195,7 +285,10 @@ func retro_load_game_special(gameType C.unsigned, info *C.const_retro_game_info,
 }
 
 //export retro_unload_game
-func retro_unload_game() {}
+func retro_unload_game() {
+	vm = nil
+	stateSize = 0
+}
 
 //export retro_get_region
 func retro_get_region() C.unsigned { return C.RETRO_REGION_PAL }
@@ -206,4 +299,50 @@ func retro_get_memory_data(id C.unsigned) unsafe.Pointer { return nil }
 //export retro_get_memory_size
 func retro_get_memory_size(id C.unsigned) C.size_t { return 0 }
 
+// setCycles applies the instructions per frame core option.
+func setCycles() {
+	cycles, err := strconv.Atoi(variable("chip8_cycles"))
+	if err != nil {
+		cycles = platform.CyclePerFrame
+	}
+	vm.SetCyclesPerFrame(cycles)
+}
+
+// variable return the value of a core option, or an empty string if the frontend doesn't provide it.
+func variable(key string) string {
+	k := C.CString(key)
+	defer C.free(unsafe.Pointer(k))
+
+	v := C.struct_retro_variable{key: k}
+	if !environment(C.RETRO_ENVIRONMENT_GET_VARIABLE, unsafe.Pointer(&v)) || v.value == nil {
+		return ""
+	}
+	return C.GoString(v.value)
+}
+
+// reportError logs an error of the machine, with the registers for a *chip8.MachineError, and shows it to the player.
+func reportError(err error) {
+	if err == nil {
+		lastError = ""
+		return
+	}
+	if err.Error() == lastError {
+		return
+	}
+	lastError = err.Error()
+
+	msg := "system errored: " + err.Error()
+	var e *chip8.MachineError
+	if errors.As(err, &e) {
+		logMessage(C.RETRO_LOG_ERROR, msg+"\n"+e.Registers())
+	} else {
+		logMessage(C.RETRO_LOG_ERROR, msg)
+	}
+
+	text := C.CString(msg)
+	defer C.free(unsafe.Pointer(text))
+	message := C.struct_retro_message{msg: text, frames: 3 * chip8.FramePerSecond}
+	environment(C.RETRO_ENVIRONMENT_SET_MESSAGE, unsafe.Pointer(&message))
+}
+
 func main() {}
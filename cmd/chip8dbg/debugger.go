@@ -0,0 +1,481 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Bit-Doctor/emulation/pkg/chip8"
+)
+
+// debugger holds the machine being debugged and the state of the session.
+type debugger struct {
+	vm     *chip8.Chip8
+	breaks *chip8.Breakpoints
+
+	// The keys held while the machine runs.
+	keys [16]bool
+
+	// Stops the frames run by continue on Ctrl-C.
+	interrupt chan os.Signal
+}
+
+type command struct {
+	run   func(d *debugger, args []string) error
+	usage string
+	help  string
+}
+
+// commands is filled in init since help refers to it.
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"step":     {(*debugger).step, "step [n]", "execute n instructions, 1 by default"},
+		"next":     {(*debugger).next, "next", "execute the next instruction, running the frames of a subroutine call until it returns"},
+		"continue": {(*debugger).cont, "continue", "run frames until a breakpoint, a watchpoint or Ctrl-C"},
+		"until":    {(*debugger).until, "until <addr>", "run frames until the instruction at addr is reached"},
+		"frame":    {(*debugger).frame, "frame [n] [keys]", "run n frames holding the given hexadecimal keys, like 1a for 1 and A"},
+		"break":    {(*debugger).breakpoint, "break <addr>", "stop before executing the instruction at addr"},
+		"watch":    {(*debugger).watch, "watch <addr>[-<end>]|v<x> [r|w|rw]", "stop before an instruction accesses the memory or a register"},
+		"delete":   {(*debugger).delete, "delete <addr>|<addr>[-<end>]|v<x>", "delete a breakpoint or a watchpoint"},
+		"info":     {(*debugger).info, "info", "list the breakpoints and watchpoints"},
+		"regs":     {(*debugger).regs, "regs", "print the registers"},
+		"stack":    {(*debugger).stack, "stack", "print the return addresses on the stack"},
+		"mem":      {(*debugger).mem, "mem <addr> [n]", "print n bytes of memory, 64 by default"},
+		"disasm":   {(*debugger).disasm, "disasm [addr] [n]", "disassemble n instructions around addr, the program counter by default"},
+		"display":  {(*debugger).display, "display", "print the display as text"},
+		"quit":     {nil, "quit", "exit the debugger"},
+	}
+	commands["help"] = command{(*debugger).help, "help", "print this help"}
+
+	for alias, name := range map[string]string{"s": "step", "n": "next", "c": "continue", "u": "until", "f": "frame", "b": "break", "w": "watch", "d": "delete", "r": "regs", "x": "mem", "l": "disasm", "q": "quit"} {
+		commands[alias] = commands[name]
+	}
+}
+
+func (d *debugger) help(args []string) error {
+	var names []string
+	for name, cmd := range commands {
+		if strings.Fields(cmd.usage)[0] == name { // Aliases are left out
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Printf("  %-38s %s\n", commands[name].usage, commands[name].help)
+	}
+	return nil
+}
+
+func (d *debugger) step(args []string) error {
+	n := 1
+	if len(args) > 0 {
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil {
+			return fmt.Errorf("invalid count: %v", args[0])
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		if d.vm.State().Halted {
+			fmt.Println("the machine halted")
+			break
+		}
+		if err := d.vm.Step(); err != nil {
+			d.stopped(err)
+			return nil
+		}
+	}
+
+	d.where()
+	return nil
+}
+
+func (d *debugger) next(args []string) error {
+	s := d.vm.State()
+	hi, err := d.vm.Peek(s.PC)
+	if err != nil {
+		return err
+	}
+
+	if err := d.vm.Step(); err != nil {
+		d.stopped(err)
+		return nil
+	}
+	if hi&0xF0 != 0x20 {
+		d.where()
+		return nil
+	}
+
+	// Run the subroutine in frames until it returns, so it can wait for the timers or the keys and be interrupted
+	ret := s.PC + 2
+	temporary := !d.hasBreakpoint(ret)
+	if temporary {
+		d.breaks.Add(ret)
+		defer d.breaks.Remove(ret)
+	}
+	d.run(func(b *chip8.Break) bool {
+		// A recursive call returns to the same address with a deeper stack
+		return temporary && b.Kind == chip8.Breakpoint && b.PC == ret && d.vm.State().SP != s.SP
+	})
+	return nil
+}
+
+func (d *debugger) cont(args []string) error {
+	d.run(nil)
+	return nil
+}
+
+func (d *debugger) until(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: until <addr>")
+	}
+	addr, err := parseAddr(args[0])
+	if err != nil {
+		return err
+	}
+
+	// The breakpoint is temporary unless it was already set
+	if !d.hasBreakpoint(addr) {
+		d.breaks.Add(addr)
+		defer d.breaks.Remove(addr)
+	}
+
+	d.run(nil)
+	return nil
+}
+
+// hasBreakpoint tells whether a breakpoint is set at an address.
+func (d *debugger) hasBreakpoint(addr uint16) bool {
+	for _, b := range d.breaks.List() {
+		if b == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// run executes frames until the execution stops, running through the breaks for which resume is true if not nil.
+func (d *debugger) run(resume func(b *chip8.Break) bool) {
+	for {
+		select {
+		case <-d.interrupt:
+			fmt.Println("interrupted")
+			d.where()
+			return
+		default:
+		}
+
+		if d.vm.State().Halted {
+			fmt.Println("the machine halted")
+			return
+		}
+
+		if _, _, err := d.vm.GetNextFrame(d.keys); err != nil {
+			var b *chip8.Break
+			if resume != nil && errors.As(err, &b) && resume(b) {
+				continue
+			}
+			d.stopped(err)
+			return
+		}
+	}
+}
+
+func (d *debugger) frame(args []string) error {
+	n := 1
+	if len(args) > 0 {
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil {
+			return fmt.Errorf("invalid count: %v", args[0])
+		}
+	}
+
+	var keys [16]bool
+	if len(args) > 1 {
+		for _, r := range args[1] {
+			k, err := strconv.ParseUint(string(r), 16, 4)
+			if err != nil {
+				return fmt.Errorf("invalid key: %c", r)
+			}
+			keys[k] = true
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		if _, _, err := d.vm.GetNextFrame(keys); err != nil {
+			d.stopped(err)
+			return nil
+		}
+	}
+
+	d.where()
+	return nil
+}
+
+func (d *debugger) breakpoint(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: break <addr>")
+	}
+	addr, err := parseAddr(args[0])
+	if err != nil {
+		return err
+	}
+
+	d.breaks.Add(addr)
+	fmt.Printf("breakpoint at 0x%04X\n", addr)
+	return nil
+}
+
+func (d *debugger) watch(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errors.New("usage: watch <addr>[-<end>]|v<x> [r|w|rw]")
+	}
+
+	access := chip8.ReadWrite
+	if len(args) == 2 {
+		switch args[1] {
+		case "r":
+			access = chip8.Read
+		case "w":
+			access = chip8.Write
+		case "rw":
+			access = chip8.ReadWrite
+		default:
+			return fmt.Errorf("invalid access: %v", args[1])
+		}
+	}
+
+	if x, ok := parseRegister(args[0]); ok {
+		fmt.Printf("watching %v of V%X\n", access, x)
+		return d.breaks.WatchRegister(x, access)
+	}
+
+	start, end, err := parseRange(args[0])
+	if err != nil {
+		return err
+	}
+	d.breaks.WatchMemory(start, end, access)
+	fmt.Printf("watching %v of 0x%04X-0x%04X\n", access, start, end)
+	return nil
+}
+
+func (d *debugger) delete(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: delete <addr>|<addr>[-<end>]|v<x>")
+	}
+
+	if x, ok := parseRegister(args[0]); ok {
+		return d.breaks.WatchRegister(x, 0)
+	}
+
+	start, end, err := parseRange(args[0])
+	if err != nil {
+		return err
+	}
+	d.breaks.Remove(start)
+	d.breaks.UnwatchMemory(start, end)
+	return nil
+}
+
+func (d *debugger) info(args []string) error {
+	for _, addr := range d.breaks.List() {
+		fmt.Printf("breakpoint at 0x%04X\n", addr)
+	}
+	for _, w := range d.breaks.Watchpoints() {
+		fmt.Printf("watchpoint on %v of 0x%04X-0x%04X\n", w.Access, w.Start, w.End)
+	}
+	for x := 0; x < 16; x++ {
+		if access := d.breaks.WatchedRegister(x); access != 0 {
+			fmt.Printf("watchpoint on %v of V%X\n", access, x)
+		}
+	}
+	return nil
+}
+
+func (d *debugger) regs(args []string) error {
+	s := d.vm.State()
+	for x, v := range s.V {
+		fmt.Printf("V%X=%02X ", x, v)
+		if x == 7 || x == 15 {
+			fmt.Println()
+		}
+	}
+	fmt.Printf("I=%04X PC=%04X SP=%X DT=%02X ST=%02X\n", s.I, s.PC, s.SP, s.DT, s.ST)
+	return nil
+}
+
+func (d *debugger) stack(args []string) error {
+	s := d.vm.State()
+	if s.SP == 0 {
+		fmt.Println("the stack is empty")
+	}
+	for i := int(s.SP) - 1; i >= 0; i-- {
+		fmt.Printf("#%d 0x%04X\n", i, s.Stack[i])
+	}
+	return nil
+}
+
+func (d *debugger) mem(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errors.New("usage: mem <addr> [n]")
+	}
+	addr, err := parseAddr(args[0])
+	if err != nil {
+		return err
+	}
+	n := 64
+	if len(args) == 2 {
+		if n, err = strconv.Atoi(args[1]); err != nil {
+			return fmt.Errorf("invalid count: %v", args[1])
+		}
+	}
+
+	for row := 0; row < n; row += 16 {
+		fmt.Printf("%04X:", int(addr)+row)
+		for col := row; col < row+16 && col < n; col++ {
+			b, err := d.vm.Peek(uint16(int(addr) + col))
+			if err != nil {
+				fmt.Println()
+				return err
+			}
+			fmt.Printf(" %02X", b)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+func (d *debugger) disasm(args []string) error {
+	pc := d.vm.State().PC
+	addr := pc
+	// Start a few instructions before the program counter, instructions being 2 bytes long
+	if addr >= 8 {
+		addr -= 8
+	}
+	n := 10
+
+	if len(args) > 0 {
+		var err error
+		if addr, err = parseAddr(args[0]); err != nil {
+			return err
+		}
+	}
+	if len(args) > 1 {
+		var err error
+		if n, err = strconv.Atoi(args[1]); err != nil {
+			return fmt.Errorf("invalid count: %v", args[1])
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		text, size, err := d.vm.Disassemble(addr)
+		if err != nil {
+			return err
+		}
+
+		marker := "  "
+		if addr == pc {
+			marker = "=>"
+		}
+		fmt.Printf("%s 0x%04X  %s\n", marker, addr, text)
+		addr += uint16(size)
+	}
+	return nil
+}
+
+func (d *debugger) display(args []string) error {
+	// One character per colour of the palette
+	const chars = " #+@"
+	width, height := d.vm.Resolution()
+	colors := make(map[uint32]byte)
+	for i, c := range chip8.Palette {
+		colors[c] = chars[i]
+	}
+
+	fb := d.vm.Framebuffer()
+	fmt.Println("+" + strings.Repeat("-", width) + "+")
+	for y := 0; y < height; y++ {
+		line := make([]byte, width)
+		for x := range line {
+			line[x] = colors[fb[x+y*width]]
+		}
+		fmt.Println("|" + string(line) + "|")
+	}
+	fmt.Println("+" + strings.Repeat("-", width) + "+")
+	return nil
+}
+
+// stopped reports why the execution stopped.
+func (d *debugger) stopped(err error) {
+	var b *chip8.Break
+	if !errors.As(err, &b) {
+		fmt.Println("system errored:", err)
+		var e *chip8.MachineError
+		if errors.As(err, &e) {
+			fmt.Println(e.Registers())
+		}
+		return
+	}
+
+	fmt.Println(b)
+	d.where()
+}
+
+// where prints the next instruction.
+func (d *debugger) where() {
+	pc := d.vm.State().PC
+	text, _, err := d.vm.Disassemble(pc)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("=> 0x%04X  %s\n", pc, text)
+}
+
+// parseAddr parses a decimal address, or hexadecimal with the 0x prefix.
+func parseAddr(s string) (uint16, error) {
+	addr, err := strconv.ParseUint(s, 0, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid address: %v", s)
+	}
+	return uint16(addr), nil
+}
+
+// parseRange parses an address or a range of addresses separated by a dash.
+func parseRange(s string) (uint16, uint16, error) {
+	parts := strings.SplitN(s, "-", 2)
+	start, err := parseAddr(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(parts) == 1 {
+		return start, start, nil
+	}
+
+	end, err := parseAddr(parts[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	if end < start {
+		return 0, 0, fmt.Errorf("invalid range: %v", s)
+	}
+	return start, end, nil
+}
+
+// parseRegister parses a register name like v3 or VA.
+func parseRegister(s string) (int, bool) {
+	if len(s) != 2 || (s[0] != 'v' && s[0] != 'V') {
+		return 0, false
+	}
+	x, err := strconv.ParseUint(s[1:], 16, 4)
+	if err != nil {
+		return 0, false
+	}
+	return int(x), true
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/Bit-Doctor/emulation/pkg/chip8"
+)
+
+func main() {
+	var names []string
+	for _, p := range chip8.Platforms {
+		names = append(names, p.Name)
+	}
+
+	platformName := flag.String("platform", chip8.PlatformOcto.Name, "emulated platform, one of: "+strings.Join(names, ", "))
+	seed := flag.Int64("seed", 0, "seed of the random numbers")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %v [options] <file>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(-1)
+	}
+
+	platform, ok := chip8.PlatformByName(*platformName)
+	if !ok {
+		fmt.Fprintln(os.Stderr, "unknown platform: ", *platformName)
+		os.Exit(-1)
+	}
+
+	data, err := ioutil.ReadFile(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "cannot", err)
+		os.Exit(-1)
+	}
+
+	vm := chip8.New(platform, chip8.WithSeed(*seed))
+	if err := vm.LoadGame(data); err != nil {
+		fmt.Fprintln(os.Stderr, "cannot load game data: ", err)
+		os.Exit(-1)
+	}
+
+	d := &debugger{
+		vm:        vm,
+		breaks:    chip8.NewBreakpoints(),
+		interrupt: make(chan os.Signal, 1),
+	}
+	vm.SetBreakpoints(d.breaks)
+	signal.Notify(d.interrupt, os.Interrupt)
+
+	fmt.Printf("%s loaded on the %s platform, type help for the list of commands.\n", flag.Arg(0), platform.Name)
+	d.where()
+
+	var last string
+	scanner := bufio.NewScanner(os.Stdin)
+	for fmt.Print("(chip8) "); scanner.Scan(); fmt.Print("(chip8) ") {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			line = last // Like gdb an empty line repeats the last command
+		}
+		if line == "" {
+			continue
+		}
+		last = line
+
+		fields := strings.Fields(line)
+		cmd, ok := commands[fields[0]]
+		if !ok {
+			fmt.Println("unknown command:", fields[0])
+			continue
+		}
+		if cmd.run == nil {
+			return
+		}
+		if err := cmd.run(d, fields[1:]); err != nil {
+			fmt.Println(err)
+		}
+	}
+}
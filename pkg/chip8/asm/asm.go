@@ -0,0 +1,360 @@
+// Package asm compiles programs written in the Octo assembly language into Chip-8 ROMs.
+//
+// The language is described in https://github.com/JohnEarnest/Octo/blob/gh-pages/docs/Manual.md and the following
+// parts of it are supported:
+//   - the instructions of CHIP-8, SUPER-CHIP and XO-CHIP, with the names of Octo,
+//   - labels, forward references included, and calls written as the bare name of a label,
+//   - the directives :const, :alias, :macro, :calc, :byte, :org, :call, :unpack, :next, :breakpoint and :monitor,
+//   - the structured conditionals if ... then, if ... begin ... else ... end, with the comparisons <, >, <= and >=,
+//   - the loops loop ... again, and while inside loops,
+//   - sprites written as byte literals, in binary with 0b or in hexadecimal with 0x.
+//
+// Like Octo the program starts at the main label, a jump to main being inserted at 0x200 when main is elsewhere.
+package asm
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Bit-Doctor/emulation/pkg/chip8/symbols"
+)
+
+// start is the address where the ROM is loaded.
+const start = 0x200
+
+// Program is an assembled program.
+type Program struct {
+	// ROM is the program to load at 0x200.
+	ROM []byte
+	// Symbols maps the addresses to the labels and lines of the source code.
+	Symbols *symbols.Table
+}
+
+// Error is an error in the source code.
+type Error struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%v:%v: %v", e.File, e.Line, e.Msg)
+}
+
+// Assemble compiles Octo source code, name being the file recorded in the symbols and the errors.
+func Assemble(name string, src []byte) (*Program, error) {
+	p, err := assemble(name, src, false)
+	if err != nil {
+		return nil, err
+	}
+	if p.Symbols.Labels["main"] != start {
+		return assemble(name, src, true)
+	}
+	return p, nil
+}
+
+// assemble compiles the source code, starting with a jump to main if jumpToMain is set.
+func assemble(name string, src []byte, jumpToMain bool) (p *Program, err error) {
+	a := &assembler{
+		file:    name,
+		tokens:  tokenize(string(src)),
+		here:    start,
+		top:     start,
+		labels:  make(map[string]int),
+		consts:  make(map[string]float64),
+		aliases: make(map[string]int),
+		macros:  make(map[string]macro),
+		table:   symbols.New(),
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*Error)
+			if !ok {
+				panic(r)
+			}
+			p, err = nil, e
+		}
+	}()
+
+	if jumpToMain {
+		a.stmt = token{text: "main", line: 1}
+		a.resolve(a.stmt, fixAddress, 0)
+		a.write(0x10)
+		a.write(0x00)
+	}
+	for a.pos < len(a.tokens) {
+		a.statement()
+	}
+	a.finish()
+
+	return &Program{ROM: append([]byte(nil), a.rom[start:a.top]...), Symbols: a.table}, nil
+}
+
+// macro is a sequence of tokens to substitute, with the names of its arguments.
+type macro struct {
+	args []string
+	body []token
+}
+
+// The kinds of value written once a label is defined.
+const (
+	fixAddress = iota // The 12 lower bits of an instruction
+	fixLong           // The 16 bits following i := long
+	fixHigh           // The upper byte of a :unpack, below the nibble unless it is negative
+	fixLow            // The lower byte of a :unpack
+)
+
+// fixup is a reference to a label not defined yet.
+type fixup struct {
+	addr   int
+	kind   int
+	nibble int
+	tok    token
+}
+
+// control is an open if ... begin or loop.
+type control struct {
+	loop bool
+	// The address of the jump to patch with the end of the block for if, or of the start of the loop.
+	addr int
+	// The jumps exiting the loop.
+	breaks []int
+	tok    token
+}
+
+// The maximum number of nested macro expansions.
+const maxDepth = 64
+
+// The maximum number of bytes that can be assembled, the memory of XO-CHIP.
+const memorySize = 0x10000
+
+type assembler struct {
+	file   string
+	tokens []token
+	pos    int
+	// The first token of the statement being compiled.
+	stmt token
+
+	rom [memorySize]byte
+	// Whether the bytes of the ROM have been written.
+	used [memorySize]bool
+	here int
+	// The end of the ROM.
+	top int
+
+	labels   map[string]int
+	consts   map[string]float64
+	aliases  map[string]int
+	macros   map[string]macro
+	fixups   []fixup
+	controls []control
+	table    *symbols.Table
+}
+
+// fail stops the compilation with an error located at tok.
+func (a *assembler) fail(tok token, format string, args ...interface{}) {
+	panic(&Error{File: a.file, Line: tok.line, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (a *assembler) next() token {
+	if a.pos >= len(a.tokens) {
+		a.fail(a.last(), "unexpected end of file")
+	}
+	tok := a.tokens[a.pos]
+	a.pos++
+	return tok
+}
+
+func (a *assembler) peek() token {
+	if a.pos >= len(a.tokens) {
+		return token{line: a.last().line}
+	}
+	return a.tokens[a.pos]
+}
+
+func (a *assembler) last() token {
+	if len(a.tokens) == 0 {
+		return token{line: 1}
+	}
+	return a.tokens[len(a.tokens)-1]
+}
+
+func (a *assembler) expect(text string) {
+	if tok := a.next(); tok.text != text {
+		a.fail(tok, "expected %q, found %q", text, tok.text)
+	}
+}
+
+// write writes a byte of the ROM.
+func (a *assembler) write(b byte) {
+	if a.here < start || a.here >= memorySize {
+		a.fail(a.stmt, "address out of the program: 0x%X", a.here)
+	}
+	if a.used[a.here] {
+		a.fail(a.stmt, "overlapping data at 0x%03X", a.here)
+	}
+	a.rom[a.here] = b
+	a.used[a.here] = true
+	a.here++
+	if a.here > a.top {
+		a.top = a.here
+	}
+}
+
+// instruction writes an instruction, recording the source line.
+func (a *assembler) instruction(op int) {
+	a.table.Lines = append(a.table.Lines, symbols.Line{Address: uint16(a.here), File: a.file, Line: a.stmt.line})
+	a.write(byte(op >> 8))
+	a.write(byte(op))
+}
+
+// addressInstruction writes an instruction with the address named by tok in its 12 lower bits.
+func (a *assembler) addressInstruction(op int, tok token) {
+	addr, ok := a.resolve(tok, fixAddress, 0)
+	if ok && addr > 0xFFF {
+		a.fail(tok, "address out of the 12 bits range: 0x%X", addr)
+	}
+	a.instruction(op | addr&0xFFF)
+}
+
+// resolve return the value of a number, constant or label.
+// The labels not defined yet are recorded to be written later at the current address, and zero is returned.
+func (a *assembler) resolve(tok token, kind, nibble int) (int, bool) {
+	if v, ok := a.number(tok); ok {
+		return v, true
+	}
+	if addr, ok := a.labels[tok.text]; ok {
+		return addr, true
+	}
+	a.checkName(tok)
+	a.fixups = append(a.fixups, fixup{addr: a.here, kind: kind, nibble: nibble, tok: tok})
+	return 0, false
+}
+
+// number return the value of a literal or a constant.
+func (a *assembler) number(tok token) (int, bool) {
+	if n, ok := parseNumber(tok.text); ok {
+		return n, true
+	}
+	if v, ok := a.consts[tok.text]; ok {
+		return int(v), true
+	}
+	return 0, false
+}
+
+// value return the value of a literal or a constant in the range [min, max].
+func (a *assembler) value(tok token, min, max int) int {
+	v, ok := a.number(tok)
+	if !ok {
+		a.fail(tok, "expected a number, found %q", tok.text)
+	}
+	if v < min || v > max {
+		a.fail(tok, "value out of the range [%d, %d]: %d", min, max, v)
+	}
+	return v
+}
+
+// byteValue return the value of a byte, negative values being written in two's complement.
+func (a *assembler) byteValue(tok token) int {
+	return a.value(tok, -128, 255) & 0xFF
+}
+
+func (a *assembler) nibbleValue(tok token) int {
+	return a.value(tok, 0, 15)
+}
+
+// register return the register named by tok, v0 to vf or an alias.
+func (a *assembler) register(tok token) int {
+	x, ok := a.isRegister(tok)
+	if !ok {
+		a.fail(tok, "expected a register, found %q", tok.text)
+	}
+	return x
+}
+
+func (a *assembler) isRegister(tok token) (int, bool) {
+	if x, ok := parseRegister(tok.text); ok {
+		return x, true
+	}
+	x, ok := a.aliases[tok.text]
+	return x, ok
+}
+
+// reserved lists the words which cannot be used as names.
+var reserved = map[string]bool{
+	":": true, ":=": true, "+=": true, "-=": true, "|=": true, "&=": true, "^=": true, "=-": true, ">>=": true, "<<=": true,
+	"==": true, "!=": true, "<": true, ">": true, "<=": true, ">=": true, "-": true, "{": true, "}": true, ";": true,
+	"clear": true, "return": true, "bcd": true, "save": true, "load": true, "saveflags": true, "loadflags": true,
+	"sprite": true, "jump": true, "jump0": true, "native": true, "hires": true, "lores": true, "exit": true,
+	"scroll-down": true, "scroll-up": true, "scroll-left": true, "scroll-right": true, "plane": true, "audio": true,
+	"delay": true, "buzzer": true, "pitch": true, "i": true, "random": true, "key": true, "-key": true, "hex": true,
+	"bighex": true, "long": true, "if": true, "then": true, "begin": true, "else": true, "end": true, "loop": true,
+	"again": true, "while": true,
+}
+
+// checkName fails if tok cannot name a label, a constant, an alias or a macro.
+func (a *assembler) checkName(tok token) {
+	_, number := parseNumber(tok.text)
+	_, register := parseRegister(tok.text)
+	if number || register || reserved[tok.text] || tok.text[0] == ':' {
+		a.fail(tok, "invalid name: %q", tok.text)
+	}
+}
+
+// define checks that a new name is not already used.
+func (a *assembler) define(tok token) {
+	a.checkName(tok)
+	_, label := a.labels[tok.text]
+	_, constant := a.consts[tok.text]
+	_, alias := a.aliases[tok.text]
+	_, macro := a.macros[tok.text]
+	if label || constant || alias || macro {
+		a.fail(tok, "name already defined: %q", tok.text)
+	}
+}
+
+// finish writes the forward references and checks that the program is complete.
+func (a *assembler) finish() {
+	if len(a.controls) > 0 {
+		c := a.controls[len(a.controls)-1]
+		a.fail(c.tok, "%q without its end", c.tok.text)
+	}
+
+	for _, f := range a.fixups {
+		v, ok := a.labels[f.tok.text]
+		if !ok {
+			a.fail(f.tok, "undefined name: %q", f.tok.text)
+		}
+		switch f.kind {
+		case fixAddress:
+			if v > 0xFFF {
+				a.fail(f.tok, "address out of the 12 bits range: 0x%X", v)
+			}
+			a.rom[f.addr] |= byte(v >> 8)
+			a.rom[f.addr+1] = byte(v)
+		case fixLong:
+			a.rom[f.addr] = byte(v >> 8)
+			a.rom[f.addr+1] = byte(v)
+		case fixHigh:
+			if f.nibble < 0 {
+				a.rom[f.addr] = byte(v >> 8)
+				break
+			}
+			if v > 0xFFF {
+				a.fail(f.tok, "address out of the 12 bits range: 0x%X", v)
+			}
+			a.rom[f.addr] = byte(f.nibble<<4 | v>>8)
+		case fixLow:
+			a.rom[f.addr] = byte(v)
+		}
+	}
+
+	if _, ok := a.labels["main"]; !ok {
+		a.fail(a.last(), "the program has no main label")
+	}
+	for name, addr := range a.labels {
+		a.table.Labels[name] = uint16(addr)
+	}
+	sort.Slice(a.table.Lines, func(i, j int) bool { return a.table.Lines[i].Address < a.table.Lines[j].Address })
+}
@@ -0,0 +1,199 @@
+package asm
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Bit-Doctor/emulation/pkg/chip8"
+	"github.com/Bit-Doctor/emulation/pkg/chip8/disasm"
+	"github.com/Bit-Doctor/emulation/pkg/chip8/symbols"
+)
+
+func TestAssemble(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		want    []byte
+		wantErr bool
+	}{
+		{"instructions", ": main clear v0 := 5 v1 += v0 i := long main sprite v0 v1 15 ;",
+			[]byte{0x00, 0xE0, 0x60, 0x05, 0x81, 0x04, 0xF0, 0x00, 0x02, 0x00, 0xD0, 0x1F, 0x00, 0xEE}, false},
+		{"registers", ": main v3 -= 1 va := random 0x0F vb := key delay := vb i := hex v2 save v1 - v4 load v2",
+			[]byte{0x73, 0xFF, 0xCA, 0x0F, 0xFB, 0x0A, 0xFB, 0x15, 0xF2, 0x29, 0x51, 0x42, 0xF2, 0x65}, false},
+		{"forward reference", ": main jump done 0xFF : done sub : sub ;",
+			[]byte{0x12, 0x03, 0xFF, 0x22, 0x05, 0x00, 0xEE}, false},
+		{"jump to main", ": data 0b10000001 : main i := data",
+			[]byte{0x12, 0x03, 0x81, 0xA2, 0x02}, false},
+		{"const and alias", ":const speed 3 :alias x v4 : main x += speed",
+			[]byte{0x74, 0x03}, false},
+		{"calc", ": main :calc size { 2 * 3 + 1 } :byte { size } :byte { ( 2 * 3 ) + 1 } :byte { HERE - main }",
+			[]byte{0x08, 0x07, 0x02}, false},
+		{"macro", ":macro twice op { op op } : main twice clear",
+			[]byte{0x00, 0xE0, 0x00, 0xE0}, false},
+		{"if then", ": main if v1 == 2 then v0 := 1 if v1 key then v0 := 2",
+			[]byte{0x41, 0x02, 0x60, 0x01, 0xE1, 0xA1, 0x60, 0x02}, false},
+		{"if begin else end", ": main if v1 != v2 begin v0 := 1 else v0 := 2 end",
+			[]byte{0x91, 0x20, 0x12, 0x08, 0x60, 0x01, 0x12, 0x0A, 0x60, 0x02}, false},
+		{"comparison", ": main if v1 > 5 then clear",
+			[]byte{0x6F, 0x05, 0x8F, 0x15, 0x3F, 0x01, 0x00, 0xE0}, false},
+		{"loop", ": main loop v0 += 1 while v0 != 10 again",
+			[]byte{0x70, 0x01, 0x40, 0x0A, 0x12, 0x08, 0x12, 0x00}, false},
+		{"unpack", ": main :unpack 0xA data : data",
+			[]byte{0x60, 0xA2, 0x61, 0x04}, false},
+		{"comments", "# the start\n: main # of the program\nclear",
+			[]byte{0x00, 0xE0}, false},
+		{"no main", "clear", nil, true},
+		{"undefined label", ": main jump nowhere", nil, true},
+		{"redefined label", ": main : main", nil, true},
+		{"byte out of range", ": main v0 := 256", nil, true},
+		{"unknown operator", ": main v0 ~= v1", nil, true},
+		{"end without if", ": main end", nil, true},
+		{"if without end", ": main if v0 == 1 begin", nil, true},
+		{"while outside of loop", ": main while v0 == 1", nil, true},
+		{"overlap", ": main clear :org 0x200 clear", nil, true},
+		{"recursive macro", ":macro m { m } : main m", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Assemble("test.8o", []byte(tt.src))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Assemble() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !bytes.Equal(got.ROM, tt.want) {
+				t.Errorf("Assemble() = % X, want % X", got.ROM, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssemble_symbols(t *testing.T) {
+	src := ": main\n\tv0 := 1\n\n: wait\n\tif v0 > 3 then\n\tjump wait\n"
+	p, err := Assemble("game.8o", []byte(src))
+	if err != nil {
+		t.Fatalf("Assemble() error = %v", err)
+	}
+
+	if want := map[string]uint16{"main": 0x200, "wait": 0x202}; !reflect.DeepEqual(p.Symbols.Labels, want) {
+		t.Errorf("Assemble() labels = %v, want %v", p.Symbols.Labels, want)
+	}
+	want := []symbols.Line{
+		{Address: 0x200, File: "game.8o", Line: 2},
+		{Address: 0x202, File: "game.8o", Line: 5},
+		{Address: 0x204, File: "game.8o", Line: 5},
+		{Address: 0x206, File: "game.8o", Line: 5},
+		{Address: 0x208, File: "game.8o", Line: 6},
+	}
+	if !reflect.DeepEqual(p.Symbols.Lines, want) {
+		t.Errorf("Assemble() lines = %v, want %v", p.Symbols.Lines, want)
+	}
+}
+
+func TestAssemble_error(t *testing.T) {
+	_, err := Assemble("game.8o", []byte(": main\n\tclear\n\tv0 := v1 v2\n"))
+	e, ok := err.(*Error)
+	if !ok || e.Line != 3 || e.File != "game.8o" {
+		t.Errorf("Assemble() error = %v, want an error on game.8o line 3", err)
+	}
+}
+
+// The disassembled ROMs assemble back to the same bytes.
+func TestAssemble_disassembled(t *testing.T) {
+	roms, err := filepath.Glob("../../../roms/*.ch8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rom := range roms {
+		t.Run(filepath.Base(rom), func(t *testing.T) {
+			data, err := ioutil.ReadFile(rom)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var src bytes.Buffer
+			if err := disasm.Disassemble(data, 0x200, chip8.SCHIP11).Write(&src); err != nil {
+				t.Fatalf("Program.Write() error = %v", err)
+			}
+
+			got, err := Assemble(filepath.Base(rom), src.Bytes())
+			if err != nil {
+				t.Fatalf("Assemble() error = %v", err)
+			}
+			if !bytes.Equal(got.ROM, data) {
+				t.Errorf("Assemble() does not give back the ROM")
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	// The assembled program runs on the interpreter: it counts to 10 in a loop then stops
+	p, err := Assemble("count.8o", []byte(": main loop v0 += 1 while v0 != 10 again : done jump done"))
+	if err != nil {
+		t.Fatalf("Assemble() error = %v", err)
+	}
+
+	vm := chip8.New(chip8.PlatformOcto)
+	if err := vm.LoadGame(p.ROM); err != nil {
+		t.Fatalf("Chip8.LoadGame() error = %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		if err := vm.Step(); err != nil {
+			t.Fatalf("Chip8.Step() error = %v", err)
+		}
+	}
+	if state := vm.State(); state.V[0] != 10 || state.PC != p.Symbols.Labels["done"] {
+		t.Errorf("V0 = %v at 0x%04X, want 10 at the done label", state.V[0], state.PC)
+	}
+}
+
+func TestRun_comparisons(t *testing.T) {
+	// Each comparison sets a register when true, the ordered ones go through the borrow flag in vf
+	const src = `: main
+		if v0 == 5 then v1 := 1
+		if v0 != 5 then v2 := 1
+		if v0 > 5 then v3 := 1
+		if v0 < 5 then v4 := 1
+		if v0 >= 5 then v5 := 1
+		if v0 <= 5 then v6 := 1
+		if v0 > va then v7 := 1
+		if v0 < va then v8 := 1
+	: done jump done`
+	p, err := Assemble("compare.8o", []byte(src))
+	if err != nil {
+		t.Fatalf("Assemble() error = %v", err)
+	}
+
+	tests := []struct {
+		v0   byte
+		want [8]byte
+	}{
+		{v0: 3, want: [8]byte{0, 1, 0, 1, 0, 1, 0, 1}},
+		{v0: 5, want: [8]byte{1, 0, 0, 0, 1, 1, 0, 0}},
+		{v0: 10, want: [8]byte{0, 1, 1, 0, 1, 0, 1, 0}},
+	}
+	for _, tt := range tests {
+		vm := chip8.New(chip8.PlatformOcto)
+		if err := vm.LoadGame(p.ROM); err != nil {
+			t.Fatalf("Chip8.LoadGame() error = %v", err)
+		}
+		if err := vm.SetRegister(0, tt.v0); err != nil {
+			t.Fatal(err)
+		}
+		if err := vm.SetRegister(0xA, 5); err != nil {
+			t.Fatal(err)
+		}
+		for vm.State().PC != p.Symbols.Labels["done"] {
+			if err := vm.Step(); err != nil {
+				t.Fatalf("Chip8.Step() error = %v", err)
+			}
+		}
+		var got [8]byte
+		state := vm.State()
+		copy(got[:], state.V[1:9])
+		if got != tt.want {
+			t.Errorf("v0 = %d: V1 to V8 = %v, want %v", tt.v0, got, tt.want)
+		}
+	}
+}
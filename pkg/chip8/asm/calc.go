@@ -0,0 +1,115 @@
+package asm
+
+import "math"
+
+// The operators of the :calc expressions.
+
+var unaryOperators = map[string]func(float64) float64{
+	"-":     func(x float64) float64 { return -x },
+	"~":     func(x float64) float64 { return float64(^int(x)) },
+	"!":     func(x float64) float64 { return boolean(x == 0) },
+	"sin":   math.Sin,
+	"cos":   math.Cos,
+	"tan":   math.Tan,
+	"exp":   math.Exp,
+	"log":   math.Log,
+	"abs":   math.Abs,
+	"sqrt":  math.Sqrt,
+	"ceil":  math.Ceil,
+	"floor": math.Floor,
+	"sign": func(x float64) float64 {
+		switch {
+		case x > 0:
+			return 1
+		case x < 0:
+			return -1
+		}
+		return 0
+	},
+}
+
+var binaryOperators = map[string]func(float64, float64) float64{
+	"+":   func(x, y float64) float64 { return x + y },
+	"-":   func(x, y float64) float64 { return x - y },
+	"*":   func(x, y float64) float64 { return x * y },
+	"/":   func(x, y float64) float64 { return x / y },
+	"%":   math.Mod,
+	"pow": math.Pow,
+	"min": math.Min,
+	"max": math.Max,
+	"&":   func(x, y float64) float64 { return float64(int(x) & int(y)) },
+	"|":   func(x, y float64) float64 { return float64(int(x) | int(y)) },
+	"^":   func(x, y float64) float64 { return float64(int(x) ^ int(y)) },
+	"<<":  func(x, y float64) float64 { return float64(int(x) << uint(y)) },
+	">>":  func(x, y float64) float64 { return float64(int(x) >> uint(y)) },
+	"<":   func(x, y float64) float64 { return boolean(x < y) },
+	"<=":  func(x, y float64) float64 { return boolean(x <= y) },
+	">":   func(x, y float64) float64 { return boolean(x > y) },
+	">=":  func(x, y float64) float64 { return boolean(x >= y) },
+	"==":  func(x, y float64) float64 { return boolean(x == y) },
+	"!=":  func(x, y float64) float64 { return boolean(x != y) },
+}
+
+func boolean(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// calc evaluates the expression between braces following the current token.
+// Like in Octo the operators have no precedence and are evaluated from right to left: 2 * 3 + 1 is 8.
+func (a *assembler) calc() float64 {
+	a.expect("{")
+	v := a.expression()
+	a.expect("}")
+	return v
+}
+
+func (a *assembler) expression() float64 {
+	x := a.term()
+	if op, ok := binaryOperators[a.peek().text]; ok {
+		a.next()
+		return op(x, a.expression())
+	}
+	return x
+}
+
+func (a *assembler) term() float64 {
+	tok := a.next()
+	if tok.text == "(" {
+		v := a.expression()
+		a.expect(")")
+		return v
+	}
+	if op, ok := unaryOperators[tok.text]; ok {
+		return op(a.term())
+	}
+	if tok.text == "@" {
+		addr := int(a.term())
+		if addr < 0 || addr >= len(a.rom) {
+			a.fail(tok, "address out of memory: %v", addr)
+		}
+		return float64(a.rom[addr])
+	}
+
+	switch tok.text {
+	case "HERE":
+		return float64(a.here)
+	case "PI":
+		return math.Pi
+	case "E":
+		return math.E
+	}
+	if n, ok := parseNumber(tok.text); ok {
+		return float64(n)
+	}
+	if v, ok := a.consts[tok.text]; ok {
+		return v
+	}
+	if addr, ok := a.labels[tok.text]; ok {
+		return float64(addr)
+	}
+	a.fail(tok, "undefined name in expression: %q", tok.text)
+	return 0
+}
@@ -0,0 +1,69 @@
+package asm
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// token is a word of the source code.
+type token struct {
+	text string
+	line int
+	// The number of macro expansions which produced the token.
+	depth int
+}
+
+// tokenize splits the source code in words separated by spaces, leaving the comments out.
+func tokenize(src string) []token {
+	var tokens []token
+	for i, line := range strings.Split(src, "\n") {
+		if comment := strings.IndexByte(line, '#'); comment >= 0 {
+			line = line[:comment]
+		}
+		for _, word := range strings.FieldsFunc(line, unicode.IsSpace) {
+			tokens = append(tokens, token{text: word, line: i + 1})
+		}
+	}
+	return tokens
+}
+
+// parseNumber parses a decimal, hexadecimal (0x) or binary (0b) literal, optionally negative.
+func parseNumber(s string) (int, bool) {
+	negative := strings.HasPrefix(s, "-")
+	if negative {
+		s = s[1:]
+	}
+
+	base := 10
+	switch {
+	case strings.HasPrefix(s, "0x"), strings.HasPrefix(s, "0X"):
+		s, base = s[2:], 16
+	case strings.HasPrefix(s, "0b"), strings.HasPrefix(s, "0B"):
+		s, base = s[2:], 2
+	}
+	if s == "" || s[0] == '+' || s[0] == '-' {
+		return 0, false
+	}
+
+	n, err := strconv.ParseInt(s, base, 32)
+	if err != nil {
+		return 0, false
+	}
+	if negative {
+		n = -n
+	}
+	return int(n), true
+}
+
+// parseRegister parses the name of a register, v0 to vf.
+func parseRegister(s string) (int, bool) {
+	if len(s) != 2 || s[0] != 'v' && s[0] != 'V' {
+		return 0, false
+	}
+	x, err := strconv.ParseUint(s[1:], 16, 4)
+	if err != nil {
+		return 0, false
+	}
+	return int(x), true
+}
@@ -0,0 +1,452 @@
+package asm
+
+// The instructions without operand.
+var simpleInstructions = map[string]int{
+	"clear":        0x00E0,
+	"return":       0x00EE,
+	";":            0x00EE,
+	"scroll-right": 0x00FB,
+	"scroll-left":  0x00FC,
+	"exit":         0x00FD,
+	"lores":        0x00FE,
+	"hires":        0x00FF,
+	"audio":        0xF002,
+}
+
+// The instructions taking a single register, in their second nibble.
+var registerInstructions = map[string]int{
+	"bcd":       0xF033,
+	"saveflags": 0xF075,
+	"loadflags": 0xF085,
+}
+
+// The instructions setting a timer or the pitch from a register.
+var timerInstructions = map[string]int{
+	"delay":  0xF015,
+	"buzzer": 0xF018,
+	"pitch":  0xF03A,
+}
+
+// The arithmetic operators between two registers, in the last nibble of 8xyn.
+var registerOperators = map[string]int{
+	":=":  0x0,
+	"|=":  0x1,
+	"&=":  0x2,
+	"^=":  0x3,
+	"+=":  0x4,
+	"-=":  0x5,
+	">>=": 0x6,
+	"=-":  0x7,
+	"<<=": 0xE,
+}
+
+// statement compiles the next statement.
+func (a *assembler) statement() {
+	tok := a.next()
+	a.stmt = tok
+
+	if op, ok := simpleInstructions[tok.text]; ok {
+		a.instruction(op)
+		return
+	}
+	if op, ok := registerInstructions[tok.text]; ok {
+		a.instruction(op | a.register(a.next())<<8)
+		return
+	}
+	if op, ok := timerInstructions[tok.text]; ok {
+		a.expect(":=")
+		a.instruction(op | a.register(a.next())<<8)
+		return
+	}
+	if x, ok := a.isRegister(tok); ok {
+		a.registerStatement(x)
+		return
+	}
+	if m, ok := a.macros[tok.text]; ok {
+		a.expand(tok, m)
+		return
+	}
+
+	switch tok.text {
+	case ":":
+		name := a.next()
+		a.define(name)
+		a.labels[name.text] = a.here
+	case ":const":
+		name := a.next()
+		a.define(name)
+		a.consts[name.text] = float64(a.value(a.next(), -1<<31, 1<<31-1))
+	case ":calc":
+		name := a.next()
+		if _, ok := a.consts[name.text]; !ok {
+			a.define(name)
+		}
+		a.consts[name.text] = a.calc()
+	case ":alias":
+		name := a.next()
+		a.define(name)
+		a.aliases[name.text] = a.register(a.next())
+	case ":macro":
+		a.macroDefinition()
+	case ":byte":
+		if a.peek().text == "{" {
+			a.write(byte(int(a.calc())))
+		} else {
+			a.write(byte(a.byteValue(a.next())))
+		}
+	case ":org":
+		if a.peek().text == "{" {
+			a.here = int(a.calc())
+		} else {
+			a.here = a.value(a.next(), 0, memorySize-1)
+		}
+	case ":call":
+		a.addressInstruction(0x2000, a.next())
+	case ":unpack":
+		a.unpack()
+	case ":next":
+		name := a.next()
+		a.define(name)
+		a.labels[name.text] = a.here + 1
+	case ":breakpoint":
+		a.next()
+	case ":monitor":
+		a.next()
+		a.next()
+	case "jump":
+		a.addressInstruction(0x1000, a.next())
+	case "jump0":
+		a.addressInstruction(0xB000, a.next())
+	case "native":
+		a.addressInstruction(0x0000, a.next())
+	case "save", "load":
+		x := a.register(a.next())
+		if a.peek().text == "-" {
+			a.next()
+			y := a.register(a.next())
+			op := 0x5002
+			if tok.text == "load" {
+				op = 0x5003
+			}
+			a.instruction(op | x<<8 | y<<4)
+		} else if tok.text == "save" {
+			a.instruction(0xF055 | x<<8)
+		} else {
+			a.instruction(0xF065 | x<<8)
+		}
+	case "sprite":
+		x := a.register(a.next())
+		y := a.register(a.next())
+		a.instruction(0xD000 | x<<8 | y<<4 | a.nibbleValue(a.next()))
+	case "scroll-down":
+		a.instruction(0x00C0 | a.nibbleValue(a.next()))
+	case "scroll-up":
+		a.instruction(0x00D0 | a.nibbleValue(a.next()))
+	case "plane":
+		a.instruction(0xF001 | a.nibbleValue(a.next())<<8)
+	case "i":
+		a.indexStatement()
+	case "if":
+		a.ifStatement()
+	case "else":
+		c := a.popControl(tok, false)
+		a.controls = append(a.controls, control{addr: a.here, tok: tok})
+		a.instruction(0x1000)
+		a.patch(c.addr, a.here)
+	case "end":
+		c := a.popControl(tok, false)
+		a.patch(c.addr, a.here)
+	case "loop":
+		a.controls = append(a.controls, control{loop: true, addr: a.here, tok: tok})
+	case "while":
+		i := len(a.controls) - 1
+		for i >= 0 && !a.controls[i].loop {
+			i--
+		}
+		if i < 0 {
+			a.fail(tok, "while outside of a loop")
+		}
+		a.skip(a.parseCondition(), true)
+		a.controls[i].breaks = append(a.controls[i].breaks, a.here)
+		a.instruction(0x1000)
+	case "again":
+		c := a.popControl(tok, true)
+		if c.addr > 0xFFF {
+			a.fail(tok, "address out of the 12 bits range: 0x%X", c.addr)
+		}
+		a.instruction(0x1000 | c.addr)
+		for _, addr := range c.breaks {
+			a.patch(addr, a.here)
+		}
+	default:
+		if _, ok := a.number(tok); ok {
+			a.write(byte(a.byteValue(tok)))
+			return
+		}
+		// Any other name is a subroutine call
+		a.addressInstruction(0x2000, tok)
+	}
+}
+
+// registerStatement compiles an operation on the register x.
+func (a *assembler) registerStatement(x int) {
+	op := a.next()
+	rhs := a.next()
+
+	if y, ok := a.isRegister(rhs); ok {
+		n, ok := registerOperators[op.text]
+		if !ok {
+			a.fail(op, "unknown operator: %q", op.text)
+		}
+		a.instruction(0x8000 | x<<8 | y<<4 | n)
+		return
+	}
+
+	switch op.text {
+	case ":=":
+		switch rhs.text {
+		case "random":
+			a.instruction(0xC000 | x<<8 | a.byteValue(a.next()))
+		case "key":
+			a.instruction(0xF00A | x<<8)
+		case "delay":
+			a.instruction(0xF007 | x<<8)
+		default:
+			a.instruction(0x6000 | x<<8 | a.byteValue(rhs))
+		}
+	case "+=":
+		a.instruction(0x7000 | x<<8 | a.byteValue(rhs))
+	case "-=":
+		a.instruction(0x7000 | x<<8 | -a.value(rhs, -255, 255)&0xFF)
+	default:
+		a.fail(op, "operator %q needs a register", op.text)
+	}
+}
+
+// indexStatement compiles an operation on the index register.
+func (a *assembler) indexStatement() {
+	op := a.next()
+	rhs := a.next()
+	switch {
+	case op.text == "+=":
+		a.instruction(0xF01E | a.register(rhs)<<8)
+	case op.text != ":=":
+		a.fail(op, "unknown operator: %q", op.text)
+	case rhs.text == "hex":
+		a.instruction(0xF029 | a.register(a.next())<<8)
+	case rhs.text == "bighex":
+		a.instruction(0xF030 | a.register(a.next())<<8)
+	case rhs.text == "long":
+		a.instruction(0xF000)
+		tok := a.next()
+		addr, _ := a.resolve(tok, fixLong, 0)
+		if addr < 0 || addr >= memorySize {
+			a.fail(tok, "address out of memory: 0x%X", addr)
+		}
+		a.write(byte(addr >> 8))
+		a.write(byte(addr))
+	default:
+		a.addressInstruction(0xA000, rhs)
+	}
+}
+
+// unpack compiles :unpack, which loads an address in v0 and v1, below a nibble or with long.
+func (a *assembler) unpack() {
+	nibble := -1
+	if tok := a.next(); tok.text != "long" {
+		nibble = a.nibbleValue(tok)
+	}
+
+	tok := a.next()
+	addr, ok := a.resolve(tok, fixHigh, nibble)
+	if ok && nibble >= 0 && addr > 0xFFF {
+		a.fail(tok, "address out of the 12 bits range: 0x%X", addr)
+	}
+	if ok && nibble >= 0 {
+		addr |= nibble << 12
+	}
+	// The fixups are written on the immediate values, following the opcode bytes
+	if !ok {
+		a.fixups[len(a.fixups)-1].addr++
+	}
+	a.instruction(0x6000 | addr>>8&0xFF)
+
+	if !ok {
+		a.resolve(tok, fixLow, 0)
+		a.fixups[len(a.fixups)-1].addr++
+	}
+	a.instruction(0x6100 | addr&0xFF)
+}
+
+// The conditions negated, for the blocks skipping their body when the condition is false.
+var inverse = map[string]string{
+	"==":   "!=",
+	"!=":   "==",
+	"key":  "-key",
+	"-key": "key",
+	"<":    ">=",
+	">=":   "<",
+	">":    "<=",
+	"<=":   ">",
+}
+
+// condition is a comparison of a register with a register or a byte.
+type condition struct {
+	x   int
+	cmp token
+	rhs token
+}
+
+// parseCondition parses a condition, the right hand side being absent for key and -key.
+func (a *assembler) parseCondition() condition {
+	c := condition{x: a.register(a.next()), cmp: a.next()}
+	if _, ok := inverse[c.cmp.text]; !ok {
+		a.fail(c.cmp, "unknown comparison: %q", c.cmp.text)
+	}
+	if c.cmp.text != "key" && c.cmp.text != "-key" {
+		c.rhs = a.next()
+	}
+	return c
+}
+
+// skip compiles a condition into a skip of the next instruction when it is false, or when it is true if negated.
+// The comparisons are computed in vf.
+func (a *assembler) skip(c condition, negated bool) {
+	x, cmp := c.x, c.cmp.text
+	if negated {
+		cmp = inverse[cmp]
+	}
+
+	switch cmp {
+	case "key":
+		a.instruction(0xE0A1 | x<<8)
+		return
+	case "-key":
+		a.instruction(0xE09E | x<<8)
+		return
+	}
+
+	y, isRegister := a.isRegister(c.rhs)
+	switch cmp {
+	case "==":
+		if isRegister {
+			a.instruction(0x9000 | x<<8 | y<<4)
+		} else {
+			a.instruction(0x4000 | x<<8 | a.byteValue(c.rhs))
+		}
+		return
+	case "!=":
+		if isRegister {
+			a.instruction(0x5000 | x<<8 | y<<4)
+		} else {
+			a.instruction(0x3000 | x<<8 | a.byteValue(c.rhs))
+		}
+		return
+	}
+
+	// vf := rhs, then vf is the flag of rhs - vx or vx - rhs, set when there is no borrow
+	if isRegister {
+		a.instruction(0x8F00 | y<<4)
+	} else {
+		a.instruction(0x6F00 | a.byteValue(c.rhs))
+	}
+	switch cmp {
+	case ">":
+		a.instruction(0x8F05 | x<<4)
+		a.instruction(0x3F01)
+	case "<=":
+		a.instruction(0x8F05 | x<<4)
+		a.instruction(0x3F00)
+	case "<":
+		a.instruction(0x8F07 | x<<4)
+		a.instruction(0x3F01)
+	case ">=":
+		a.instruction(0x8F07 | x<<4)
+		a.instruction(0x3F00)
+	}
+}
+
+// ifStatement compiles if ... then and if ... begin.
+func (a *assembler) ifStatement() {
+	c := a.parseCondition()
+	switch tok := a.next(); tok.text {
+	case "then":
+		a.skip(c, false)
+	case "begin":
+		// The body is jumped over when the condition is false
+		a.skip(c, true)
+		a.controls = append(a.controls, control{addr: a.here, tok: a.stmt})
+		a.instruction(0x1000)
+	default:
+		a.fail(tok, "expected then or begin, found %q", tok.text)
+	}
+}
+
+// popControl ends the innermost block, which must be a loop or an if.
+func (a *assembler) popControl(tok token, loop bool) control {
+	if len(a.controls) == 0 {
+		a.fail(tok, "%q without its beginning", tok.text)
+	}
+	c := a.controls[len(a.controls)-1]
+	if c.loop != loop {
+		a.fail(tok, "%q closing %q", tok.text, c.tok.text)
+	}
+	a.controls = a.controls[:len(a.controls)-1]
+	return c
+}
+
+// patch sets the target of the jump at addr.
+func (a *assembler) patch(addr, target int) {
+	if target > 0xFFF {
+		a.fail(a.stmt, "address out of the 12 bits range: 0x%X", target)
+	}
+	a.rom[addr] = byte(0x10 | target>>8)
+	a.rom[addr+1] = byte(target)
+}
+
+// macroDefinition compiles :macro name arguments { body }.
+func (a *assembler) macroDefinition() {
+	name := a.next()
+	a.define(name)
+
+	var m macro
+	for tok := a.next(); tok.text != "{"; tok = a.next() {
+		a.checkName(tok)
+		m.args = append(m.args, tok.text)
+	}
+	for depth := 1; ; {
+		tok := a.next()
+		if tok.text == "{" {
+			depth++
+		} else if tok.text == "}" {
+			if depth--; depth == 0 {
+				break
+			}
+		}
+		m.body = append(m.body, tok)
+	}
+	a.macros[name.text] = m
+}
+
+// expand substitutes the arguments following a macro name in its body, and inserts it in place of the invocation.
+func (a *assembler) expand(tok token, m macro) {
+	if tok.depth >= maxDepth {
+		a.fail(tok, "too many nested macros expanding %q", tok.text)
+	}
+
+	args := make(map[string]string)
+	for _, arg := range m.args {
+		args[arg] = a.next().text
+	}
+
+	body := make([]token, len(m.body), len(m.body)+len(a.tokens)-a.pos)
+	for i, t := range m.body {
+		if v, ok := args[t.text]; ok {
+			t.text = v
+		}
+		t.depth = tok.depth + 1
+		body[i] = t
+	}
+	a.tokens = append(body, a.tokens[a.pos:]...)
+	a.pos = 0
+}
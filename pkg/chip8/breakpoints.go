@@ -0,0 +1,284 @@
+package chip8
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+)
+
+// Access is a kind of access to a register or to the memory.
+type Access byte
+
+const (
+	// Read is an access reading a value.
+	Read Access = 1 << iota
+	// Write is an access changing a value.
+	Write
+	// ReadWrite is any access.
+	ReadWrite = Read | Write
+)
+
+func (a Access) String() string {
+	switch a {
+	case Read:
+		return "read"
+	case Write:
+		return "write"
+	case ReadWrite:
+		return "read/write"
+	}
+	return "none"
+}
+
+// BreakKind is the kind of condition which stopped the execution.
+type BreakKind int
+
+const (
+	// Breakpoint stops before executing the instruction at an address.
+	Breakpoint BreakKind = iota
+	// MemoryWatchpoint stops before an instruction accessing a range of memory.
+	MemoryWatchpoint
+	// RegisterWatchpoint stops before an instruction accessing a V register.
+	RegisterWatchpoint
+)
+
+// Break is the error returned when the execution stops on a breakpoint or a watchpoint.
+// The instruction which triggered it is not executed yet, executing the machine again resumes with it.
+type Break struct {
+	Kind BreakKind
+	// The address and the opcode of the instruction.
+	PC     uint16
+	Opcode uint16
+	// The first watched address accessed by the instruction, for a memory watchpoint.
+	Addr uint16
+	// The register accessed by the instruction, for a register watchpoint.
+	Register int
+	// The access made by the instruction, for a watchpoint.
+	Access Access
+}
+
+func (b *Break) Error() string {
+	switch b.Kind {
+	case MemoryWatchpoint:
+		return fmt.Sprintf("memory watchpoint: %v of 0x%04X by 0x%04X at 0x%04X", b.Access, b.Addr, b.Opcode, b.PC)
+	case RegisterWatchpoint:
+		return fmt.Sprintf("register watchpoint: %v of V%X by 0x%04X at 0x%04X", b.Access, b.Register, b.Opcode, b.PC)
+	}
+	return fmt.Sprintf("breakpoint at 0x%04X", b.PC)
+}
+
+// Watchpoint stops the execution before an instruction accesses the memory from Start to End included.
+type Watchpoint struct {
+	Start  uint16
+	End    uint16
+	Access Access
+}
+
+// Breakpoints holds the conditions stopping the execution of a machine, see Chip8.SetBreakpoints.
+// The memory accessed by the instructions of the machine itself, which is checked by the breakpoints, is not watched.
+type Breakpoints struct {
+	pc        map[uint16]bool
+	memory    []Watchpoint
+	registers [16]Access
+}
+
+// NewBreakpoints return an empty set of breakpoints and watchpoints.
+func NewBreakpoints() *Breakpoints {
+	return &Breakpoints{pc: make(map[uint16]bool)}
+}
+
+// Add sets a breakpoint on the instruction at addr.
+func (b *Breakpoints) Add(addr uint16) {
+	b.pc[addr] = true
+}
+
+// Remove deletes the breakpoint on the instruction at addr.
+func (b *Breakpoints) Remove(addr uint16) {
+	delete(b.pc, addr)
+}
+
+// List return the addresses of the breakpoints in increasing order.
+func (b *Breakpoints) List() []uint16 {
+	var addrs []uint16
+	for addr := range b.pc {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i] < addrs[j] })
+	return addrs
+}
+
+// WatchMemory sets a watchpoint on the memory from start to end included.
+func (b *Breakpoints) WatchMemory(start, end uint16, access Access) {
+	b.memory = append(b.memory, Watchpoint{Start: start, End: end, Access: access})
+}
+
+// UnwatchMemory deletes the watchpoints on the memory from start to end included.
+func (b *Breakpoints) UnwatchMemory(start, end uint16) {
+	var kept []Watchpoint
+	for _, w := range b.memory {
+		if w.Start != start || w.End != end {
+			kept = append(kept, w)
+		}
+	}
+	b.memory = kept
+}
+
+// Watchpoints return the watchpoints on the memory.
+func (b *Breakpoints) Watchpoints() []Watchpoint {
+	return append([]Watchpoint(nil), b.memory...)
+}
+
+// WatchRegister sets a watchpoint on the register Vx, a zero access deletes it.
+func (b *Breakpoints) WatchRegister(x int, access Access) error {
+	if x < 0 || x >= len(b.registers) {
+		return fmt.Errorf("no such register: V%X", x)
+	}
+	b.registers[x] = access
+	return nil
+}
+
+// WatchedRegister return the access watched on the register Vx.
+func (b *Breakpoints) WatchedRegister(x int) Access {
+	if x < 0 || x >= len(b.registers) {
+		return 0
+	}
+	return b.registers[x]
+}
+
+// Clear deletes all the breakpoints and watchpoints.
+func (b *Breakpoints) Clear() {
+	*b = *NewBreakpoints()
+}
+
+// check return the first condition triggered by the instruction op about to be executed by the machine.
+func (b *Breakpoints) check(c *Chip8, op uint16) *Break {
+	if b.pc[c.pc] {
+		return &Break{Kind: Breakpoint, PC: c.pc, Opcode: op}
+	}
+
+	a := c.accesses(op)
+	for x := range b.registers {
+		for _, access := range []Access{Read, Write} {
+			mask := a.regReads
+			if access == Write {
+				mask = a.regWrites
+			}
+			if b.registers[x]&access != 0 && mask&(1<<x) != 0 {
+				return &Break{Kind: RegisterWatchpoint, PC: c.pc, Opcode: op, Register: x, Access: access}
+			}
+		}
+	}
+
+	if a.memLen == 0 {
+		return nil
+	}
+	for _, w := range b.memory {
+		if w.Access&a.memAccess == 0 {
+			continue
+		}
+		for k := 0; k < a.memLen; k++ {
+			addr := uint16(a.memStart + k) // The addresses wrap around like the instructions
+			if addr >= w.Start && addr <= w.End {
+				return &Break{Kind: MemoryWatchpoint, PC: c.pc, Opcode: op, Addr: addr, Access: a.memAccess}
+			}
+		}
+	}
+
+	return nil
+}
+
+// SetBreakpoints makes the machine stop on the given breakpoints and watchpoints, nil removes them.
+// GetNextFrame, Step and RunCycles return a *Break error when the execution stops.
+func (c *Chip8) SetBreakpoints(b *Breakpoints) {
+	c.breakpoints = b
+}
+
+// accesses describes the registers and memory an instruction accesses.
+type accesses struct {
+	// The registers read and written, one bit per register.
+	regReads  uint16
+	regWrites uint16
+	// The memory accessed from memStart to memStart+memLen excluded.
+	memStart  int
+	memLen    int
+	memAccess Access
+}
+
+// accesses return the registers and memory the instruction op would access if it was executed now.
+// It follows the decoding of decodeExecute, the instructions not accessing registers or memory are left out.
+func (c *Chip8) accesses(op uint16) accesses {
+	x := byte(op >> 8 & 0xF)
+	y := byte(op >> 4 & 0xF)
+	rx, ry := uint16(1)<<x, uint16(1)<<y
+	const vf = 1 << 0xF
+	regs := func(x byte) uint16 { return uint16(1)<<(x+1) - 1 } // V0 through Vx
+	i := int(c.i)
+
+	switch {
+	case op&0xF000 == 0x3000, op&0xF000 == 0x4000, op&0xF0FF == 0xE09E, op&0xF0FF == 0xE0A1:
+		return accesses{regReads: rx}
+	case op&0xF00F == 0x5000, op&0xF00F == 0x9000:
+		return accesses{regReads: rx | ry}
+	case op&0xF00F == 0x5002 && c.set >= XOCHIP:
+		var mask uint16
+		for _, r := range regsRange(x, y) {
+			mask |= 1 << r
+		}
+		return accesses{regReads: mask, memStart: i, memLen: len(regsRange(x, y)), memAccess: Write}
+	case op&0xF00F == 0x5003 && c.set >= XOCHIP:
+		var mask uint16
+		for _, r := range regsRange(x, y) {
+			mask |= 1 << r
+		}
+		return accesses{regWrites: mask, memStart: i, memLen: len(regsRange(x, y)), memAccess: Read}
+	case op&0xF000 == 0x6000, op&0xF000 == 0xC000:
+		return accesses{regWrites: rx}
+	case op&0xF000 == 0x7000:
+		return accesses{regReads: rx, regWrites: rx}
+	case op&0xF00F == 0x8000:
+		return accesses{regReads: ry, regWrites: rx}
+	case op&0xF00F == 0x8001, op&0xF00F == 0x8002, op&0xF00F == 0x8003:
+		a := accesses{regReads: rx | ry, regWrites: rx}
+		if c.quirks.ResetVF {
+			a.regWrites |= vf
+		}
+		return a
+	case op&0xF00F == 0x8004, op&0xF00F == 0x8005, op&0xF00F == 0x8007:
+		return accesses{regReads: rx | ry, regWrites: rx | vf}
+	case op&0xF00F == 0x8006, op&0xF00F == 0x800E:
+		if c.quirks.ShiftVy {
+			return accesses{regReads: ry, regWrites: rx | vf}
+		}
+		return accesses{regReads: rx, regWrites: rx | vf}
+	case op&0xF000 == 0xB000:
+		if c.quirks.JumpVx {
+			return accesses{regReads: rx}
+		}
+		return accesses{regReads: 1}
+	case op&0xF000 == 0xD000:
+		size := int(op & 0xF)
+		if size == 0 && c.set >= SCHIP10 {
+			size = 32
+		}
+		return accesses{regReads: rx | ry, regWrites: vf, memStart: i, memLen: size * bits.OnesCount8(c.planes&0x3), memAccess: Read}
+	case op == 0xF002 && c.set >= XOCHIP:
+		return accesses{memStart: i, memLen: len(c.pattern), memAccess: Read}
+	case op&0xF0FF == 0xF007, op&0xF0FF == 0xF00A:
+		return accesses{regWrites: rx}
+	case op&0xF0FF == 0xF015, op&0xF0FF == 0xF018, op&0xF0FF == 0xF01E, op&0xF0FF == 0xF029:
+		return accesses{regReads: rx}
+	case op&0xF0FF == 0xF030 && c.set >= SCHIP10, op&0xF0FF == 0xF03A && c.set >= XOCHIP:
+		return accesses{regReads: rx}
+	case op&0xF0FF == 0xF033:
+		return accesses{regReads: rx, memStart: i, memLen: 3, memAccess: Write}
+	case op&0xF0FF == 0xF055:
+		return accesses{regReads: regs(x), memStart: i, memLen: int(x) + 1, memAccess: Write}
+	case op&0xF0FF == 0xF065:
+		return accesses{regWrites: regs(x), memStart: i, memLen: int(x) + 1, memAccess: Read}
+	case op&0xF0FF == 0xF075 && c.set >= SCHIP10 && c.flagsAvailable(x):
+		return accesses{regReads: regs(x)}
+	case op&0xF0FF == 0xF085 && c.set >= SCHIP10 && c.flagsAvailable(x):
+		return accesses{regWrites: regs(x)}
+	}
+	return accesses{}
+}
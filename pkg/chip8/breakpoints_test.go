@@ -0,0 +1,187 @@
+package chip8
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// scoreProgram counts in v0 and writes its BCD representation at 0x300 forever.
+var scoreProgram = []byte{
+	0xA3, 0x00, // i := 0x300
+	0x70, 0x01, // v0 += 1
+	0xF0, 0x33, // bcd v0
+	0xF1, 0x65, // load v1
+	0x12, 0x02, // jump 0x202
+}
+
+func TestBreakpoints(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(b *Breakpoints)
+		want  *Break
+		// The number of instructions executed after resuming before the next stop.
+		next int
+	}{
+		{
+			name:  "breakpoint",
+			setup: func(b *Breakpoints) { b.Add(0x206) },
+			want:  &Break{Kind: Breakpoint, PC: 0x206, Opcode: 0xF165},
+			next:  3,
+		},
+		{
+			name:  "memory write",
+			setup: func(b *Breakpoints) { b.WatchMemory(0x302, 0x310, Write) },
+			want:  &Break{Kind: MemoryWatchpoint, PC: 0x204, Opcode: 0xF033, Addr: 0x302, Access: Write},
+			next:  3,
+		},
+		{
+			name:  "memory read",
+			setup: func(b *Breakpoints) { b.WatchMemory(0x2FF, 0x300, Read) },
+			want:  &Break{Kind: MemoryWatchpoint, PC: 0x206, Opcode: 0xF165, Addr: 0x300, Access: Read},
+			next:  3,
+		},
+		{
+			name:  "memory outside",
+			setup: func(b *Breakpoints) { b.WatchMemory(0x303, 0x310, ReadWrite) },
+		},
+		{
+			name:  "register read",
+			setup: func(b *Breakpoints) { b.WatchRegister(0, Read) },
+			want:  &Break{Kind: RegisterWatchpoint, PC: 0x202, Opcode: 0x7001, Register: 0, Access: Read},
+		},
+		{
+			name:  "register write",
+			setup: func(b *Breakpoints) { b.WatchRegister(1, Write) },
+			want:  &Break{Kind: RegisterWatchpoint, PC: 0x206, Opcode: 0xF165, Register: 1, Access: Write},
+			next:  3,
+		},
+		{
+			name: "removed",
+			setup: func(b *Breakpoints) {
+				b.Add(0x206)
+				b.WatchMemory(0x300, 0x302, ReadWrite)
+				b.WatchRegister(0, ReadWrite)
+				b.Remove(0x206)
+				b.UnwatchMemory(0x300, 0x302)
+				b.WatchRegister(0, 0)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(PlatformOcto)
+			if err := c.LoadGame(scoreProgram); err != nil {
+				t.Fatalf("chip8.LoadGame() error = %v", err)
+			}
+			b := NewBreakpoints()
+			tt.setup(b)
+			c.SetBreakpoints(b)
+
+			_, _, err := c.GetNextFrame([16]bool{})
+			var got *Break
+			if err != nil && !errors.As(err, &got) {
+				t.Fatalf("chip8.GetNextFrame() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("chip8.GetNextFrame() break = %+v, want %+v", got, tt.want)
+			}
+			if got == nil {
+				return
+			}
+			if c.pc != got.PC {
+				t.Errorf("c.pc = 0x%04X, want 0x%04X", c.pc, got.PC)
+			}
+
+			// Resuming executes the instruction then stops on the next hit.
+			if err := c.Step(); err != nil {
+				t.Fatalf("chip8.Step() after break error = %v", err)
+			}
+			if c.pc == got.PC {
+				t.Errorf("chip8.Step() after break did not execute the instruction")
+			}
+			n, err := c.RunCycles(10)
+			if !errors.As(err, &got) || n != tt.next {
+				t.Errorf("chip8.RunCycles() = %v, %v, want %v, a break", n, err, tt.next)
+			}
+		})
+	}
+}
+
+func TestBreakpoints_resumeAfterJump(t *testing.T) {
+	c := New(PlatformOcto)
+	if err := c.LoadGame(scoreProgram); err != nil {
+		t.Fatalf("chip8.LoadGame() error = %v", err)
+	}
+	b := NewBreakpoints()
+	b.Add(0x202)
+	c.SetBreakpoints(b)
+
+	if err := c.Step(); err != nil {
+		t.Fatalf("chip8.Step() error = %v", err)
+	}
+	if err := c.Step(); err == nil {
+		t.Fatalf("chip8.Step() error = nil, want a break")
+	}
+
+	// Moving the program counter away cancels the resume, the breakpoint hits again when it comes back.
+	if err := c.SetPC(0x200); err != nil {
+		t.Fatalf("chip8.SetPC() error = %v", err)
+	}
+	if err := c.Step(); err != nil {
+		t.Fatalf("chip8.Step() error = %v", err)
+	}
+	if err := c.Step(); err == nil {
+		t.Errorf("chip8.Step() error = nil, want a break")
+	}
+}
+
+func TestBreakpoints_resumeFrame(t *testing.T) {
+	c := New(PlatformOcto)
+	if err := c.LoadGame(scoreProgram); err != nil {
+		t.Fatalf("chip8.LoadGame() error = %v", err)
+	}
+	c.SetCyclesPerFrame(10)
+	c.dt = 5
+	b := NewBreakpoints()
+	b.Add(0x206)
+	c.SetBreakpoints(b)
+
+	if _, _, err := c.GetNextFrame([16]bool{}); err == nil {
+		t.Fatalf("chip8.GetNextFrame() error = nil, want a break")
+	}
+
+	// The next frame runs the 7 cycles left without ticking the timers again.
+	c.SetBreakpoints(nil)
+	if _, _, err := c.GetNextFrame([16]bool{}); err != nil {
+		t.Fatalf("chip8.GetNextFrame() error = %v", err)
+	}
+	if c.pc != 0x204 || c.dt != 4 {
+		t.Errorf("c.pc, c.dt = 0x%04X, %d after resuming the frame, want 0x0204, 4", c.pc, c.dt)
+	}
+	if _, _, err := c.GetNextFrame([16]bool{}); err != nil {
+		t.Fatalf("chip8.GetNextFrame() error = %v", err)
+	}
+	if c.pc != 0x208 || c.dt != 3 {
+		t.Errorf("c.pc, c.dt = 0x%04X, %d after the next frame, want 0x0208, 3", c.pc, c.dt)
+	}
+}
+
+func TestBreakpoints_List(t *testing.T) {
+	b := NewBreakpoints()
+	b.Add(0x300)
+	b.Add(0x200)
+	b.Add(0x250)
+	b.Remove(0x250)
+	if got, want := b.List(), []uint16{0x200, 0x300}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Breakpoints.List() = %v, want %v", got, want)
+	}
+	if err := b.WatchRegister(16, Read); err == nil {
+		t.Errorf("Breakpoints.WatchRegister(16) error = nil, want an error")
+	}
+
+	b.Clear()
+	if got := b.List(); len(got) != 0 {
+		t.Errorf("Breakpoints.List() after Clear() = %v, want none", got)
+	}
+}
@@ -0,0 +1,261 @@
+// Package cfg builds the control-flow graph of Chip-8 programs: their basic blocks, the edges between them and the
+// call graph of their subroutines.
+//
+// The code is found by the recursive descent of the disasm package, so the targets of jump0 are unknown and the code
+// only reached through it is left out. The bytes never reached which still look like code are reported as unreachable.
+package cfg
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Bit-Doctor/emulation/pkg/chip8"
+	"github.com/Bit-Doctor/emulation/pkg/chip8/disasm"
+)
+
+// EdgeKind is the way control passes from a block to another.
+type EdgeKind int
+
+const (
+	// Fallthrough continues with the next block in memory, or after the call ending the block once it returns.
+	Fallthrough EdgeKind = iota
+	// Jump is a jump to the start of the block.
+	Jump
+	// Skip is taken when the skip ending the block skips the next instruction.
+	Skip
+	// Call is a call of the subroutine starting at the block.
+	Call
+)
+
+func (k EdgeKind) String() string {
+	switch k {
+	case Fallthrough:
+		return "fallthrough"
+	case Jump:
+		return "jump"
+	case Skip:
+		return "skip"
+	case Call:
+		return "call"
+	}
+	return "unknown"
+}
+
+// Block is a basic block, a sequence of instructions always run from the first to the last.
+type Block struct {
+	// Start is the address of the first instruction.
+	Start uint16
+	// End is the address following the last instruction.
+	End          uint16
+	Instructions []disasm.Instruction
+}
+
+// last return the last instruction of the block.
+func (b *Block) last() disasm.Instruction {
+	return b.Instructions[len(b.Instructions)-1]
+}
+
+// Edge is a transfer of control between two blocks.
+type Edge struct {
+	From uint16
+	To   uint16
+	Kind EdgeKind
+}
+
+// Function is a subroutine, or the main program.
+type Function struct {
+	// Entry is the start of the first block.
+	Entry uint16
+	// Blocks are the starts of the blocks reached from the entry without calling a subroutine, sorted.
+	Blocks []uint16
+	// Calls are the entries of the subroutines called, sorted.
+	Calls []uint16
+}
+
+// Range is a range of addresses, End excluded.
+type Range struct {
+	Start uint16
+	End   uint16
+}
+
+// Graph is the control-flow graph of a program.
+type Graph struct {
+	Entry uint16
+	// Blocks are sorted by address.
+	Blocks []*Block
+	// Edges are sorted by origin and destination.
+	Edges []Edge
+	// Functions are sorted by entry, the main program being the first one.
+	Functions []*Function
+	// Unreachable are the ranges of bytes never reached from the entry which decode as instructions ending with a
+	// jump, return or exit. This is a guess: they are either dead code or data looking like code.
+	Unreachable []Range
+
+	program *disasm.Program
+	blocks  map[uint16]*Block
+}
+
+// Build builds the control-flow graph of a program loaded at origin, where it starts running.
+func Build(rom []byte, origin uint16, set chip8.InstructionSet) *Graph {
+	p := disasm.Disassemble(rom, origin, set)
+	g := &Graph{Entry: origin, program: p, blocks: make(map[uint16]*Block)}
+
+	instructions := p.Instructions()
+	at := make(map[uint16]bool, len(instructions))
+	for _, ins := range instructions {
+		at[ins.Address] = true
+	}
+
+	// A block starts at the entry, at the targets of the jumps and calls, and after the instructions transferring the
+	// control elsewhere.
+	leaders := map[uint16]bool{origin: true}
+	for _, ins := range instructions {
+		if ins.Flow == disasm.Sequential {
+			continue
+		}
+		leaders[ins.Address+uint16(ins.Size)] = true
+		for _, next := range ins.Successors() {
+			leaders[next] = true
+		}
+	}
+
+	var block *Block
+	for _, ins := range instructions {
+		if block == nil || leaders[ins.Address] || block.End != ins.Address {
+			block = &Block{Start: ins.Address}
+			g.Blocks = append(g.Blocks, block)
+			g.blocks[block.Start] = block
+		}
+		block.Instructions = append(block.Instructions, ins)
+		block.End = ins.Address + uint16(ins.Size)
+	}
+
+	for _, b := range g.Blocks {
+		last := b.last()
+		switch last.Flow {
+		case disasm.Sequential:
+			g.edge(b.Start, b.End, Fallthrough, at)
+		case disasm.Jump:
+			g.edge(b.Start, last.Target, Jump, at)
+		case disasm.Skip:
+			next := last.Successors()
+			g.edge(b.Start, next[0], Fallthrough, at)
+			g.edge(b.Start, next[1], Skip, at)
+		case disasm.Call:
+			g.edge(b.Start, last.Target, Call, at)
+			g.edge(b.Start, b.End, Fallthrough, at)
+		}
+	}
+	sort.Slice(g.Edges, func(i, j int) bool {
+		a, b := g.Edges[i], g.Edges[j]
+		return a.From < b.From || a.From == b.From && (a.To < b.To || a.To == b.To && a.Kind < b.Kind)
+	})
+
+	g.functions()
+	g.unreachable(rom, set)
+	return g
+}
+
+// edge adds an edge to the instruction at the address to, if there is one.
+func (g *Graph) edge(from, to uint16, kind EdgeKind, at map[uint16]bool) {
+	if at[to] {
+		g.Edges = append(g.Edges, Edge{From: from, To: to, Kind: kind})
+	}
+}
+
+// functions finds the blocks of the main program and of the subroutines.
+func (g *Graph) functions() {
+	entries := map[uint16]bool{g.Entry: true}
+	successors := make(map[uint16][]uint16)
+	calls := make(map[uint16][]uint16)
+	for _, e := range g.Edges {
+		if e.Kind == Call {
+			entries[e.To] = true
+			calls[e.From] = append(calls[e.From], e.To)
+		} else {
+			successors[e.From] = append(successors[e.From], e.To)
+		}
+	}
+
+	for entry := range entries {
+		f := &Function{Entry: entry}
+		seen := map[uint16]bool{entry: true}
+		called := make(map[uint16]bool)
+		for todo := []uint16{entry}; len(todo) > 0; {
+			b := todo[len(todo)-1]
+			todo = todo[:len(todo)-1]
+			f.Blocks = append(f.Blocks, b)
+			for _, callee := range calls[b] {
+				if !called[callee] {
+					called[callee] = true
+					f.Calls = append(f.Calls, callee)
+				}
+			}
+			for _, next := range successors[b] {
+				if !seen[next] {
+					seen[next] = true
+					todo = append(todo, next)
+				}
+			}
+		}
+		sortAddresses(f.Blocks)
+		sortAddresses(f.Calls)
+		g.Functions = append(g.Functions, f)
+	}
+
+	sort.Slice(g.Functions, func(i, j int) bool {
+		a, b := g.Functions[i], g.Functions[j]
+		return a.Entry == g.Entry || b.Entry != g.Entry && a.Entry < b.Entry
+	})
+}
+
+// unreachable finds the code never reached, in the bytes left out by the disassembly.
+func (g *Graph) unreachable(rom []byte, set chip8.InstructionSet) {
+	for offset := 0; offset < len(rom); {
+		if end, ok := g.deadCode(rom, offset, set); ok {
+			g.Unreachable = append(g.Unreachable, Range{Start: g.Entry + uint16(offset), End: g.Entry + uint16(end)})
+			offset = end
+		} else {
+			offset++
+		}
+	}
+}
+
+// deadCode tells whether the bytes at offset, not reached, decode as at least two instructions ending with a jump,
+// return or exit, and return the offset following them.
+func (g *Graph) deadCode(rom []byte, offset int, set chip8.InstructionSet) (int, bool) {
+	addr := g.Entry + uint16(offset)
+	// The data loaded in the index register is not code
+	if strings.HasPrefix(g.program.Label(addr), "data-") {
+		return 0, false
+	}
+
+	for count := 1; offset < len(rom); count++ {
+		addr := g.Entry + uint16(offset)
+		ins, err := disasm.Decode(rom[offset:], addr, set)
+		if err != nil || g.program.IsCode(addr) || g.program.IsCode(addr+uint16(ins.Size-1)) {
+			return 0, false
+		}
+		offset += ins.Size
+		switch ins.Flow {
+		case disasm.Jump, disasm.Return, disasm.Exit:
+			return offset, count >= 2
+		}
+	}
+	return 0, false
+}
+
+// Block return the block starting at addr.
+func (g *Graph) Block(addr uint16) (*Block, bool) {
+	b, ok := g.blocks[addr]
+	return b, ok
+}
+
+// Label return the label generated by the disassembler for addr, or an empty string if there is none.
+func (g *Graph) Label(addr uint16) string {
+	return g.program.Label(addr)
+}
+
+func sortAddresses(a []uint16) {
+	sort.Slice(a, func(i, j int) bool { return a[i] < a[j] })
+}
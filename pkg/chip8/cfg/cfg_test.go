@@ -0,0 +1,102 @@
+package cfg
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Bit-Doctor/emulation/pkg/chip8"
+)
+
+var rom = []byte{
+	0x60, 0x00, // 0x200 main: v0 := 0
+	0x22, 0x0E, // 0x202 sub-20e
+	0x30, 0x05, // 0x204 if v0 != 5 then
+	0x12, 0x00, // 0x206   jump main
+	0x12, 0x08, // 0x208 jump 0x208
+	0x61, 0x01, // 0x20A unreachable: v1 := 1
+	0x00, 0xEE, // 0x20C unreachable: return
+	0x70, 0x01, // 0x20E sub-20e: v0 += 1
+	0x00, 0xEE, // 0x210 return
+}
+
+func TestBuild(t *testing.T) {
+	g := Build(rom, 0x200, chip8.CHIP8)
+
+	var starts []uint16
+	for _, b := range g.Blocks {
+		starts = append(starts, b.Start)
+	}
+	if want := []uint16{0x200, 0x204, 0x206, 0x208, 0x20E}; !reflect.DeepEqual(starts, want) {
+		t.Errorf("Build() blocks = %X, want %X", starts, want)
+	}
+
+	wantEdges := []Edge{
+		{0x200, 0x204, Fallthrough},
+		{0x200, 0x20E, Call},
+		{0x204, 0x206, Fallthrough},
+		{0x204, 0x208, Skip},
+		{0x206, 0x200, Jump},
+		{0x208, 0x208, Jump},
+	}
+	if !reflect.DeepEqual(g.Edges, wantEdges) {
+		t.Errorf("Build() edges = %v, want %v", g.Edges, wantEdges)
+	}
+
+	wantFunctions := []*Function{
+		{Entry: 0x200, Blocks: []uint16{0x200, 0x204, 0x206, 0x208}, Calls: []uint16{0x20E}},
+		{Entry: 0x20E, Blocks: []uint16{0x20E}},
+	}
+	if !reflect.DeepEqual(g.Functions, wantFunctions) {
+		t.Errorf("Build() functions = %v, want %v", g.Functions, wantFunctions)
+	}
+
+	if want := []Range{{0x20A, 0x20E}}; !reflect.DeepEqual(g.Unreachable, want) {
+		t.Errorf("Build() unreachable = %v, want %v", g.Unreachable, want)
+	}
+}
+
+func TestGraph_WriteDOT(t *testing.T) {
+	g := Build(rom, 0x200, chip8.CHIP8)
+
+	var buf bytes.Buffer
+	if err := g.WriteDOT(&buf); err != nil {
+		t.Fatalf("Graph.WriteDOT() error = %v", err)
+	}
+	for _, want := range []string{
+		`"200" [label="main:\l200  v0 := 0x00\l202  sub-20e\l"];`,
+		`"204" -> "208" [color=darkgreen label="skip"];`,
+		`"20A" [label="unreachable 20A-20D" style=filled fillcolor=lightgrey];`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Graph.WriteDOT() = %v, want a line %v", buf.String(), want)
+		}
+	}
+
+	buf.Reset()
+	if err := g.WriteCallGraphDOT(&buf); err != nil {
+		t.Fatalf("Graph.WriteCallGraphDOT() error = %v", err)
+	}
+	if want := `"200" -> "20E";`; !strings.Contains(buf.String(), want) {
+		t.Errorf("Graph.WriteCallGraphDOT() = %v, want a line %v", buf.String(), want)
+	}
+}
+
+func TestGraph_WriteJSON(t *testing.T) {
+	g := Build(rom, 0x200, chip8.CHIP8)
+
+	var buf bytes.Buffer
+	if err := g.WriteJSON(&buf); err != nil {
+		t.Fatalf("Graph.WriteJSON() error = %v", err)
+	}
+
+	var doc jsonGraph
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("Graph.WriteJSON() is not valid JSON: %v", err)
+	}
+	if len(doc.Blocks) != 5 || len(doc.Edges) != 6 || doc.Functions[1].Name != "sub-20e" || doc.Edges[1].Kind != "call" {
+		t.Errorf("Graph.WriteJSON() = %v", buf.String())
+	}
+}
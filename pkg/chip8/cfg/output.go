@@ -0,0 +1,151 @@
+package cfg
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// name return the label of a block or function, or its address.
+func (g *Graph) name(addr uint16) string {
+	if label := g.Label(addr); label != "" {
+		return label
+	}
+	return fmt.Sprintf("0x%03X", addr)
+}
+
+// quote quotes a string for Graphviz.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// The styles of the edges in the DOT graphs.
+var edgeStyles = map[EdgeKind]string{
+	Fallthrough: "",
+	Jump:        ` [color=blue]`,
+	Skip:        ` [color=darkgreen label="skip"]`,
+	Call:        ` [style=dashed label="call"]`,
+}
+
+// WriteDOT writes the graph of the blocks in the Graphviz DOT language, the unreachable code in grey.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph cfg {")
+	fmt.Fprintln(bw, "\tnode [shape=box fontname=monospace];")
+
+	for _, b := range g.Blocks {
+		var label strings.Builder
+		if name := g.Label(b.Start); name != "" {
+			label.WriteString(name + ":\\l")
+		}
+		for _, ins := range b.Instructions {
+			label.WriteString(fmt.Sprintf("%03X  %v\\l", ins.Address, ins.Mnemonic(g.Label)))
+		}
+		// The label is quoted by hand to keep the line breaks \l
+		fmt.Fprintf(bw, "\t\"%03X\" [label=\"%v\"];\n", b.Start, strings.Replace(label.String(), `"`, `\"`, -1))
+	}
+	for _, r := range g.Unreachable {
+		fmt.Fprintf(bw, "\t\"%03X\" [label=%v style=filled fillcolor=lightgrey];\n", r.Start, quote(fmt.Sprintf("unreachable %03X-%03X", r.Start, r.End-1)))
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(bw, "\t\"%03X\" -> \"%03X\"%v;\n", e.From, e.To, edgeStyles[e.Kind])
+	}
+
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// WriteCallGraphDOT writes the graph of the calls between the functions in the Graphviz DOT language.
+func (g *Graph) WriteCallGraphDOT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph calls {")
+	fmt.Fprintln(bw, "\tnode [shape=box fontname=monospace];")
+	for _, f := range g.Functions {
+		fmt.Fprintf(bw, "\t\"%03X\" [label=%v];\n", f.Entry, quote(g.name(f.Entry)))
+	}
+	for _, f := range g.Functions {
+		for _, callee := range f.Calls {
+			fmt.Fprintf(bw, "\t\"%03X\" -> \"%03X\";\n", f.Entry, callee)
+		}
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// The JSON document written by WriteJSON.
+
+type jsonGraph struct {
+	Entry       uint16         `json:"entry"`
+	Blocks      []jsonBlock    `json:"blocks"`
+	Edges       []jsonEdge     `json:"edges"`
+	Functions   []jsonFunction `json:"functions"`
+	Unreachable []jsonRange    `json:"unreachable"`
+}
+
+type jsonBlock struct {
+	Start        uint16            `json:"start"`
+	End          uint16            `json:"end"`
+	Label        string            `json:"label,omitempty"`
+	Instructions []jsonInstruction `json:"instructions"`
+}
+
+type jsonInstruction struct {
+	Address  uint16 `json:"address"`
+	Opcode   uint16 `json:"opcode"`
+	Mnemonic string `json:"mnemonic"`
+}
+
+type jsonEdge struct {
+	From uint16 `json:"from"`
+	To   uint16 `json:"to"`
+	Kind string `json:"kind"`
+}
+
+type jsonFunction struct {
+	Entry  uint16   `json:"entry"`
+	Name   string   `json:"name"`
+	Blocks []uint16 `json:"blocks"`
+	Calls  []uint16 `json:"calls"`
+}
+
+type jsonRange struct {
+	Start uint16 `json:"start"`
+	End   uint16 `json:"end"`
+}
+
+// WriteJSON writes the graph as a JSON document, the addresses being in decimal.
+func (g *Graph) WriteJSON(w io.Writer) error {
+	doc := jsonGraph{
+		Entry:       g.Entry,
+		Blocks:      []jsonBlock{},
+		Edges:       []jsonEdge{},
+		Functions:   []jsonFunction{},
+		Unreachable: []jsonRange{},
+	}
+	for _, b := range g.Blocks {
+		block := jsonBlock{Start: b.Start, End: b.End, Label: g.Label(b.Start)}
+		for _, ins := range b.Instructions {
+			block.Instructions = append(block.Instructions, jsonInstruction{ins.Address, ins.Opcode, ins.Mnemonic(g.Label)})
+		}
+		doc.Blocks = append(doc.Blocks, block)
+	}
+	for _, e := range g.Edges {
+		doc.Edges = append(doc.Edges, jsonEdge{e.From, e.To, e.Kind.String()})
+	}
+	for _, f := range g.Functions {
+		calls := f.Calls
+		if calls == nil {
+			calls = []uint16{}
+		}
+		doc.Functions = append(doc.Functions, jsonFunction{f.Entry, g.name(f.Entry), f.Blocks, calls})
+	}
+	for _, r := range g.Unreachable {
+		doc.Unreachable = append(doc.Unreachable, jsonRange{r.Start, r.End})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
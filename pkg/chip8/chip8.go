@@ -3,7 +3,6 @@ package chip8
 import (
 	"errors"
 	"math"
-	"math/rand"
 	"time"
 )
 
@@ -11,25 +10,70 @@ const (
 	// FramePerSecond is the number of Frame update in Hertz
 	FramePerSecond = 60.0
 	// CyclePerSecond is the number of CPU cycle in Hertz
+	//
+	// Deprecated: the speed depends on the platform, use Chip8.CyclesPerFrame and Chip8.SetCyclesPerFrame.
 	CyclePerSecond = 600.0
-	//CyclePerFrame is the number of CPU cycle per frame
+	// CyclePerFrame is the number of CPU cycle per frame
+	//
+	// Deprecated: the speed depends on the platform, use Chip8.CyclesPerFrame and Chip8.SetCyclesPerFrame.
 	CyclePerFrame = CyclePerSecond / FramePerSecond
 	// SamplingRate is the number of audio sample in Hertz
 	SamplingRate = 44100.0
 	// SamplePerFrame is the number of audio sample per frame
 	SamplePerFrame = SamplingRate / FramePerSecond
-	// DisplayWidth is the number of pixels in a row
-	DisplayWidth = 64
-	// DisplayHeight is the number of pixels in a column
-	DisplayHeight = 32
+	// DisplayWidth is the number of pixels in a row in high resolution mode
+	DisplayWidth = 128
+	// DisplayHeight is the number of pixels in a column in high resolution mode
+	DisplayHeight = 64
+	// LowResWidth is the number of pixels in a row in low resolution mode
+	LowResWidth = 64
+	// LowResHeight is the number of pixels in a column in low resolution mode
+	LowResHeight = 32
+	// MemorySize is the number of bytes of memory of the XO-CHIP
+	MemorySize = 0x10000
+)
+
+const (
+	// fontAddress is the memory location of the 8x5 hexadecimal digits sprites.
+	fontAddress = 0x000
+	// bigFontAddress is the memory location of the 8x10 digits sprites.
+	bigFontAddress = 0x050
 )
 
+// The XO-CHIP plays a 1-bit audio pattern while the sound timer is active.
+// Until a program loads its own pattern a square wave is played, about 500Hz at the default pitch.
+var defaultPattern = [16]byte{0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0}
+
+// Palette is the colour of a pixel depending on the XO-CHIP planes it is set on.
+// The first colour is the background, the second is used for the first plane, the third for the second plane and the fourth for pixels set on both planes.
+var Palette = [4]uint32{0x00171F, 0xF2F4F3, 0x007EA7, 0x80CED7}
+
+// Quirks toggles the behaviours on which the CHIP-8 interpreters disagree.
+// The zero value matches the behaviour of most modern interpreters.
+type Quirks struct {
+	// ShiftVy makes 8xy6 and 8xyE shift Vy and store the result in Vx, like the COSMAC VIP, instead of shifting Vx in place.
+	ShiftVy bool
+	// IncrementI makes Fx55 and Fx65 leave I pointing after the last register accessed, like the COSMAC VIP.
+	IncrementI bool
+	// JumpVx makes Bnnn jump to nnn + Vx, where x is the highest nibble of nnn, like the CHIP-48 and SUPER-CHIP.
+	JumpVx bool
+	// ResetVF makes 8xy1, 8xy2 and 8xy3 reset VF to 0, like the COSMAC VIP.
+	ResetVF bool
+	// DisplayWait makes Dxyn wait for the next frame after drawing, like the COSMAC VIP waiting for the vertical blank interrupt.
+	DisplayWait bool
+	// ClipSprites makes the parts of a sprite outside of the display disappear instead of wrapping around to the opposite side.
+	ClipSprites bool
+}
+
 // Chip8 is based on Cowgod's Chip-8 Technical Reference v1.0
 // Available at http://devernay.free.fr/hacks/chip8/C8TECH10.HTM#1.0
 type Chip8 struct {
-	// The 4096 bytes of memory.
+	// The 4096 bytes of memory, extended to 65536 bytes by the XO-CHIP.
 	//
 	// Memory Map:
+	// +---------------+= 0xFFFF (65535) End of XO-CHIP RAM
+	// |               |
+	// |               |
 	// +---------------+= 0xFFF (4095) End of Chip-8 RAM
 	// |               |
 	// |               |
@@ -52,7 +96,7 @@ type Chip8 struct {
 	// | Reserved for  |
 	// |  interpreter  |
 	// +---------------+= 0x000 (0) Start of Chip-8 RAM
-	memory [4096]byte
+	memory []byte
 
 	// Chip-8 has 16 general purpose 8-bit registers, usually referred to as Vx, where x is a hexadecimal digit (0 through F)
 	// The VF register should not be used by any program, as it is used as a flag by some instructions
@@ -90,6 +134,10 @@ type Chip8 struct {
 	// +-------+
 	keypad [16]bool
 
+	// The key pressed while waiting for a key with Fx0A, the instruction only ends once it is released.
+	keyPressed bool
+	pressedKey byte
+
 	// The original implementation of the Chip-8 language used a 64x32-pixel monochrome display with this format:
 	// +----------------+
 	// |(0,0)	  (63,0)|
@@ -98,48 +146,179 @@ type Chip8 struct {
 	// |(0,31)	 (63,31)|
 	// +----------------+
 	//
-	// Each bit will encode the status (on/off) of the pixel, hence the uint64 per line.
-	display [DisplayHeight]uint64
+	// The SUPER-CHIP added a 128x64-pixel high resolution mode with the same format:
+	// +------------------+
+	// |(0,0)	   (127,0)|
+	// |                  |
+	// |                  |
+	// |(0,63)	  (127,63)|
+	// +------------------+
+	//
+	// Each bit will encode the status (on/off) of the pixel, hence the two uint64 per line.
+	// In low resolution mode only the first uint64 of the first 32 lines are used.
+	//
+	// The XO-CHIP has two of these bitplanes, their combination gives 4 colours per pixel.
+	display [2][DisplayHeight][DisplayWidth / 64]uint64
+
+	// The XO-CHIP selects with a bitmask which planes are drawn, cleared and scrolled.
+	planes byte
+
+	// The SUPER-CHIP can switch between the low and high resolution display modes.
+	hires bool
+
+	// The SUPER-CHIP has an instruction to exit the interpreter, after which no more instructions are executed.
+	halted bool
+
+	// The SUPER-CHIP can save registers to the 8 user flags (RPL) of the HP48 calculators.
+	// The XO-CHIP extends them to 16 flags.
+	rpl [16]byte
+
+	// The XO-CHIP plays this 128 bits pattern, most-significant-bit first, while the sound timer is active.
+	pattern [16]byte
+
+	// The XO-CHIP plays the pattern at 4000*2^((pitch-64)/48) bits per second.
+	pitch byte
+
+	// The position in bits of the audio playback in the pattern, kept between frames to avoid clicks.
+	phase float64
+
+	// The instructions available and the semantic of some of them depend on the interpreter being emulated.
+	set    InstructionSet
+	quirks Quirks
+
+	// The number of instructions executed per frame depends on the interpreter being emulated.
+	cycles int
+
+	// Set when a sprite was drawn with the display wait quirk, no more instructions are executed until the next frame.
+	vblank bool
 
 	// Chip-8 has an instruction that generate a random number.
-	rand *rand.Rand
+	rand RandomSource
+
+	// The conditions stopping the execution, and the address of the instruction to execute without checking them
+	// when resuming from a stop.
+	breakpoints *Breakpoints
+	resume      bool
+	resumePC    uint16
+
+	// The cycles left in the frame stopped by a break, the next frame runs them instead of starting a new one.
+	frameLeft int
+
+	// Writes the instructions executed when set.
+	tracer *Tracer
+
+	// Counts the instructions executed when set.
+	profiler *Profiler
+
+	// Records the memory used by the instructions executed when set.
+	coverage *Coverage
+}
+
+// Option customizes a machine created by New.
+type Option func(*Chip8)
+
+// WithSeed makes the random numbers depend only on the given seed instead of the time the machine is created,
+// so that runs are reproducible.
+func WithSeed(seed int64) Option {
+	return func(c *Chip8) {
+		c.rand = newRandSource(seed)
+	}
+}
+
+// WithRandomSource makes the machine take its random numbers from the given source.
+func WithRandomSource(r RandomSource) Option {
+	return func(c *Chip8) {
+		c.rand = r
+	}
+}
+
+// WithVIPRandom makes the machine generate random numbers like the COSMAC VIP interpreter.
+// The sequence is always the same and depends on the first page of the program, at 0x200.
+func WithVIPRandom() Option {
+	return func(c *Chip8) {
+		c.rand = &vipRandom{memory: c.memory}
+	}
 }
 
-// New return a fully initialized instance of the CHIP-8 system.
-func New() *Chip8 {
+// New return a fully initialized instance of the CHIP-8 system, emulating the given platform.
+// Unless an option says otherwise, the random numbers are seeded with the current time.
+func New(p Platform, opts ...Option) *Chip8 {
 	m := &Chip8{
-		pc: 0x200,
-		memory: [4096]byte{
-			// Programs may also refer to a group of sprites representing the hexadecimal digits 0 through F.
-			// These sprites are 5 bytes long, or 8x5 pixels.
-			// The data should be stored in the interpreter area of Chip-8 memory (0x000 to 0x1FF).
-			0xF0, 0x90, 0x90, 0x90, 0xF0, //0
-			0x20, 0x60, 0x20, 0x20, 0x70, //1
-			0xF0, 0x10, 0xF0, 0x80, 0xF0, //2
-			0xF0, 0x10, 0xF0, 0x10, 0xF0, //3
-			0x90, 0x90, 0xF0, 0x10, 0x10, //4
-			0xF0, 0x80, 0xF0, 0x10, 0xF0, //5
-			0xF0, 0x80, 0xF0, 0x90, 0xF0, //6
-			0xF0, 0x10, 0x20, 0x40, 0x40, //7
-			0xF0, 0x90, 0xF0, 0x90, 0xF0, //8
-			0xF0, 0x90, 0xF0, 0x10, 0xF0, //9
-			0xF0, 0x90, 0xF0, 0x90, 0x90, //A
-			0xE0, 0x90, 0xE0, 0x90, 0xE0, //B
-			0xF0, 0x80, 0x80, 0x80, 0xF0, //C
-			0xE0, 0x90, 0x90, 0x90, 0xE0, //D
-			0xF0, 0x80, 0xF0, 0x80, 0xF0, //E
-			0xF0, 0x80, 0xF0, 0x80, 0x80, //F
-
-		},
-		rand: rand.New(rand.NewSource(time.Now().UTC().UnixNano())),
+		set:     p.InstructionSet,
+		quirks:  p.Quirks,
+		cycles:  p.CyclePerFrame,
+		memory:  make([]byte, p.MemorySize),
+		pc:      p.LoadAddress,
+		planes:  1,
+		pattern: defaultPattern,
+		pitch:   64,
+		rand:    newRandSource(time.Now().UTC().UnixNano()),
+	}
+	// Programs may also refer to a group of sprites representing the hexadecimal digits 0 through F.
+	// The data should be stored in the interpreter area of Chip-8 memory (0x000 to 0x1FF).
+	copy(m.memory[fontAddress:], p.Font.Small[:])
+	copy(m.memory[bigFontAddress:], p.Font.Big[:])
+
+	for _, opt := range opts {
+		opt(m)
 	}
 	return m
 }
 
+// Resolution return the size in pixels of the current display mode.
+func (c *Chip8) Resolution() (width, height int) {
+	if c.hires {
+		return DisplayWidth, DisplayHeight
+	}
+	return LowResWidth, LowResHeight
+}
+
+// CyclesPerFrame return the number of instructions executed per frame.
+func (c *Chip8) CyclesPerFrame() int {
+	return c.cycles
+}
+
+// SetCyclesPerFrame changes the number of instructions executed per frame, starting with the next frame.
+// The speed of the platforms varies from a few hundreds to hundreds of thousands instructions per second,
+// and some programs need a specific one. At least one instruction is executed per frame.
+func (c *Chip8) SetCyclesPerFrame(cycles int) {
+	if cycles < 1 {
+		cycles = 1
+	}
+	c.cycles = cycles
+}
+
 // GetNextFrame takes in an input state run for one frame and return the video and audio data.
+// When a breakpoint or a watchpoint stops the execution a *Break error is returned,
+// the next call runs the rest of the frame without ticking the timers again.
 func (c *Chip8) GetNextFrame(inputs [16]bool) ([]uint32, []int16, error) {
+	c.SetKeypad(inputs)
+	cycles := c.frameLeft
+	if cycles == 0 {
+		c.TickTimers()
+		cycles = c.cycles
+	}
+	n, err := c.RunCycles(cycles)
+	c.frameLeft = 0
+	if err != nil {
+		if _, ok := err.(*Break); ok {
+			c.frameLeft = cycles - n
+		}
+		return nil, nil, err
+	}
+
+	return c.Framebuffer(), c.Audio(), nil
+}
+
+// SetKeypad changes the state of the keys, true meaning pressed.
+func (c *Chip8) SetKeypad(inputs [16]bool) {
 	c.keypad = inputs
+}
 
+// TickTimers decrements the delay and sound timers, like the 60Hz interrupt at the start of a frame.
+// It also ends the wait for the vertical blank after a sprite was drawn with the display wait quirk, and marks the
+// start of a frame in the trace.
+func (c *Chip8) TickTimers() {
 	if c.dt != 0 {
 		c.dt--
 	}
@@ -148,47 +327,107 @@ func (c *Chip8) GetNextFrame(inputs [16]bool) ([]uint32, []int16, error) {
 		c.st--
 	}
 
-	for i := 0; i < CyclePerFrame; i++ {
-		op, err := c.fetch()
-		if err != nil {
-			return nil, nil, err
+	c.vblank = false
+
+	if c.tracer != nil {
+		c.tracer.startFrame()
+	}
+}
+
+// Step executes the next instruction, even if the machine waits for the next frame.
+// Nothing is executed once the machine halted.
+// The errors of the instructions are returned as a *MachineError.
+func (c *Chip8) Step() error {
+	if c.halted {
+		return nil
+	}
+
+	resume := c.resume && c.resumePC == c.pc
+	c.resume = false
+	if c.breakpoints != nil && !resume && int(c.pc)+1 < len(c.memory) {
+		op := uint16(c.memory[c.pc])<<8 | uint16(c.memory[c.pc+1])
+		if b := c.breakpoints.check(c, op); b != nil {
+			c.resume, c.resumePC = true, c.pc
+			return b
+		}
+	}
+
+	if c.tracer != nil {
+		c.tracer.instruction(c)
+	}
+	if c.profiler != nil {
+		c.profiler.instruction(c)
+	}
+	if c.coverage != nil {
+		c.coverage.instruction(c)
+	}
+
+	pc := c.pc
+	op, err := c.fetch()
+	if err == nil {
+		err = c.decodeExecute(op)
+	}
+	if err != nil {
+		// A segmentation fault comes with the address out of memory, the rest is known here
+		e, ok := err.(*MachineError)
+		if !ok {
+			e = &MachineError{Err: err}
 		}
+		e.PC, e.Opcode, e.State = pc, op, c.State()
+		return e
+	}
+	return nil
+}
 
-		if err := c.decodeExecute(op); err != nil {
-			return nil, nil, err
+// RunCycles executes up to n instructions and return the number of instructions executed.
+// It stops early when the machine halts or waits for the next frame.
+func (c *Chip8) RunCycles(n int) (int, error) {
+	var i int
+	for ; i < n && !c.halted && !c.vblank; i++ {
+		if err := c.Step(); err != nil {
+			return i, err
 		}
 	}
 
-	return c.mapGraphic(), c.mapAudio(), nil
+	return i, nil
 }
 
-func (c *Chip8) mapGraphic() []uint32 {
-	fb := make([]uint32, DisplayWidth*DisplayHeight)
-	for y, row := range c.display {
-		for x := 0; x < DisplayWidth; x++ {
-			if row&(1<<(63-x)) > 0 {
-				fb[x+(y*DisplayWidth)] = 0xF2F4F3
-			} else {
-				fb[x+(y*DisplayWidth)] = 0x00171F
+// Framebuffer return the video data of the display as it is now, without running the machine.
+func (c *Chip8) Framebuffer() []uint32 {
+	width, height := c.Resolution()
+	fb := make([]uint32, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var color byte
+			for p := range c.display {
+				if c.pixel(p, x, y) {
+					color |= 1 << p
+				}
 			}
+			fb[x+(y*width)] = Palette[color]
 		}
 	}
 
 	return fb
 }
 
-func (c *Chip8) mapAudio() []int16 {
-	const tone = 480.0
-	const deltaPhase = 2 * math.Pi * tone / SamplingRate
+// Audio return the audio data of one frame, the playback of the pattern goes on from where the previous frame stopped.
+func (c *Chip8) Audio() []int16 {
+	const volume = math.MaxInt16 / 2
 	sb := make([]int16, SamplePerFrame*2)
 
 	if c.st > 0 {
-		var phase float64
+		rate := 4000 * math.Pow(2, (float64(c.pitch)-64)/48)
+		deltaPhase := rate / SamplingRate
 		for i := 0; i < len(sb); i += 2 {
-			phase += deltaPhase
-			sample := int16(math.Sin(phase) * math.MaxInt16)
+			bit := int(c.phase)
+			sample := int16(-volume)
+			if c.pattern[bit/8]&(0x80>>(bit%8)) != 0 {
+				sample = volume
+			}
 			sb[i] = sample   // Left channel
 			sb[i+1] = sample // Right channel
+			c.phase = math.Mod(c.phase+deltaPhase, float64(len(c.pattern)*8))
 		}
 	}
 
@@ -0,0 +1,108 @@
+package chip8
+
+import "testing"
+
+func TestChip8_SetCyclesPerFrame(t *testing.T) {
+	tests := []struct {
+		name   string
+		cycles int
+		want   int
+	}{
+		{"slower", 5, 5},
+		{"faster", 200, 200},
+		{"at least one", 0, 1},
+		{"negative", -3, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(PlatformOcto)
+			// Fill the memory with v0 += 1 to count the instructions executed.
+			for addr := int(c.pc); addr+1 < len(c.memory); addr += 2 {
+				c.memory[addr], c.memory[addr+1] = 0x70, 0x01
+			}
+
+			c.SetCyclesPerFrame(tt.cycles)
+			if got := c.CyclesPerFrame(); got != tt.want {
+				t.Errorf("chip8.CyclesPerFrame() = %v, want %v", got, tt.want)
+			}
+
+			if _, _, err := c.GetNextFrame([16]bool{}); err != nil {
+				t.Fatalf("chip8.GetNextFrame() error = %v", err)
+			}
+			if got := int(c.pc-PlatformOcto.LoadAddress) / 2; got != tt.want {
+				t.Errorf("%v instructions executed in a frame, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChip8_Step(t *testing.T) {
+	tests := []struct {
+		name   string
+		halted bool
+		vblank bool
+		wantPC uint16
+	}{
+		{"running", false, false, 0x202},
+		{"waiting for the next frame", false, true, 0x202},
+		{"halted", true, false, 0x200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(PlatformOcto)
+			c.memory[0x200], c.memory[0x201] = 0x70, 0x01
+			c.halted = tt.halted
+			c.vblank = tt.vblank
+
+			if err := c.Step(); err != nil {
+				t.Fatalf("chip8.Step() error = %v", err)
+			}
+			if c.pc != tt.wantPC {
+				t.Errorf("c.pc = 0x%04X, want 0x%04X", c.pc, tt.wantPC)
+			}
+		})
+	}
+}
+
+func TestChip8_RunCycles(t *testing.T) {
+	tests := []struct {
+		name    string
+		program []byte
+		n       int
+		want    int
+		wantErr bool
+	}{
+		{"all cycles", []byte{0x70, 0x01, 0x12, 0x00}, 7, 7, false},
+		{"halted", []byte{0x70, 0x01, 0x00, 0xFD}, 7, 2, false},
+		{"display wait", []byte{0xD0, 0x01, 0x12, 0x00}, 7, 1, false},
+		{"error", []byte{0x70, 0x01, 0xFF, 0xFF}, 7, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := PlatformOcto
+			p.Quirks.DisplayWait = true
+			c := New(p)
+			if err := c.LoadGame(tt.program); err != nil {
+				t.Fatalf("chip8.LoadGame() error = %v", err)
+			}
+
+			got, err := c.RunCycles(tt.n)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("chip8.RunCycles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("chip8.RunCycles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChip8_TickTimers(t *testing.T) {
+	c := New(PlatformOcto)
+	c.dt, c.st, c.vblank = 2, 0, true
+
+	c.TickTimers()
+	if c.dt != 1 || c.st != 0 || c.vblank {
+		t.Errorf("after chip8.TickTimers() dt, st, vblank = %v, %v, %v, want 1, 0, false", c.dt, c.st, c.vblank)
+	}
+}
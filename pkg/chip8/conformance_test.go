@@ -0,0 +1,180 @@
+package chip8_test
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Bit-Doctor/emulation/pkg/chip8"
+	"github.com/Bit-Doctor/emulation/pkg/chip8/script"
+)
+
+// conformanceDir holds the test ROMs and the pictures of the results they are expected to display, both from
+// https://github.com/Timendus/chip8-test-suite.
+const conformanceDir = "testdata/conformance"
+
+// conformanceTest runs a test ROM of the suite on a platform and compares the display at the end with a picture of the
+// expected result.
+type conformanceTest struct {
+	rom      string
+	platform chip8.Platform
+	// The value written at 0x1FF, which selects the test or the platform without going through the menu of the ROM.
+	// Nothing is written when 0.
+	selection byte
+	frames    int
+	// The keys pressed, as a script.
+	keys string
+	// The picture of the expected display, or its bitmap in a .txt file.
+	want string
+}
+
+// name return the name of the test.
+func (tt conformanceTest) name() string {
+	name := fmt.Sprintf("%v-%v", strings.TrimSuffix(tt.rom, ".ch8"), tt.platform.Name)
+	if tt.selection != 0 {
+		name += fmt.Sprintf("-%d", tt.selection)
+	}
+	return name
+}
+
+func conformanceTests() []conformanceTest {
+	var tests []conformanceTest
+	for _, p := range chip8.Platforms {
+		tests = append(tests,
+			conformanceTest{rom: "3-corax+.ch8", platform: p, frames: 600, want: "corax+.png"},
+			conformanceTest{rom: "4-flags.ch8", platform: p, frames: 600, want: "flags.png"},
+		)
+	}
+	return append(tests,
+		// The platforms of the quirks test are 1 for CHIP-8, 2 for SUPER-CHIP and 3 for XO-CHIP, the suite only has a
+		// picture of the CHIP-8 result, the others are bitmaps checked by hand
+		conformanceTest{rom: "5-quirks.ch8", platform: chip8.PlatformVIP, selection: 1, frames: 1200, want: "quirks.png"},
+		conformanceTest{rom: "5-quirks.ch8", platform: chip8.PlatformSCHIP10, selection: 2, frames: 1200,
+			want: "quirks-schip.txt"},
+		conformanceTest{rom: "5-quirks.ch8", platform: chip8.PlatformSCHIP11, selection: 2, frames: 1200,
+			want: "quirks-schip.txt"},
+		conformanceTest{rom: "5-quirks.ch8", platform: chip8.PlatformXOCHIP, selection: 3, frames: 1200,
+			want: "quirks-xochip.txt"},
+		// The tests of the keypad test are 1 for Ex9E, 2 for ExA1 and 3 for Fx0A, the pictures of the first two are
+		// taken while pressing the keys 1 and 6
+		conformanceTest{rom: "6-keypad.ch8", platform: chip8.PlatformVIP, selection: 1, frames: 120, keys: "60 press 1 6",
+			want: "keypad-down.png"},
+		conformanceTest{rom: "6-keypad.ch8", platform: chip8.PlatformVIP, selection: 2, frames: 120, keys: "60 press 1 6",
+			want: "keypad-up.png"},
+		conformanceTest{rom: "6-keypad.ch8", platform: chip8.PlatformVIP, selection: 3, frames: 120,
+			keys: "60 press 5\n70 release 5", want: "keypad-getkey.png"},
+		conformanceTest{rom: "6-keypad.ch8", platform: chip8.PlatformOcto, selection: 3, frames: 120,
+			keys: "60 press 5\n70 release 5", want: "keypad-getkey.png"},
+	)
+}
+
+// TestConformance runs the test ROMs and compares the display at the end with the pictures of the suite.
+func TestConformance(t *testing.T) {
+	for _, tt := range conformanceTests() {
+		t.Run(tt.name(), func(t *testing.T) {
+			rom, err := ioutil.ReadFile(filepath.Join(conformanceDir, tt.rom))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := readPicture(filepath.Join(conformanceDir, tt.want))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c := chip8.New(tt.platform, chip8.WithSeed(0))
+			if err := c.LoadGame(rom); err != nil {
+				t.Fatal(err)
+			}
+			if tt.selection != 0 {
+				if err := c.Poke(0x1FF, tt.selection); err != nil {
+					t.Fatal(err)
+				}
+			}
+			keys, err := script.Parse(strings.NewReader(tt.keys))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := keys.Run(c, tt.frames, nil); err != nil {
+				t.Fatalf("%v stopped: %v", tt.rom, err)
+			}
+
+			if got := bitmap(c); !bytes.Equal(got, want) {
+				t.Errorf("display = \n%s, want \n%s", got, want)
+			}
+		})
+	}
+}
+
+// readPicture return a screenshot of the suite as a low resolution bitmap, like bitmap does. The screenshots are
+// 64x32 displays scaled up, drawn with two colours: the most common one is the background.
+// A .txt file already holds the bitmap.
+func readPicture(path string) ([]byte, error) {
+	if filepath.Ext(path) == ".txt" {
+		return ioutil.ReadFile(path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+
+	const width, height = chip8.DisplayWidth / 2, chip8.DisplayHeight / 2
+	scale := img.Bounds().Dx() / width
+	pixel := func(x, y int) color.Color {
+		return img.At(img.Bounds().Min.X+x*scale+scale/2, img.Bounds().Min.Y+y*scale+scale/2)
+	}
+	counts := make(map[color.Color]int)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			counts[pixel(x, y)]++
+		}
+	}
+	var background color.Color
+	for c, n := range counts {
+		if background == nil || n > counts[background] {
+			background = c
+		}
+	}
+
+	var buf bytes.Buffer
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if pixel(x, y) == background {
+				buf.WriteByte('.')
+			} else {
+				buf.WriteByte('#')
+			}
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// bitmap return the display as text, a line per row and a character per pixel: . when off, then # + * for the
+// colours of the XO-CHIP planes.
+func bitmap(c *chip8.Chip8) []byte {
+	const chars = ".#+*"
+	width, _ := c.Resolution()
+	var buf bytes.Buffer
+	for i, pixel := range c.Framebuffer() {
+		for color, p := range chip8.Palette {
+			if pixel == p {
+				buf.WriteByte(chars[color])
+			}
+		}
+		if i%width == width-1 {
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,77 @@
+package chip8
+
+// Usage is the set of ways an address of the memory was used.
+type Usage byte
+
+const (
+	// Executed addresses were fetched as part of an instruction.
+	Executed Usage = 1 << iota
+	// DataRead addresses were read by an instruction, as a sprite, registers or an audio pattern.
+	DataRead
+	// DataWritten addresses were written by an instruction, with registers or a BCD number.
+	DataWritten
+)
+
+func (u Usage) String() string {
+	s := []byte("---")
+	if u&Executed != 0 {
+		s[0] = 'X'
+	}
+	if u&DataRead != 0 {
+		s[1] = 'R'
+	}
+	if u&DataWritten != 0 {
+		s[2] = 'W'
+	}
+	return string(s)
+}
+
+// Coverage records how the addresses of the memory are used by the instructions executed.
+type Coverage struct {
+	usage [0x10000]Usage
+}
+
+// NewCoverage return a coverage where no address was used.
+func NewCoverage() *Coverage {
+	return &Coverage{}
+}
+
+// SetCoverage records the memory used by the instructions executed in cov, or stops recording if cov is nil.
+func (c *Chip8) SetCoverage(cov *Coverage) {
+	c.coverage = cov
+}
+
+// Usage return how the address was used.
+func (cov *Coverage) Usage(addr uint16) Usage {
+	return cov.usage[addr]
+}
+
+// Add records a use of the address, to merge coverages.
+func (cov *Coverage) Add(addr uint16, u Usage) {
+	cov.usage[addr] |= u
+}
+
+// instruction records the memory used by the instruction at the program counter.
+func (cov *Coverage) instruction(c *Chip8) {
+	if int(c.pc)+1 >= len(c.memory) {
+		return
+	}
+	op := uint16(c.memory[c.pc])<<8 | uint16(c.memory[c.pc+1])
+
+	size := 2
+	if op == 0xF000 && c.set >= XOCHIP {
+		size = 4
+	}
+	for addr := int(c.pc); addr < int(c.pc)+size && addr < len(c.memory); addr++ {
+		cov.usage[addr] |= Executed
+	}
+
+	a := c.accesses(op)
+	u := DataRead
+	if a.memAccess == Write {
+		u = DataWritten
+	}
+	for k := 0; k < a.memLen; k++ {
+		cov.usage[uint16(a.memStart+k)] |= u // The addresses wrap around like the instructions
+	}
+}
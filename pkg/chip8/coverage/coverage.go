@@ -0,0 +1,208 @@
+// Package coverage reads, merges and writes the coverage reports of CHIP-8 programs, telling which addresses of the
+// memory were executed, read as data and written over one or several runs.
+//
+// A coverage report is a JSON document listing the ranges of addresses used in each way, the end of a range being
+// excluded, and the range and the SHA-256 of the program:
+//
+//	{
+//	  "version": 2,
+//	  "rom": {"start": 512, "end": 1024},
+//	  "sha256": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+//	  "executed": [{"start": 512, "end": 620}],
+//	  "read": [{"start": 0, "end": 80}, {"start": 700, "end": 708}],
+//	  "written": [{"start": 900, "end": 903}]
+//	}
+//
+// The addresses are in decimal, as JSON has no hexadecimal numbers.
+package coverage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Bit-Doctor/emulation/pkg/chip8"
+)
+
+// Version is the version of the format of the reports written by this package.
+// The version 1 had no SHA-256 of the program and is not read anymore.
+const Version = 2
+
+// Range is a range of addresses, from Start to End excluded.
+type Range struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Report is the coverage of a program loaded in memory.
+type Report struct {
+	// ROM is the range of addresses of the program.
+	ROM Range
+	// SHA256 is the SHA-256 of the program.
+	SHA256 [sha256.Size]byte
+	usage  *chip8.Coverage
+}
+
+// jsonReport is the JSON document of a report.
+type jsonReport struct {
+	Version  int     `json:"version"`
+	ROM      Range   `json:"rom"`
+	SHA256   string  `json:"sha256"`
+	Executed []Range `json:"executed"`
+	Read     []Range `json:"read"`
+	Written  []Range `json:"written"`
+}
+
+// New return the report of the coverage of a program loaded at origin.
+// The report keeps a copy of the coverage.
+func New(cov *chip8.Coverage, rom []byte, origin uint16) *Report {
+	r := &Report{
+		ROM:    Range{int(origin), int(origin) + len(rom)},
+		SHA256: sha256.Sum256(rom),
+		usage:  chip8.NewCoverage(),
+	}
+	for addr := 0; addr < 0x10000; addr++ {
+		r.usage.Add(uint16(addr), cov.Usage(uint16(addr)))
+	}
+	return r
+}
+
+// Read decodes a report.
+func Read(r io.Reader) (*Report, error) {
+	var doc jsonReport
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid coverage report: %v", err)
+	}
+	if doc.Version != Version {
+		return nil, fmt.Errorf("coverage report version not supported: %d", doc.Version)
+	}
+
+	hash, err := hex.DecodeString(doc.SHA256)
+	if err != nil || len(hash) != sha256.Size {
+		return nil, fmt.Errorf("invalid coverage report: invalid SHA-256 of the program: %q", doc.SHA256)
+	}
+
+	report := &Report{ROM: doc.ROM, usage: chip8.NewCoverage()}
+	copy(report.SHA256[:], hash)
+	for _, ranges := range []struct {
+		ranges []Range
+		usage  chip8.Usage
+	}{{doc.Executed, chip8.Executed}, {doc.Read, chip8.DataRead}, {doc.Written, chip8.DataWritten}} {
+		for _, rg := range ranges.ranges {
+			if rg.Start < 0 || rg.End > 0x10000 || rg.Start > rg.End {
+				return nil, fmt.Errorf("invalid coverage report: range out of memory: %d-%d", rg.Start, rg.End)
+			}
+			for addr := rg.Start; addr < rg.End; addr++ {
+				report.usage.Add(uint16(addr), ranges.usage)
+			}
+		}
+	}
+	return report, nil
+}
+
+// Load reads a report from a file.
+func Load(path string) (*Report, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Write encodes the report.
+func (r *Report) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(jsonReport{
+		Version:  Version,
+		ROM:      r.ROM,
+		SHA256:   hex.EncodeToString(r.SHA256[:]),
+		Executed: r.ranges(chip8.Executed),
+		Read:     r.ranges(chip8.DataRead),
+		Written:  r.ranges(chip8.DataWritten),
+	})
+}
+
+// ranges return the ranges of the addresses used in a way.
+func (r *Report) ranges(u chip8.Usage) []Range {
+	ranges := []Range{}
+	for addr := 0; addr < 0x10000; addr++ {
+		if r.usage.Usage(uint16(addr))&u == 0 {
+			continue
+		}
+		if n := len(ranges); n > 0 && ranges[n-1].End == addr {
+			ranges[n-1].End++
+		} else {
+			ranges = append(ranges, Range{addr, addr + 1})
+		}
+	}
+	return ranges
+}
+
+// Usage return how the address was used.
+func (r *Report) Usage(addr uint16) chip8.Usage {
+	return r.usage.Usage(addr)
+}
+
+// Check return an error if the report is not the coverage of the program loaded at origin.
+func (r *Report) Check(rom []byte, origin uint16) error {
+	if r.ROM != (Range{int(origin), int(origin) + len(rom)}) {
+		return fmt.Errorf("the coverage is of a program at %d-%d", r.ROM.Start, r.ROM.End)
+	}
+	if r.SHA256 != sha256.Sum256(rom) {
+		return errors.New("the coverage is of another program")
+	}
+	return nil
+}
+
+// Merge adds the coverage of another run of the same program.
+func (r *Report) Merge(other *Report) error {
+	if other.ROM != r.ROM {
+		return fmt.Errorf("cannot merge the coverage of programs at different addresses: %d-%d and %d-%d",
+			r.ROM.Start, r.ROM.End, other.ROM.Start, other.ROM.End)
+	}
+	if other.SHA256 != r.SHA256 {
+		return errors.New("cannot merge the coverage of different programs")
+	}
+	for addr := 0; addr < 0x10000; addr++ {
+		r.usage.Add(uint16(addr), other.usage.Usage(uint16(addr)))
+	}
+	return nil
+}
+
+// Summary is the number of bytes of the program used in each way.
+type Summary struct {
+	Bytes    int
+	Executed int
+	Read     int
+	Written  int
+	// Unused is the number of bytes neither executed, read nor written.
+	Unused int
+}
+
+// Summary counts the bytes of the program used in each way.
+func (r *Report) Summary() Summary {
+	var s Summary
+	for addr := r.ROM.Start; addr < r.ROM.End; addr++ {
+		u := r.usage.Usage(uint16(addr))
+		s.Bytes++
+		if u&chip8.Executed != 0 {
+			s.Executed++
+		}
+		if u&chip8.DataRead != 0 {
+			s.Read++
+		}
+		if u&chip8.DataWritten != 0 {
+			s.Written++
+		}
+		if u == 0 {
+			s.Unused++
+		}
+	}
+	return s
+}
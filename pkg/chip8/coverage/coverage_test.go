@@ -0,0 +1,165 @@
+package coverage
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Bit-Doctor/emulation/pkg/chip8"
+)
+
+// The program draws a sprite and writes its BCD over it, the clear is skipped.
+var program = []byte{
+	0xA2, 0x0C, // 0x200 i := 0x20C
+	0xD0, 0x01, // 0x202 sprite v0 v0 1
+	0xF0, 0x33, // 0x204 bcd v0
+	0x30, 0x00, // 0x206 if v0 != 0 then
+	0x00, 0xE0, // 0x208 clear
+	0x12, 0x0A, // 0x20A jump 0x20A
+	0xFF, 0x00, // 0x20C
+	0x00, 0x7E, // 0x20E
+}
+
+// run return the report of a run of the program for a number of cycles.
+func run(t *testing.T, cycles int) *Report {
+	c := chip8.New(chip8.PlatformOcto)
+	if err := c.LoadGame(program); err != nil {
+		t.Fatal(err)
+	}
+	cov := chip8.NewCoverage()
+	c.SetCoverage(cov)
+	if _, err := c.RunCycles(cycles); err != nil {
+		t.Fatalf("chip8.RunCycles() error = %v", err)
+	}
+	return New(cov, program, 0x200)
+}
+
+func TestReport_Write(t *testing.T) {
+	var buf bytes.Buffer
+	if err := run(t, 6).Write(&buf); err != nil {
+		t.Fatalf("Report.Write() error = %v", err)
+	}
+	for _, want := range []string{
+		`"rom": {
+    "start": 512,
+    "end": 528
+  }`,
+		`"executed": [
+    {
+      "start": 512,
+      "end": 520
+    },
+    {
+      "start": 522,
+      "end": 524
+    }
+  ]`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Report.Write() = %v, want it to contain %v", buf.String(), want)
+		}
+	}
+
+	r, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if got, want := r.Summary(), (Summary{Bytes: 16, Executed: 10, Read: 1, Written: 3, Unused: 3}); got != want {
+		t.Errorf("Read().Summary() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRead(t *testing.T) {
+	hash := strings.Repeat("00", 32)
+	tests := []struct {
+		name    string
+		doc     string
+		wantErr bool
+	}{
+		{"valid", `{"version": 2, "rom": {"start": 512, "end": 520}, "sha256": "` + hash + `", "executed": [{"start": 512, "end": 514}]}`, false},
+		{"version", `{"version": 1, "rom": {"start": 512, "end": 520}}`, true},
+		{"no sha256", `{"version": 2, "rom": {"start": 512, "end": 520}}`, true},
+		{"short sha256", `{"version": 2, "rom": {"start": 512, "end": 520}, "sha256": "9f86d081"}`, true},
+		{"out of memory", `{"version": 2, "rom": {"start": 512, "end": 520}, "sha256": "` + hash + `", "read": [{"start": 65535, "end": 65537}]}`, true},
+		{"not json", `executed`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Read(strings.NewReader(tt.doc)); (err != nil) != tt.wantErr {
+				t.Errorf("Read() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReport_Merge(t *testing.T) {
+	// The first run stops before the sprite is drawn
+	r := run(t, 1)
+	if got := r.Usage(0x20C); got != 0 {
+		t.Errorf("Report.Usage(0x20C) = %v before merging, want ---", got)
+	}
+	if err := r.Merge(run(t, 6)); err != nil {
+		t.Fatalf("Report.Merge() error = %v", err)
+	}
+	if got, want := r.Usage(0x20C), chip8.DataRead|chip8.DataWritten; got != want {
+		t.Errorf("Report.Usage(0x20C) = %v after merging, want %v", got, want)
+	}
+
+	other := New(chip8.NewCoverage(), program, 0x600)
+	if err := r.Merge(other); err == nil {
+		t.Errorf("Report.Merge() of a program at another address error = nil, want an error")
+	}
+	other = New(chip8.NewCoverage(), make([]byte, len(program)), 0x200)
+	if err := r.Merge(other); err == nil {
+		t.Errorf("Report.Merge() of another program error = nil, want an error")
+	}
+}
+
+func TestReport_Check(t *testing.T) {
+	other := append([]byte{}, program...)
+	other[len(other)-1] = 0x7F
+	tests := []struct {
+		name    string
+		rom     []byte
+		origin  uint16
+		wantErr bool
+	}{
+		{"same", program, 0x200, false},
+		{"address", program, 0x600, true},
+		{"size", program[:len(program)-2], 0x200, true},
+		{"content", other, 0x200, true},
+	}
+	r := run(t, 6)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := r.Check(tt.rom, tt.origin); (err != nil) != tt.wantErr {
+				t.Errorf("Report.Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReport_WriteListing(t *testing.T) {
+	var buf bytes.Buffer
+	if err := run(t, 6).WriteListing(&buf, program, chip8.XOCHIP); err != nil {
+		t.Fatalf("Report.WriteListing() error = %v", err)
+	}
+	want := `; 16 bytes from 0x200: 10 executed, 1 read, 3 written, 3 unused
+; 2 of 12 bytes of code never executed
+: main
+X--  0200  A20C       i := data-20c
+X--  0202  D001       sprite v0 v0 1
+X--  0204  F033       bcd v0
+X--  0206  3000       if v0 != 0x00 then
+---  0208  00E0       clear
+: label-20a
+X--  020A  120A       jump label-20a
+: data-20c
+-RW  020C  FF
+--W  020D  00 00
+---  020F  7E
+`
+	if got := buf.String(); got != want {
+		t.Errorf("Report.WriteListing() = \n%v, want \n%v", got, want)
+	}
+}
@@ -0,0 +1,116 @@
+package coverage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Bit-Doctor/emulation/pkg/chip8"
+	"github.com/Bit-Doctor/emulation/pkg/chip8/disasm"
+)
+
+// The number of data bytes written per line of the listing.
+const bytesPerLine = 8
+
+// WriteListing writes the disassembly of the program annotated with its coverage.
+//
+// Each line starts with how its bytes were used: X for executed, R for read and W for written, or - otherwise, then
+// gives the address, the bytes and the instruction in Octo syntax. The instructions found by the disassembler but
+// never executed are the code not covered, and the code only reached through jump0 is found from the coverage.
+func (r *Report) WriteListing(w io.Writer, rom []byte, set chip8.InstructionSet) error {
+	origin := uint16(r.ROM.Start)
+	program := disasm.Disassemble(rom, origin, set)
+
+	bw := bufio.NewWriter(w)
+	s := r.Summary()
+	var code, missed int
+	for offset := range rom {
+		addr := origin + uint16(offset)
+		if program.IsCode(addr) || r.Usage(addr)&chip8.Executed != 0 {
+			code++
+			if r.Usage(addr)&chip8.Executed == 0 {
+				missed++
+			}
+		}
+	}
+	fmt.Fprintf(bw, "; %d bytes from 0x%03X: %d executed, %d read, %d written, %d unused\n",
+		s.Bytes, origin, s.Executed, s.Read, s.Written, s.Unused)
+	fmt.Fprintf(bw, "; %d of %d bytes of code never executed\n", missed, code)
+
+	var data []byte
+	var dataAddr uint16
+	var dataUsage chip8.Usage
+	flush := func() {
+		if len(data) == 0 {
+			return
+		}
+		hex := make([]string, len(data))
+		for i, b := range data {
+			hex[i] = fmt.Sprintf("%02X", b)
+		}
+		fmt.Fprintf(bw, "%v  %04X  %v\n", dataUsage, dataAddr, strings.Join(hex, " "))
+		data = data[:0]
+	}
+
+	for offset := 0; offset < len(rom); {
+		addr := origin + uint16(offset)
+		if name := program.Label(addr); name != "" {
+			flush()
+			fmt.Fprintf(bw, ": %v\n", name)
+		}
+
+		ins, ok := r.instruction(program, rom, offset, set)
+		if !ok {
+			u := r.Usage(addr)
+			if len(data) > 0 && u != dataUsage {
+				flush()
+			}
+			if len(data) == 0 {
+				dataAddr, dataUsage = addr, u
+			}
+			data = append(data, rom[offset])
+			if len(data) == bytesPerLine {
+				flush()
+			}
+			offset++
+			continue
+		}
+
+		flush()
+		var u chip8.Usage
+		for i := 0; i < ins.Size; i++ {
+			u |= r.Usage(addr + uint16(i))
+		}
+		hex := fmt.Sprintf("%04X", ins.Opcode)
+		if ins.Size == 4 {
+			hex += fmt.Sprintf(" %04X", ins.Long)
+		}
+		fmt.Fprintf(bw, "%v  %04X  %-9s  %v\n", u, addr, hex, ins.Mnemonic(program.Label))
+		offset += ins.Size
+	}
+	flush()
+	return bw.Flush()
+}
+
+// instruction return the instruction at an offset of the program, found by the disassembler or executed.
+func (r *Report) instruction(program *disasm.Program, rom []byte, offset int, set chip8.InstructionSet) (disasm.Instruction, bool) {
+	addr := program.Origin + uint16(offset)
+	if ins, ok := program.Instruction(addr); ok {
+		return ins, true
+	}
+	if r.Usage(addr)&chip8.Executed == 0 || program.IsCode(addr) {
+		return disasm.Instruction{}, false
+	}
+
+	ins, err := disasm.Decode(rom[offset:], addr, set)
+	if err != nil {
+		return disasm.Instruction{}, false
+	}
+	for i := 1; i < ins.Size; i++ {
+		if program.IsCode(addr + uint16(i)) {
+			return disasm.Instruction{}, false
+		}
+	}
+	return ins, true
+}
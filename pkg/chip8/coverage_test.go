@@ -0,0 +1,46 @@
+package chip8
+
+import "testing"
+
+// The program draws a sprite and writes its BCD over it, the clear is skipped.
+var coveredProgram = []byte{
+	0xA2, 0x0C, // 0x200 i := 0x20C
+	0xD0, 0x01, // 0x202 sprite v0 v0 1
+	0xF0, 0x33, // 0x204 bcd v0
+	0x30, 0x00, // 0x206 if v0 != 0 then
+	0x00, 0xE0, // 0x208 clear
+	0x12, 0x0A, // 0x20A jump 0x20A
+	0xFF, 0x00, // 0x20C
+	0x00, 0x7E, // 0x20E
+}
+
+func TestCoverage(t *testing.T) {
+	c := New(PlatformOcto)
+	c.LoadGame(coveredProgram)
+	cov := NewCoverage()
+	c.SetCoverage(cov)
+
+	if _, err := c.RunCycles(6); err != nil {
+		t.Fatalf("chip8.RunCycles() error = %v", err)
+	}
+
+	tests := []struct {
+		addr uint16
+		want Usage
+	}{
+		{0x200, Executed},
+		{0x201, Executed},
+		{0x206, Executed},
+		{0x208, 0},
+		{0x20A, Executed},
+		{0x20C, DataRead | DataWritten},
+		{0x20D, DataWritten},
+		{0x20E, DataWritten},
+		{0x20F, 0},
+	}
+	for _, tt := range tests {
+		if got := cov.Usage(tt.addr); got != tt.want {
+			t.Errorf("Coverage.Usage(0x%03X) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
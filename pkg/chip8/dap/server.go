@@ -0,0 +1,606 @@
+// Package dap implements a Debug Adapter Protocol server, so that editors can debug CHIP-8 programs.
+//
+// The server takes the launch arguments of the client to create the machine, then the frontend drives it
+// one frame at a time with Frame, which handles the requests of the client and runs the machine unless it is paused.
+package dap
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/Bit-Doctor/emulation/pkg/chip8"
+	"github.com/Bit-Doctor/emulation/pkg/chip8/symbols"
+)
+
+// ErrDisconnected is returned once the client ended the session or closed the connection.
+var ErrDisconnected = errors.New("the debug client disconnected")
+
+// The machine has a single thread of execution.
+const threadID = 1
+
+// The variables references of the scopes.
+const (
+	registersReference = 1
+	stackReference     = 2
+)
+
+// Server is a debug session with a client over a connection.
+type Server struct {
+	w        io.Writer
+	seq      int
+	requests chan *request
+	events   []event
+	ended    bool
+
+	program  string
+	vm       *chip8.Chip8
+	platform chip8.Platform
+	breaks   *chip8.Breakpoints
+	symbols  *symbols.Table
+
+	// The addresses of the breakpoints set on each source file and on instructions.
+	sourceBreaks      map[string][]uint16
+	instructionBreaks []uint16
+
+	configured  bool
+	stopOnEntry bool
+	paused      bool
+	exited      bool
+
+	// While stepping, reports whether the execution stops before the next instruction.
+	until func() bool
+}
+
+// NewServer starts a debug session with the client at the other end of the connection.
+func NewServer(conn io.ReadWriter) *Server {
+	s := &Server{
+		w:            conn,
+		requests:     make(chan *request, 16),
+		sourceBreaks: make(map[string][]uint16),
+	}
+	go s.read(bufio.NewReader(conn))
+	return s
+}
+
+func (s *Server) read(r *bufio.Reader) {
+	for {
+		req := new(request)
+		if err := readMessage(r, req); err != nil {
+			close(s.requests)
+			return
+		}
+		s.requests <- req
+	}
+}
+
+// Launch handles the requests of the client until it launches a program.
+// It return the machine running the program and the platform it emulates.
+func (s *Server) Launch() (*chip8.Chip8, chip8.Platform, error) {
+	for s.vm == nil {
+		req, ok := <-s.requests
+		if !ok {
+			s.ended = true
+		} else {
+			s.handle(req)
+		}
+		if s.ended {
+			return nil, chip8.Platform{}, ErrDisconnected
+		}
+	}
+	return s.vm, s.platform, nil
+}
+
+// Program return the ROM file launched.
+func (s *Server) Program() string {
+	return s.program
+}
+
+// Frame handles the pending requests of the client then runs the machine for one frame, unless the program is paused.
+// It return the video and audio data of the frame, silence while paused.
+func (s *Server) Frame(inputs [16]bool) ([]uint32, []int16, error) {
+	for pending := true; pending && !s.ended; {
+		select {
+		case req, ok := <-s.requests:
+			if !ok {
+				s.ended = true
+			} else {
+				s.handle(req)
+			}
+		default:
+			pending = false
+		}
+	}
+	if s.ended {
+		return nil, nil, ErrDisconnected
+	}
+
+	if !s.configured || s.paused || s.exited {
+		return s.vm.Framebuffer(), make([]int16, chip8.SamplePerFrame*2), nil
+	}
+
+	s.vm.SetKeypad(inputs)
+	s.vm.TickTimers()
+	for i := 0; i < s.vm.CyclesPerFrame(); i++ {
+		if s.until != nil && s.until() {
+			s.stop("step", "")
+			break
+		}
+
+		n, err := s.vm.RunCycles(1)
+		if err != nil {
+			s.stopOn(err)
+			break
+		}
+		if n == 0 { // The machine halted or waits for the next frame
+			break
+		}
+	}
+
+	if s.vm.State().Halted && !s.exited {
+		s.exited = true
+		s.emit("exited", map[string]interface{}{"exitCode": 0})
+		s.emit("terminated", nil)
+	}
+	s.flush()
+
+	return s.vm.Framebuffer(), s.vm.Audio(), nil
+}
+
+// handle answers a request of the client.
+func (s *Server) handle(req *request) {
+	var body interface{}
+	var err error
+	if s.vm == nil && req.Command != "initialize" && req.Command != "launch" && req.Command != "disconnect" {
+		err = errors.New("no program launched")
+	} else {
+		body, err = s.dispatch(req)
+	}
+
+	resp := response{Type: "response", RequestSeq: req.Seq, Success: err == nil, Command: req.Command, Body: body}
+	if err != nil {
+		resp.Message = err.Error()
+	}
+	s.write(&resp)
+	s.flush()
+}
+
+func (s *Server) dispatch(req *request) (interface{}, error) {
+	switch req.Command {
+	case "initialize":
+		s.emit("initialized", nil)
+		return map[string]bool{
+			"supportsConfigurationDoneRequest": true,
+			"supportsReadMemoryRequest":        true,
+			"supportsWriteMemoryRequest":       true,
+			"supportsDisassembleRequest":       true,
+			"supportsInstructionBreakpoints":   true,
+		}, nil
+	case "launch":
+		var args launchArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return nil, s.launch(args)
+	case "setBreakpoints":
+		var args setBreakpointsArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.setBreakpoints(args), nil
+	case "setInstructionBreakpoints":
+		var args setInstructionBreakpointsArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.setInstructionBreakpoints(args), nil
+	case "configurationDone":
+		s.configured = true
+		if s.stopOnEntry {
+			s.stop("entry", "")
+		}
+		return nil, nil
+	case "threads":
+		return map[string]interface{}{"threads": []thread{{ID: threadID, Name: s.platform.Name}}}, nil
+	case "stackTrace":
+		frames := s.stackTrace()
+		return map[string]interface{}{"stackFrames": frames, "totalFrames": len(frames)}, nil
+	case "scopes":
+		return map[string]interface{}{"scopes": []scope{
+			{Name: "Registers", VariablesReference: registersReference},
+			{Name: "Stack", VariablesReference: stackReference},
+		}}, nil
+	case "variables":
+		var args struct {
+			VariablesReference int `json:"variablesReference"`
+		}
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"variables": s.variables(args.VariablesReference)}, nil
+	case "continue":
+		s.paused = false
+		s.until = nil
+		return map[string]bool{"allThreadsContinued": true}, nil
+	case "pause":
+		s.stop("pause", "")
+		return nil, nil
+	case "next", "stepIn", "stepOut":
+		s.step(req.Command)
+		return nil, nil
+	case "readMemory":
+		var args readMemoryArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.readMemory(args)
+	case "writeMemory":
+		var args writeMemoryArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.writeMemory(args)
+	case "disassemble":
+		var args struct {
+			MemoryReference   string `json:"memoryReference"`
+			Offset            int    `json:"offset"`
+			InstructionOffset int    `json:"instructionOffset"`
+			InstructionCount  int    `json:"instructionCount"`
+		}
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		addr, err := parseReference(args.MemoryReference)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"instructions": s.disassemble(addr+args.Offset, args.InstructionOffset, args.InstructionCount)}, nil
+	case "disconnect":
+		s.ended = true
+		return nil, nil
+	}
+	return nil, fmt.Errorf("request not supported: %v", req.Command)
+}
+
+func (s *Server) launch(args launchArguments) error {
+	platform := chip8.PlatformOcto
+	if args.Platform != "" {
+		var ok bool
+		if platform, ok = chip8.PlatformByName(args.Platform); !ok {
+			return fmt.Errorf("unknown platform: %v", args.Platform)
+		}
+	}
+
+	data, err := ioutil.ReadFile(args.Program)
+	if err != nil {
+		return err
+	}
+
+	var table *symbols.Table
+	if args.Symbols != "" {
+		if table, err = symbols.Load(args.Symbols); err != nil {
+			return err
+		}
+	}
+
+	var opts []chip8.Option
+	if args.Seed != nil {
+		opts = append(opts, chip8.WithSeed(*args.Seed))
+	}
+	vm := chip8.New(platform, opts...)
+	if args.Cycles > 0 {
+		vm.SetCyclesPerFrame(args.Cycles)
+	}
+	if err := vm.LoadGame(data); err != nil {
+		return err
+	}
+
+	s.program, s.vm, s.platform, s.symbols = args.Program, vm, platform, table
+	s.breaks = chip8.NewBreakpoints()
+	s.vm.SetBreakpoints(s.breaks)
+	s.stopOnEntry = args.StopOnEntry
+	return nil
+}
+
+func (s *Server) setBreakpoints(args setBreakpointsArguments) map[string]interface{} {
+	var addrs []uint16
+	breakpoints := []breakpoint{}
+	for _, b := range args.Breakpoints {
+		bp := breakpoint{Source: &args.Source, Line: b.Line}
+		switch {
+		case s.symbols == nil:
+			bp.Message = "no symbol file"
+		case len(s.symbols.AddressesOf(args.Source.Path, b.Line)) == 0:
+			bp.Message = "no instruction on this line"
+		default:
+			bp.Verified = true
+			addrs = append(addrs, s.symbols.AddressesOf(args.Source.Path, b.Line)...)
+		}
+		breakpoints = append(breakpoints, bp)
+	}
+
+	s.sourceBreaks[args.Source.Path] = addrs
+	s.updateBreakpoints()
+	return map[string]interface{}{"breakpoints": breakpoints}
+}
+
+func (s *Server) setInstructionBreakpoints(args setInstructionBreakpointsArguments) map[string]interface{} {
+	s.instructionBreaks = nil
+	breakpoints := []breakpoint{}
+	for _, b := range args.Breakpoints {
+		addr, err := parseReference(b.InstructionReference)
+		if err != nil || addr+b.Offset < 0 || addr+b.Offset >= s.vm.MemorySize() {
+			breakpoints = append(breakpoints, breakpoint{Message: "invalid address"})
+			continue
+		}
+
+		addr += b.Offset
+		s.instructionBreaks = append(s.instructionBreaks, uint16(addr))
+		breakpoints = append(breakpoints, breakpoint{Verified: true, InstructionReference: reference(addr)})
+	}
+
+	s.updateBreakpoints()
+	return map[string]interface{}{"breakpoints": breakpoints}
+}
+
+// updateBreakpoints sets the breakpoints of all the sources and instructions on the machine.
+func (s *Server) updateBreakpoints() {
+	s.breaks.Clear()
+	for _, addrs := range s.sourceBreaks {
+		for _, addr := range addrs {
+			s.breaks.Add(addr)
+		}
+	}
+	for _, addr := range s.instructionBreaks {
+		s.breaks.Add(addr)
+	}
+}
+
+// stackTrace return the current instruction followed by the calls of the subroutines, the innermost first.
+func (s *Server) stackTrace() []stackFrame {
+	state := s.vm.State()
+	addrs := []uint16{state.PC}
+	for i := int(state.SP) - 1; i >= 0; i-- {
+		addrs = append(addrs, state.Stack[i]-2) // The call instruction precedes the return address
+	}
+
+	var frames []stackFrame
+	for id, addr := range addrs {
+		f := stackFrame{ID: id, Name: s.function(addr), InstructionPointerReference: reference(int(addr))}
+		if s.symbols != nil {
+			if l, ok := s.symbols.LineOf(addr); ok {
+				f.Source = &source{Name: l.File[strings.LastIndexAny(l.File, `/\`)+1:], Path: l.File}
+				f.Line, f.Column = l.Line, 1
+			}
+		}
+		frames = append(frames, f)
+	}
+	return frames
+}
+
+// function return the name of the closest label before addr, or addr itself without symbols.
+func (s *Server) function(addr uint16) string {
+	if s.symbols == nil {
+		return reference(int(addr))
+	}
+
+	var name string
+	var best uint16
+	for label, a := range s.symbols.Labels {
+		if a <= addr && (name == "" || a > best || a == best && label < name) {
+			name, best = label, a
+		}
+	}
+	if name == "" {
+		return reference(int(addr))
+	}
+	return name
+}
+
+func (s *Server) variables(ref int) []variable {
+	state := s.vm.State()
+	vars := []variable{}
+	switch ref {
+	case registersReference:
+		for x, v := range state.V {
+			vars = append(vars, variable{Name: fmt.Sprintf("V%X", x), Value: fmt.Sprintf("0x%02X", v)})
+		}
+		vars = append(vars,
+			variable{Name: "I", Value: reference(int(state.I)), MemoryReference: reference(int(state.I))},
+			variable{Name: "PC", Value: reference(int(state.PC)), MemoryReference: reference(int(state.PC))},
+			variable{Name: "SP", Value: strconv.Itoa(int(state.SP))},
+			variable{Name: "DT", Value: fmt.Sprintf("0x%02X", state.DT)},
+			variable{Name: "ST", Value: fmt.Sprintf("0x%02X", state.ST)},
+		)
+	case stackReference:
+		for i := 0; i < int(state.SP); i++ {
+			vars = append(vars, variable{Name: fmt.Sprintf("[%d]", i), Value: reference(int(state.Stack[i])), MemoryReference: reference(int(state.Stack[i]))})
+		}
+	}
+	return vars
+}
+
+// step executes the first instruction of a step request, then sets the condition to stop on.
+func (s *Server) step(kind string) {
+	start := s.vm.State()
+	startLine, hasLine := s.line(start.PC)
+
+	s.paused = false
+	s.until = nil
+	if err := s.vm.Step(); err != nil {
+		s.stopOn(err)
+		return
+	}
+
+	// Without source lines each instruction is a step
+	newLine := func() bool {
+		line, ok := s.line(s.vm.State().PC)
+		return !hasLine || ok && line != startLine
+	}
+	switch kind {
+	case "stepIn":
+		s.until = newLine
+	case "next":
+		s.until = func() bool { return s.vm.State().SP <= start.SP && newLine() }
+	case "stepOut":
+		s.until = func() bool { return s.vm.State().SP < start.SP }
+	}
+
+	if s.until() {
+		s.stop("step", "")
+	}
+}
+
+// line return the source line of the instruction at addr.
+func (s *Server) line(addr uint16) (symbols.Line, bool) {
+	if s.symbols == nil {
+		return symbols.Line{}, false
+	}
+	return s.symbols.LineOf(addr)
+}
+
+// stopOn pauses the program after an error of the machine.
+func (s *Server) stopOn(err error) {
+	var b *chip8.Break
+	switch {
+	case !errors.As(err, &b):
+		s.stop("exception", err.Error())
+	case b.Kind == chip8.Breakpoint:
+		s.stop("breakpoint", "")
+	default:
+		s.stop("data breakpoint", b.Error())
+	}
+}
+
+// stop pauses the program and notifies the client.
+func (s *Server) stop(reason, text string) {
+	s.paused = true
+	s.until = nil
+	body := map[string]interface{}{"reason": reason, "threadId": threadID, "allThreadsStopped": true}
+	if text != "" {
+		body["text"] = text
+	}
+	s.emit("stopped", body)
+}
+
+func (s *Server) readMemory(args readMemoryArguments) (interface{}, error) {
+	addr, err := parseReference(args.MemoryReference)
+	if err != nil {
+		return nil, err
+	}
+	addr += args.Offset
+
+	var data []byte
+	for i := 0; i < args.Count && addr+i >= 0 && addr+i < s.vm.MemorySize(); i++ {
+		b, err := s.vm.Peek(uint16(addr + i))
+		if err != nil {
+			break
+		}
+		data = append(data, b)
+	}
+
+	return map[string]interface{}{
+		"address":         reference(addr),
+		"data":            base64.StdEncoding.EncodeToString(data),
+		"unreadableBytes": args.Count - len(data),
+	}, nil
+}
+
+func (s *Server) writeMemory(args writeMemoryArguments) (interface{}, error) {
+	addr, err := parseReference(args.MemoryReference)
+	if err != nil {
+		return nil, err
+	}
+	addr += args.Offset
+
+	data, err := base64.StdEncoding.DecodeString(args.Data)
+	if err != nil {
+		return nil, err
+	}
+	if addr < 0 || addr+len(data) > s.vm.MemorySize() {
+		return nil, fmt.Errorf("address out of memory: %v", reference(addr))
+	}
+
+	for i, b := range data {
+		if err := s.vm.Poke(uint16(addr+i), b); err != nil {
+			return nil, err
+		}
+	}
+	return map[string]int{"bytesWritten": len(data)}, nil
+}
+
+// disassemble return count instructions starting offset instructions from addr.
+// The instructions being 2 bytes long the ones before addr are found by going back 2 bytes each.
+func (s *Server) disassemble(addr, offset, count int) []map[string]interface{} {
+	addr += offset * 2
+	instructions := []map[string]interface{}{}
+	for i := 0; i < count; i++ {
+		ins := map[string]interface{}{"address": reference(addr), "instruction": "??"}
+		if addr >= 0 && addr < s.vm.MemorySize() {
+			if text, size, err := s.vm.Disassemble(uint16(addr)); err == nil {
+				var raw []string
+				for k := 0; k < size; k++ {
+					b, _ := s.vm.Peek(uint16(addr + k))
+					raw = append(raw, fmt.Sprintf("%02X", b))
+				}
+				ins["instruction"], ins["instructionBytes"] = text, strings.Join(raw, " ")
+				if l, ok := s.line(uint16(addr)); ok {
+					ins["location"], ins["line"] = source{Path: l.File}, l.Line
+				}
+				instructions = append(instructions, ins)
+				addr += size
+				continue
+			}
+		}
+		instructions = append(instructions, ins)
+		addr += 2
+	}
+	return instructions
+}
+
+// emit queues an event, sent after the response to the request being handled.
+func (s *Server) emit(name string, body interface{}) {
+	s.events = append(s.events, event{Type: "event", Event: name, Body: body})
+}
+
+// flush sends the queued events.
+func (s *Server) flush() {
+	for i := range s.events {
+		s.write(&s.events[i])
+	}
+	s.events = nil
+}
+
+// write sends a response or an event, a connection failing ends the session.
+func (s *Server) write(msg interface{}) {
+	s.seq++
+	switch m := msg.(type) {
+	case *response:
+		m.Seq = s.seq
+	case *event:
+		m.Seq = s.seq
+	}
+	if err := writeMessage(s.w, msg); err != nil {
+		s.ended = true
+	}
+}
+
+// reference return the memory reference of an address.
+func reference(addr int) string {
+	return fmt.Sprintf("0x%04X", addr)
+}
+
+// parseReference return the address of a memory reference.
+func parseReference(ref string) (int, error) {
+	addr, err := strconv.ParseInt(ref, 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid memory reference: %v", ref)
+	}
+	return int(addr), nil
+}
@@ -0,0 +1,207 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// The program calls a subroutine then counts in v0 forever.
+var program = []byte{
+	0x60, 0x05, // 0x200 main: v0 := 5
+	0x22, 0x08, // 0x202 sub
+	0x70, 0x01, // 0x204 loop: v0 += 1
+	0x12, 0x04, // 0x206 jump loop
+	0x61, 0x02, // 0x208 sub: v1 := 2
+	0x00, 0xEE, // 0x20A return
+}
+
+const programSymbols = `{
+  "version": 1,
+  "labels": {"main": 512, "loop": 516, "sub": 520},
+  "lines": [
+    {"address": 512, "file": "game.8o", "line": 1},
+    {"address": 514, "file": "game.8o", "line": 2},
+    {"address": 516, "file": "game.8o", "line": 3},
+    {"address": 518, "file": "game.8o", "line": 4},
+    {"address": 520, "file": "game.8o", "line": 6},
+    {"address": 522, "file": "game.8o", "line": 7}
+  ]
+}`
+
+// client is the editor side of a debug session.
+type client struct {
+	t        *testing.T
+	conn     io.Writer
+	server   *Server
+	seq      int
+	messages chan map[string]interface{}
+}
+
+func newClient(t *testing.T) *client {
+	conn, serverConn := net.Pipe()
+	c := &client{t: t, conn: conn, server: NewServer(serverConn), messages: make(chan map[string]interface{}, 64)}
+	go func() {
+		r := bufio.NewReader(conn)
+		for {
+			var msg map[string]interface{}
+			if err := readMessage(r, &msg); err != nil {
+				close(c.messages)
+				return
+			}
+			c.messages <- msg
+		}
+	}()
+	return c
+}
+
+func (c *client) send(command string, args interface{}) {
+	c.seq++
+	data, err := json.Marshal(args)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if err := writeMessage(c.conn, request{Seq: c.seq, Type: "request", Command: command, Arguments: data}); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// expect waits for a response to command or an event named so, running frames meanwhile.
+// The messages received before are dropped.
+func (c *client) expect(kind, name string) map[string]interface{} {
+	c.t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg, ok := <-c.messages:
+			if !ok {
+				c.t.Fatalf("connection closed while waiting for %v %v", kind, name)
+			}
+			if msg["type"] == kind && (msg["command"] == name || msg["event"] == name) {
+				if kind == "response" && msg["success"] != true {
+					c.t.Fatalf("%v failed: %v", name, msg["message"])
+				}
+				body, _ := msg["body"].(map[string]interface{})
+				return body
+			}
+		case <-timeout:
+			c.t.Fatalf("timeout while waiting for %v %v", kind, name)
+		default:
+			if c.server.vm != nil {
+				if _, _, err := c.server.Frame([16]bool{}); err != nil && err != ErrDisconnected {
+					c.t.Fatalf("Server.Frame() error = %v", err)
+				}
+			}
+		}
+	}
+}
+
+func TestServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rom := filepath.Join(dir, "game.ch8")
+	sym := filepath.Join(dir, "game.sym")
+	if err := ioutil.WriteFile(rom, program, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(sym, []byte(programSymbols), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newClient(t)
+	c.send("initialize", map[string]string{"adapterID": "chip8"})
+	c.send("launch", map[string]interface{}{"program": rom, "symbols": sym, "platform": "vip", "stopOnEntry": true})
+	vm, platform, err := c.server.Launch()
+	if err != nil {
+		t.Fatalf("Server.Launch() error = %v", err)
+	}
+	if vm == nil || platform.Name != "vip" {
+		t.Fatalf("Server.Launch() = %v, %v, want the vip platform", vm, platform.Name)
+	}
+	c.expect("event", "initialized")
+
+	source := map[string]string{"path": filepath.Join(dir, "game.8o")}
+	c.send("setBreakpoints", map[string]interface{}{"source": source, "breakpoints": []map[string]int{{"line": 6}, {"line": 5}}})
+	body := c.expect("response", "setBreakpoints")
+	breakpoints := body["breakpoints"].([]interface{})
+	if breakpoints[0].(map[string]interface{})["verified"] != true || breakpoints[1].(map[string]interface{})["verified"] == true {
+		t.Errorf("setBreakpoints = %v, want the line 6 verified and not the empty line 5", breakpoints)
+	}
+
+	c.send("configurationDone", nil)
+	if body := c.expect("event", "stopped"); body["reason"] != "entry" {
+		t.Errorf("stopped reason = %v, want entry", body["reason"])
+	}
+
+	c.send("continue", nil)
+	if body := c.expect("event", "stopped"); body["reason"] != "breakpoint" {
+		t.Errorf("stopped reason = %v, want breakpoint", body["reason"])
+	}
+
+	c.send("stackTrace", map[string]int{"threadId": threadID})
+	frames := c.expect("response", "stackTrace")["stackFrames"].([]interface{})
+	if len(frames) != 2 {
+		t.Fatalf("stackTrace = %v, want 2 frames", frames)
+	}
+	for i, want := range []struct {
+		name string
+		line float64
+	}{{"sub", 6}, {"main", 2}} {
+		f := frames[i].(map[string]interface{})
+		if f["name"] != want.name || f["line"] != want.line {
+			t.Errorf("frame %d = %v %v, want %v %v", i, f["name"], f["line"], want.name, want.line)
+		}
+	}
+
+	c.send("variables", map[string]int{"variablesReference": registersReference})
+	vars := c.expect("response", "variables")["variables"].([]interface{})
+	if v0 := vars[0].(map[string]interface{}); v0["name"] != "V0" || v0["value"] != "0x05" {
+		t.Errorf("variables V0 = %v, want 0x05", v0)
+	}
+
+	// Stepping out of the subroutine stops on the line after the call
+	c.send("stepOut", map[string]int{"threadId": threadID})
+	c.expect("event", "stopped")
+	if pc := c.server.vm.State().PC; pc != 0x204 {
+		t.Errorf("PC after stepOut = 0x%04X, want 0x0204", pc)
+	}
+
+	c.send("readMemory", map[string]interface{}{"memoryReference": "0x200", "count": 4})
+	if data := c.expect("response", "readMemory")["data"]; data != "YAUiCA==" {
+		t.Errorf("readMemory data = %v, want the 4 first bytes of the program", data)
+	}
+
+	c.send("disassemble", map[string]interface{}{"memoryReference": "0x204", "instructionOffset": -1, "instructionCount": 2})
+	instructions := c.expect("response", "disassemble")["instructions"].([]interface{})
+	if got := instructions[0].(map[string]interface{})["instruction"]; got != "CALL 0x208" {
+		t.Errorf("disassemble = %v, want CALL 0x208", got)
+	}
+
+	c.send("disconnect", nil)
+	c.expect("response", "disconnect")
+	if _, _, err := c.server.Frame([16]bool{}); err != ErrDisconnected {
+		t.Errorf("Server.Frame() after disconnect error = %v, want %v", err, ErrDisconnected)
+	}
+}
+
+func TestServer_Launch(t *testing.T) {
+	c := newClient(t)
+	c.send("launch", map[string]interface{}{"program": "missing.ch8"})
+	c.send("disconnect", nil)
+	if _, _, err := c.server.Launch(); err != ErrDisconnected {
+		t.Errorf("Server.Launch() error = %v, want %v", err, ErrDisconnected)
+	}
+	if msg := <-c.messages; msg["success"] != false {
+		t.Errorf("launch of a missing program = %v, want a failure", msg)
+	}
+}
@@ -0,0 +1,253 @@
+package disasm
+
+import (
+	"fmt"
+
+	"github.com/Bit-Doctor/emulation/pkg/chip8"
+)
+
+// Flow is the way an instruction passes control to the next one.
+type Flow int
+
+const (
+	// Sequential instructions are followed by the next instruction in memory.
+	Sequential Flow = iota
+	// Jump continues at the target.
+	Jump
+	// Call continues at the target, then after the call when the subroutine returns.
+	Call
+	// Return continues after the last call.
+	Return
+	// Skip is followed by the next instruction or the one after.
+	Skip
+	// Exit stops the interpreter.
+	Exit
+	// ComputedJump continues at the target plus a register, which is unknown until the program runs.
+	ComputedJump
+)
+
+// Instruction is a decoded instruction.
+type Instruction struct {
+	Address uint16
+	Opcode  uint16
+	// The address following the XO-CHIP long load, F000 nnnn.
+	Long uint16
+	// The size in bytes, 4 for the long load and 2 for the others.
+	Size int
+	Flow Flow
+	// The destination of jumps and calls, or the base of a computed jump.
+	Target uint16
+
+	set chip8.InstructionSet
+	// The size of the instruction skipped.
+	skipSize int
+}
+
+// Decode return the instruction at the start of code, which is located at addr.
+// The bytes following the instruction are needed to know the size of the instruction a skip jumps over.
+// It return an error when the instruction is not supported by the instruction set.
+func Decode(code []byte, addr uint16, set chip8.InstructionSet) (Instruction, error) {
+	if len(code) < 2 {
+		return Instruction{}, fmt.Errorf("truncated instruction at 0x%04X", addr)
+	}
+
+	op := uint16(code[0])<<8 | uint16(code[1])
+	ins := Instruction{Address: addr, Opcode: op, Size: 2, set: set}
+	x := op >> 8 & 0xF
+
+	switch {
+	case op == 0xF000 && set >= chip8.XOCHIP:
+		if len(code) < 4 {
+			return Instruction{}, fmt.Errorf("truncated instruction at 0x%04X", addr)
+		}
+		ins.Long, ins.Size = uint16(code[2])<<8|uint16(code[3]), 4
+	case op == 0x00EE:
+		ins.Flow = Return
+	case op == 0x00FD && set >= chip8.SCHIP10:
+		ins.Flow = Exit
+	case op&0xF000 == 0x1000:
+		ins.Flow, ins.Target = Jump, op&0xFFF
+	case op&0xF000 == 0x2000:
+		ins.Flow, ins.Target = Call, op&0xFFF
+	case op&0xF000 == 0xB000:
+		ins.Flow, ins.Target = ComputedJump, op&0xFFF
+	case op&0xF000 == 0x3000, op&0xF000 == 0x4000, op&0xF00F == 0x5000, op&0xF00F == 0x9000, op&0xF0FF == 0xE09E, op&0xF0FF == 0xE0A1:
+		ins.Flow, ins.skipSize = Skip, 2
+		if set >= chip8.XOCHIP && len(code) >= 4 && code[2] == 0xF0 && code[3] == 0x00 {
+			ins.skipSize = 4
+		}
+	case op&0xF0FF == 0xF075 || op&0xF0FF == 0xF085:
+		if set < chip8.SCHIP10 || x >= 8 && set < chip8.XOCHIP {
+			return Instruction{}, fmt.Errorf("opcode not supported: 0x%04X", op)
+		}
+	}
+
+	if ins.Mnemonic(nil) == "" {
+		return Instruction{}, fmt.Errorf("opcode not supported: 0x%04X", op)
+	}
+	return ins, nil
+}
+
+// Successors return the addresses of the instructions which may be executed after this one, as far as they are known.
+// The instructions following a call are included, but not those following a return.
+func (i Instruction) Successors() []uint16 {
+	next := i.Address + uint16(i.Size)
+	switch i.Flow {
+	case Jump:
+		return []uint16{i.Target}
+	case Call:
+		return []uint16{i.Target, next}
+	case Skip:
+		return []uint16{next, next + uint16(i.skipSize)}
+	case Return, Exit, ComputedJump:
+		return nil
+	}
+	return []uint16{next}
+}
+
+// Index return the address loaded in the index register by the instruction, if it loads one.
+func (i Instruction) Index() (uint16, bool) {
+	switch {
+	case i.Opcode&0xF000 == 0xA000:
+		return i.Opcode & 0xFFF, true
+	case i.Size == 4:
+		return i.Long, true
+	}
+	return 0, false
+}
+
+// String return the instruction in the syntax of Octo, with numeric addresses.
+func (i Instruction) String() string {
+	return i.Mnemonic(nil)
+}
+
+// Mnemonic return the instruction in the syntax of Octo, naming the addresses with label.
+// If label is nil or return an empty string the address is written as a number.
+func (i Instruction) Mnemonic(label func(addr uint16) string) string {
+	op := i.Opcode
+	nnn := op & 0xFFF
+	kk := op & 0xFF
+	n := op & 0xF
+	x := op >> 8 & 0xF
+	y := op >> 4 & 0xF
+	set := i.set
+
+	addr := func(a uint16) string {
+		if label != nil {
+			if name := label(a); name != "" {
+				return name
+			}
+		}
+		return fmt.Sprintf("0x%03X", a)
+	}
+
+	switch {
+	case op&0xFFF0 == 0x00C0 && set >= chip8.SCHIP11:
+		return fmt.Sprintf("scroll-down %d", n)
+	case op&0xFFF0 == 0x00D0 && set >= chip8.XOCHIP:
+		return fmt.Sprintf("scroll-up %d", n)
+	case op == 0x00E0:
+		return "clear"
+	case op == 0x00EE:
+		return "return"
+	case op == 0x00FB && set >= chip8.SCHIP11:
+		return "scroll-right"
+	case op == 0x00FC && set >= chip8.SCHIP11:
+		return "scroll-left"
+	case op == 0x00FD && set >= chip8.SCHIP10:
+		return "exit"
+	case op == 0x00FE && set >= chip8.SCHIP10:
+		return "lores"
+	case op == 0x00FF && set >= chip8.SCHIP10:
+		return "hires"
+	case op&0xF000 == 0x0000:
+		return "" // Machine code routines are not supported
+	case op&0xF000 == 0x1000:
+		return "jump " + addr(nnn)
+	case op&0xF000 == 0x2000:
+		if label != nil && label(nnn) != "" {
+			return label(nnn)
+		}
+		return ":call " + addr(nnn)
+	// The skips are written as the condition executing the next instruction
+	case op&0xF000 == 0x3000:
+		return fmt.Sprintf("if v%x != 0x%02X then", x, kk)
+	case op&0xF000 == 0x4000:
+		return fmt.Sprintf("if v%x == 0x%02X then", x, kk)
+	case op&0xF00F == 0x5000:
+		return fmt.Sprintf("if v%x != v%x then", x, y)
+	case op&0xF00F == 0x5002 && set >= chip8.XOCHIP:
+		return fmt.Sprintf("save v%x - v%x", x, y)
+	case op&0xF00F == 0x5003 && set >= chip8.XOCHIP:
+		return fmt.Sprintf("load v%x - v%x", x, y)
+	case op&0xF000 == 0x6000:
+		return fmt.Sprintf("v%x := 0x%02X", x, kk)
+	case op&0xF000 == 0x7000:
+		return fmt.Sprintf("v%x += 0x%02X", x, kk)
+	case op&0xF00F == 0x8000:
+		return fmt.Sprintf("v%x := v%x", x, y)
+	case op&0xF00F == 0x8001:
+		return fmt.Sprintf("v%x |= v%x", x, y)
+	case op&0xF00F == 0x8002:
+		return fmt.Sprintf("v%x &= v%x", x, y)
+	case op&0xF00F == 0x8003:
+		return fmt.Sprintf("v%x ^= v%x", x, y)
+	case op&0xF00F == 0x8004:
+		return fmt.Sprintf("v%x += v%x", x, y)
+	case op&0xF00F == 0x8005:
+		return fmt.Sprintf("v%x -= v%x", x, y)
+	case op&0xF00F == 0x8006:
+		return fmt.Sprintf("v%x >>= v%x", x, y)
+	case op&0xF00F == 0x8007:
+		return fmt.Sprintf("v%x =- v%x", x, y)
+	case op&0xF00F == 0x800E:
+		return fmt.Sprintf("v%x <<= v%x", x, y)
+	case op&0xF00F == 0x9000:
+		return fmt.Sprintf("if v%x == v%x then", x, y)
+	case op&0xF000 == 0xA000:
+		return "i := " + addr(nnn)
+	case op&0xF000 == 0xB000:
+		return "jump0 " + addr(nnn)
+	case op&0xF000 == 0xC000:
+		return fmt.Sprintf("v%x := random 0x%02X", x, kk)
+	case op&0xF000 == 0xD000:
+		return fmt.Sprintf("sprite v%x v%x %d", x, y, n)
+	case op&0xF0FF == 0xE09E:
+		return fmt.Sprintf("if v%x -key then", x)
+	case op&0xF0FF == 0xE0A1:
+		return fmt.Sprintf("if v%x key then", x)
+	case op == 0xF000 && set >= chip8.XOCHIP:
+		return "i := long " + addr(i.Long)
+	case op&0xF0FF == 0xF001 && set >= chip8.XOCHIP:
+		return fmt.Sprintf("plane %d", x)
+	case op == 0xF002 && set >= chip8.XOCHIP:
+		return "audio"
+	case op&0xF0FF == 0xF007:
+		return fmt.Sprintf("v%x := delay", x)
+	case op&0xF0FF == 0xF00A:
+		return fmt.Sprintf("v%x := key", x)
+	case op&0xF0FF == 0xF015:
+		return fmt.Sprintf("delay := v%x", x)
+	case op&0xF0FF == 0xF018:
+		return fmt.Sprintf("buzzer := v%x", x)
+	case op&0xF0FF == 0xF01E:
+		return fmt.Sprintf("i += v%x", x)
+	case op&0xF0FF == 0xF029:
+		return fmt.Sprintf("i := hex v%x", x)
+	case op&0xF0FF == 0xF030 && set >= chip8.SCHIP10:
+		return fmt.Sprintf("i := bighex v%x", x)
+	case op&0xF0FF == 0xF033:
+		return fmt.Sprintf("bcd v%x", x)
+	case op&0xF0FF == 0xF055:
+		return fmt.Sprintf("save v%x", x)
+	case op&0xF0FF == 0xF065:
+		return fmt.Sprintf("load v%x", x)
+	case op&0xF0FF == 0xF03A && set >= chip8.XOCHIP:
+		return fmt.Sprintf("pitch := v%x", x)
+	case op&0xF0FF == 0xF075 && set >= chip8.SCHIP10:
+		return fmt.Sprintf("saveflags v%x", x)
+	case op&0xF0FF == 0xF085 && set >= chip8.SCHIP10:
+		return fmt.Sprintf("loadflags v%x", x)
+	}
+	return ""
+}
@@ -0,0 +1,215 @@
+// Package disasm turns Chip-8 programs back into Octo source code.
+//
+// The program is disassembled by recursive descent: starting from the entry point, the jumps, calls and skips are
+// followed to find the instructions, and the bytes never reached are left as data, usually sprites.
+// The targets of jumps with an offset, jump0, are unknown so the code they lead to is only found if reached otherwise.
+package disasm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/Bit-Doctor/emulation/pkg/chip8"
+)
+
+// The kinds of labels, by priority when an address has several uses.
+const (
+	dataLabel = iota + 1
+	jumpLabel
+	subLabel
+	mainLabel
+)
+
+var labelPrefix = map[int]string{
+	dataLabel: "data-",
+	jumpLabel: "label-",
+	subLabel:  "sub-",
+}
+
+// Program is a disassembled program.
+type Program struct {
+	// Origin is the address of the first byte of the program, which is also the entry point.
+	Origin uint16
+	rom    []byte
+	// The instructions indexed by their offset in the ROM.
+	code map[int]Instruction
+	// Whether the bytes of the ROM belong to an instruction.
+	isCode []bool
+	labels map[uint16]int
+}
+
+// Disassemble finds the code of a program loaded at origin, where it starts running.
+func Disassemble(rom []byte, origin uint16, set chip8.InstructionSet) *Program {
+	p := &Program{
+		Origin: origin,
+		rom:    rom,
+		code:   make(map[int]Instruction),
+		isCode: make([]bool, len(rom)),
+		labels: make(map[uint16]int),
+	}
+
+	p.label(origin, mainLabel)
+	todo := []uint16{origin}
+	for len(todo) > 0 {
+		addr := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+
+		offset := int(addr) - int(origin)
+		if offset < 0 || offset >= len(rom) {
+			continue
+		}
+		if _, ok := p.code[offset]; ok || p.isCode[offset] {
+			continue
+		}
+
+		ins, err := Decode(rom[offset:], addr, set)
+		if err != nil || p.overlaps(offset, ins.Size) {
+			continue
+		}
+		p.code[offset] = ins
+		for i := 0; i < ins.Size; i++ {
+			p.isCode[offset+i] = true
+		}
+
+		switch ins.Flow {
+		case Call:
+			p.label(ins.Target, subLabel)
+		case Jump, ComputedJump:
+			p.label(ins.Target, jumpLabel)
+		}
+		if index, ok := ins.Index(); ok {
+			p.label(index, dataLabel)
+		}
+
+		// The successors are pushed in reverse so the fall through is explored first
+		next := ins.Successors()
+		for i := len(next) - 1; i >= 0; i-- {
+			todo = append(todo, next[i])
+		}
+	}
+
+	// The labels in the middle of an instruction cannot be written
+	for addr := range p.labels {
+		offset := int(addr) - int(origin)
+		if _, ok := p.code[offset]; !ok && p.isCode[offset] {
+			delete(p.labels, addr)
+		}
+	}
+	return p
+}
+
+// label names addr with a label of the given kind, if it is in the program and has no label of higher priority.
+func (p *Program) label(addr uint16, kind int) {
+	offset := int(addr) - int(p.Origin)
+	if offset < 0 || offset >= len(p.rom) {
+		return
+	}
+	if kind > p.labels[addr] {
+		p.labels[addr] = kind
+	}
+}
+
+// overlaps tells whether the bytes of the ROM in [offset, offset+size) are already part of an instruction.
+func (p *Program) overlaps(offset, size int) bool {
+	for i := offset; i < offset+size; i++ {
+		if p.isCode[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// Instructions return the instructions found, by address.
+func (p *Program) Instructions() []Instruction {
+	instructions := make([]Instruction, 0, len(p.code))
+	for _, ins := range p.code {
+		instructions = append(instructions, ins)
+	}
+	sort.Slice(instructions, func(i, j int) bool { return instructions[i].Address < instructions[j].Address })
+	return instructions
+}
+
+// Instruction return the instruction found at addr, if any.
+func (p *Program) Instruction(addr uint16) (Instruction, bool) {
+	ins, ok := p.code[int(addr)-int(p.Origin)]
+	return ins, ok
+}
+
+// IsCode tells whether the byte at addr belongs to an instruction.
+func (p *Program) IsCode(addr uint16) bool {
+	offset := int(addr) - int(p.Origin)
+	return offset >= 0 && offset < len(p.rom) && p.isCode[offset]
+}
+
+// Label return the name of the label at addr, or an empty string if there is none.
+func (p *Program) Label(addr uint16) string {
+	switch kind := p.labels[addr]; kind {
+	case 0:
+		return ""
+	case mainLabel:
+		return "main"
+	default:
+		return fmt.Sprintf("%v%03x", labelPrefix[kind], addr)
+	}
+}
+
+// The number of data bytes written per line.
+const bytesPerLine = 8
+
+// Write writes the program as Octo source code.
+// Assembling it gives back the original ROM.
+func (p *Program) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if p.Origin != 0x200 {
+		fmt.Fprintf(bw, ":org 0x%03X\n", p.Origin)
+	}
+
+	var data []byte
+	flush := func() {
+		if len(data) == 0 {
+			return
+		}
+		bw.WriteString("\t")
+		for i, b := range data {
+			if i > 0 {
+				bw.WriteString(" ")
+			}
+			fmt.Fprintf(bw, "0x%02X", b)
+		}
+		bw.WriteString("\n")
+		data = data[:0]
+	}
+
+	conditional := false
+	for offset := 0; offset < len(p.rom); {
+		addr := p.Origin + uint16(offset)
+		if name := p.Label(addr); name != "" {
+			flush()
+			fmt.Fprintf(bw, ": %v\n", name)
+		}
+
+		ins, ok := p.code[offset]
+		if !ok {
+			data = append(data, p.rom[offset])
+			if len(data) == bytesPerLine {
+				flush()
+			}
+			conditional = false
+			offset++
+			continue
+		}
+
+		flush()
+		indent := "\t"
+		if conditional {
+			indent = "\t\t"
+		}
+		fmt.Fprintf(bw, "%v%v\n", indent, ins.Mnemonic(p.Label))
+		conditional = ins.Flow == Skip
+		offset += ins.Size
+	}
+	flush()
+	return bw.Flush()
+}
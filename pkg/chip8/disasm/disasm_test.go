@@ -0,0 +1,116 @@
+package disasm
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/Bit-Doctor/emulation/pkg/chip8"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     []byte
+		set      chip8.InstructionSet
+		want     string
+		wantFlow Flow
+		wantNext []uint16
+		wantErr  bool
+	}{
+		{"sequential", []byte{0x60, 0x2A}, chip8.CHIP8, "v0 := 0x2A", Sequential, []uint16{0x202}, false},
+		{"jump", []byte{0x12, 0x40}, chip8.CHIP8, "jump 0x240", Jump, []uint16{0x240}, false},
+		{"call", []byte{0x23, 0x00}, chip8.CHIP8, ":call 0x300", Call, []uint16{0x300, 0x202}, false},
+		{"return", []byte{0x00, 0xEE}, chip8.CHIP8, "return", Return, nil, false},
+		{"skip", []byte{0x3A, 0x01, 0x60, 0x00}, chip8.CHIP8, "if va != 0x01 then", Skip, []uint16{0x202, 0x204}, false},
+		{"skip long", []byte{0xE1, 0x9E, 0xF0, 0x00, 0x12, 0x34}, chip8.XOCHIP, "if v1 -key then", Skip, []uint16{0x202, 0x206}, false},
+		{"skip long unsupported", []byte{0xE1, 0x9E, 0xF0, 0x00}, chip8.SCHIP11, "if v1 -key then", Skip, []uint16{0x202, 0x204}, false},
+		{"computed jump", []byte{0xB3, 0x00}, chip8.CHIP8, "jump0 0x300", ComputedJump, nil, false},
+		{"exit", []byte{0x00, 0xFD}, chip8.SCHIP10, "exit", Exit, nil, false},
+		{"long load", []byte{0xF0, 0x00, 0x12, 0x34}, chip8.XOCHIP, "i := long 0x1234", Sequential, []uint16{0x204}, false},
+		{"flags", []byte{0xF8, 0x75}, chip8.XOCHIP, "saveflags v8", Sequential, []uint16{0x202}, false},
+		{"flags register", []byte{0xF8, 0x75}, chip8.SCHIP11, "", 0, nil, true},
+		{"unsupported", []byte{0x00, 0xFF}, chip8.CHIP8, "", 0, nil, true},
+		{"machine code", []byte{0x01, 0x23}, chip8.CHIP8, "", 0, nil, true},
+		{"truncated", []byte{0x60}, chip8.CHIP8, "", 0, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decode(tt.code, 0x200, tt.set)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Decode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.String() != tt.want || got.Flow != tt.wantFlow {
+				t.Errorf("Decode() = %q flow %v, want %q flow %v", got, got.Flow, tt.want, tt.wantFlow)
+			}
+			if next := got.Successors(); !reflect.DeepEqual(next, tt.wantNext) {
+				t.Errorf("Instruction.Successors() = %v, want %v", next, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestDisassemble(t *testing.T) {
+	rom := []byte{
+		0xA2, 0x10, // 0x200 i := data
+		0x22, 0x0C, // 0x202 call
+		0x3F, 0x00, // 0x204 skip
+		0x12, 0x0A, // 0x206 jump
+		0x12, 0x04, // 0x208 jump back
+		0x00, 0xE0, // 0x20A clear, reached by the skip
+		0xD0, 0x18, // 0x20C sprite
+		0x00, 0xEE, // 0x20E return
+		0xFF, 0x81, 0x81, 0xFF, // 0x210 sprite data
+		0x81, 0x81, 0x81, 0xFF,
+		0x00, 0x00, // 0x218 never reached
+	}
+
+	want := `: main
+	i := data-210
+	sub-20c
+: label-204
+	if vf != 0x00 then
+		jump label-20a
+	jump label-204
+: label-20a
+	clear
+: sub-20c
+	sprite v0 v1 8
+	return
+: data-210
+	0xFF 0x81 0x81 0xFF 0x81 0x81 0x81 0xFF
+	0x00 0x00
+`
+
+	p := Disassemble(rom, 0x200, chip8.CHIP8)
+	var buf bytes.Buffer
+	if err := p.Write(&buf); err != nil {
+		t.Fatalf("Program.Write() error = %v", err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("Program.Write() =\n%v\nwant\n%v", got, want)
+	}
+
+	if got := len(p.Instructions()); got != 8 {
+		t.Errorf("len(Program.Instructions()) = %v, want 8", got)
+	}
+	if p.IsCode(0x210) || !p.IsCode(0x20F) {
+		t.Errorf("Program.IsCode() does not separate the code from the data")
+	}
+}
+
+func TestDisassemble_origin(t *testing.T) {
+	// An unsupported instruction ends the path, the bytes left are data
+	p := Disassemble([]byte{0x16, 0x02, 0x00, 0xFF}, 0x600, chip8.CHIP8)
+	var buf bytes.Buffer
+	if err := p.Write(&buf); err != nil {
+		t.Fatalf("Program.Write() error = %v", err)
+	}
+	want := ":org 0x600\n: main\n\tjump label-602\n: label-602\n\t0x00 0xFF\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Program.Write() = %q, want %q", got, want)
+	}
+}
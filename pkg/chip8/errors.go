@@ -0,0 +1,56 @@
+package chip8
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// The errors stopping the execution of a program, returned wrapped in a *MachineError.
+var (
+	// ErrSegmentationFault is returned when the program counter is out of memory.
+	ErrSegmentationFault = errors.New("segmentation fault")
+	// ErrStackOverflow is returned when calling a subroutine with a full stack.
+	ErrStackOverflow = errors.New("stack overflow")
+	// ErrStackUnderflow is returned when returning from a subroutine with an empty stack.
+	ErrStackUnderflow = errors.New("stack underflow")
+	// ErrUnknownOpcode is returned for an instruction not supported by the instruction set of the machine.
+	ErrUnknownOpcode = errors.New("unknown opcode")
+)
+
+// MachineError is the error returned when the machine cannot execute an instruction.
+// It wraps one of the errors above, so it can be checked with errors.Is.
+type MachineError struct {
+	Err error
+	// The address and the opcode of the instruction, the opcode being 0 when it is out of memory.
+	PC     uint16
+	Opcode uint16
+	// Addr is the first address out of memory of a segmentation fault, PC when the instruction itself is.
+	Addr uint16
+	// State is the snapshot of the registers when the error happened, its program counter is past the instruction.
+	State State
+}
+
+func (e *MachineError) Error() string {
+	if errors.Is(e.Err, ErrSegmentationFault) {
+		if e.Addr == e.PC {
+			return fmt.Sprintf("%v at 0x%04X", e.Err, e.PC)
+		}
+		return fmt.Sprintf("%v: 0x%04X at 0x%04X accessing 0x%04X", e.Err, e.Opcode, e.PC, e.Addr)
+	}
+	return fmt.Sprintf("%v: 0x%04X at 0x%04X", e.Err, e.Opcode, e.PC)
+}
+
+func (e *MachineError) Unwrap() error {
+	return e.Err
+}
+
+// Registers return the registers of the snapshot as text, on three lines.
+func (e *MachineError) Registers() string {
+	regs := make([]string, len(e.State.V))
+	for x, v := range e.State.V {
+		regs[x] = fmt.Sprintf("V%X=%02X", x, v)
+	}
+	return fmt.Sprintf("%v\n%v\nI=%04X PC=%04X SP=%X DT=%02X ST=%02X", strings.Join(regs[:8], " "),
+		strings.Join(regs[8:], " "), e.State.I, e.State.PC, e.State.SP, e.State.DT, e.State.ST)
+}
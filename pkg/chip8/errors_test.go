@@ -0,0 +1,115 @@
+package chip8
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestChip8_Step_errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		program []byte
+		pc      uint16
+		sp      byte
+		want    error
+		wantErr string
+		wantOp  uint16
+	}{
+		{name: "segmentation fault", pc: 0xFFFF, want: ErrSegmentationFault, wantErr: "segmentation fault at 0xFFFF"},
+		{name: "stack overflow", program: []byte{0x22, 0x00}, sp: 16, want: ErrStackOverflow,
+			wantErr: "stack overflow: 0x2200 at 0x0200", wantOp: 0x2200},
+		{name: "stack underflow", program: []byte{0x00, 0xEE}, want: ErrStackUnderflow,
+			wantErr: "stack underflow: 0x00EE at 0x0200", wantOp: 0x00EE},
+		{name: "unknown opcode", program: []byte{0xF0, 0x88}, want: ErrUnknownOpcode,
+			wantErr: "unknown opcode: 0xF088 at 0x0200", wantOp: 0xF088},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(PlatformOcto)
+			if err := c.LoadGame(tt.program); err != nil {
+				t.Fatal(err)
+			}
+			if tt.pc != 0 {
+				c.pc = tt.pc
+			}
+			c.sp = tt.sp
+			c.v[3] = 0x42
+
+			err := c.Step()
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Chip8.Step() error = %v, want %v", err, tt.want)
+			}
+			var e *MachineError
+			if !errors.As(err, &e) {
+				t.Fatalf("Chip8.Step() error = %T, want *MachineError", err)
+			}
+			if err.Error() != tt.wantErr {
+				t.Errorf("MachineError.Error() = %q, want %q", err.Error(), tt.wantErr)
+			}
+			wantPC := uint16(0x200)
+			if tt.pc != 0 {
+				wantPC = tt.pc
+			}
+			if e.PC != wantPC || e.Opcode != tt.wantOp {
+				t.Errorf("MachineError PC = 0x%04X, Opcode = 0x%04X, want 0x%04X, 0x%04X", e.PC, e.Opcode, wantPC, tt.wantOp)
+			}
+			if e.State.V[3] != 0x42 || e.State.SP != tt.sp {
+				t.Errorf("MachineError.State = %+v, want V3 = 0x42 and SP = %d", e.State, tt.sp)
+			}
+		})
+	}
+}
+
+func TestMachineError_Registers(t *testing.T) {
+	e := &MachineError{Err: ErrUnknownOpcode, State: State{V: [16]byte{0x01, 0xF: 0xFF}, I: 0x300, PC: 0x202, SP: 1, DT: 2, ST: 3}}
+	want := "V0=01 V1=00 V2=00 V3=00 V4=00 V5=00 V6=00 V7=00\n" +
+		"V8=00 V9=00 VA=00 VB=00 VC=00 VD=00 VE=00 VF=FF\n" +
+		"I=0300 PC=0202 SP=1 DT=02 ST=03"
+	if got := e.Registers(); got != want {
+		t.Errorf("MachineError.Registers() = %q, want %q", got, want)
+	}
+}
+
+func TestChip8_Step_programErrors(t *testing.T) {
+	// The programs cannot crash the emulator, at worst they stop with an error
+	tests := []struct {
+		name     string
+		platform Platform
+		program  []byte
+		wantErr  string
+	}{
+		{name: "Ex9E key out of range", platform: PlatformVIP, program: []byte{0x60, 0x20, 0xE0, 0x9E}},
+		{name: "ExA1 key out of range", platform: PlatformVIP, program: []byte{0x60, 0xFF, 0xE0, 0xA1}},
+		{name: "Dxyn out of memory", platform: PlatformVIP, program: []byte{0xAF, 0xF0, 0x60, 0xFF, 0xF0, 0x1E, 0xD0, 0x11},
+			wantErr: "segmentation fault: 0xD011 at 0x0206 accessing 0x10EF"},
+		{name: "Fx33 out of memory", platform: PlatformVIP, program: []byte{0xAF, 0xFF, 0xF0, 0x33},
+			wantErr: "segmentation fault: 0xF033 at 0x0202 accessing 0x1000"},
+		{name: "1nnn out of memory", platform: PlatformVIP, program: []byte{0x1F, 0xFF},
+			wantErr: "segmentation fault at 0x0FFF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(tt.platform)
+			if err := c.LoadGame(tt.program); err != nil {
+				t.Fatal(err)
+			}
+			var err error
+			for i := 0; i < 10 && err == nil; i++ {
+				err = c.Step()
+			}
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Chip8.Step() error = %v, want nil", err)
+				}
+				return
+			}
+			var e *MachineError
+			if !errors.As(err, &e) || !errors.Is(err, ErrSegmentationFault) {
+				t.Fatalf("Chip8.Step() error = %v, want a segmentation fault", err)
+			}
+			if err.Error() != tt.wantErr {
+				t.Errorf("MachineError.Error() = %q, want %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package chip8_test
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Bit-Doctor/emulation/pkg/chip8"
+	"github.com/Bit-Doctor/emulation/pkg/chip8/script"
+)
+
+var update = flag.Bool("update", false, "write the baseline of the golden frames from the display")
+
+// romsDir holds the ROMs distributed with the emulator.
+const romsDir = "../../roms"
+
+// goldenDir holds the input scripts of the ROMs, default.script for those without their own, and the baseline of the
+// hashes of their display.
+const goldenDir = "testdata/roms"
+
+// The frames at the end of which the display is hashed.
+var goldenFrames = []int{60, 180, 360, 600}
+
+// goldenPlatforms gives the platform of the ROMs that were not written for the COSMAC VIP, on which the others run.
+// Syzygy and Blinky rely on the shifts and loads of the CHIP-48, and Blinky takes about 700 frames to draw its maze
+// at the speed of the CHIP-48 so it runs faster to be playing by the last frames.
+var goldenPlatforms = map[string]chip8.Platform{
+	"blinky": withCyclePerFrame(chip8.PlatformCHIP48, 100),
+	"syzygy": chip8.PlatformCHIP48,
+}
+
+func withCyclePerFrame(p chip8.Platform, cycles int) chip8.Platform {
+	p.CyclePerFrame = cycles
+	return p
+}
+
+// TestGoldenFrames runs each ROM of romsDir with its input script and compares the hashes of the display at
+// goldenFrames with the baseline, which is written with -update.
+func TestGoldenFrames(t *testing.T) {
+	roms, err := filepath.Glob(filepath.Join(romsDir, "*.ch8"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roms) == 0 {
+		t.Fatalf("no ROM in %v", romsDir)
+	}
+
+	baselinePath := filepath.Join(goldenDir, "frames.golden")
+	baseline, err := readBaseline(baselinePath)
+	if err != nil && !*update {
+		t.Fatalf("cannot read the baseline, run the test with -update: %v", err)
+	}
+
+	var lines []string
+	for _, rom := range roms {
+		name := filepath.Base(rom)
+		hashes, err := runGolden(rom)
+		if err != nil {
+			t.Errorf("%v: %v", name, err)
+			continue
+		}
+		for i, frame := range goldenFrames {
+			key := fmt.Sprintf("%v %d", name, frame)
+			lines = append(lines, key+" "+hashes[i])
+			if *update {
+				continue
+			}
+			if want, ok := baseline[key]; !ok {
+				t.Errorf("%v frame %d: not in the baseline, run the test with -update", name, frame)
+			} else if hashes[i] != want {
+				t.Errorf("%v frame %d: display hash = %v, want %v", name, frame, hashes[i], want)
+			}
+		}
+	}
+
+	if *update {
+		if err := writeBaseline(baselinePath, lines); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// runGolden runs a ROM on its platform of goldenPlatforms, the VIP by default, with a fixed seed and return the hashes
+// of the display at goldenFrames.
+func runGolden(rom string) ([]string, error) {
+	data, err := ioutil.ReadFile(rom)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSuffix(filepath.Base(rom), ".ch8")
+	platform, ok := goldenPlatforms[name]
+	if !ok {
+		platform = chip8.PlatformVIP
+	}
+	c := chip8.New(platform, chip8.WithSeed(0))
+	if err := c.LoadGame(data); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(goldenDir, name+".script")
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		path = filepath.Join(goldenDir, "default.script")
+	}
+	s, err := script.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, frame := range goldenFrames {
+		s.Events = append(s.Events, script.Event{Frame: frame, Action: script.Screenshot})
+	}
+	sort.SliceStable(s.Events, func(i, j int) bool { return s.Events[i].Frame < s.Events[j].Frame })
+
+	var hashes []string
+	err = s.Run(c, goldenFrames[len(goldenFrames)-1], func(e script.Event, img image.Image) error {
+		rgba := img.(*image.RGBA)
+		hash := sha256.New()
+		fmt.Fprintf(hash, "%dx%d\n", rgba.Rect.Dx(), rgba.Rect.Dy())
+		hash.Write(rgba.Pix)
+		hashes = append(hashes, fmt.Sprintf("%x", hash.Sum(nil)))
+		return nil
+	})
+	return hashes, err
+}
+
+// readBaseline reads the hashes of a baseline, by ROM and frame.
+func readBaseline(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	baseline := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid baseline line: %q", scanner.Text())
+		}
+		baseline[fields[0]+" "+fields[1]] = fields[2]
+	}
+	return baseline, scanner.Err()
+}
+
+// writeBaseline writes the lines of a baseline, the ROM, frame and hash of each display.
+func writeBaseline(path string, lines []string) error {
+	var buf bytes.Buffer
+	buf.WriteString("# ROM, frame and SHA-256 of the display, written by go test -run GoldenFrames -update\n")
+	for _, line := range lines {
+		fmt.Fprintln(&buf, line)
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
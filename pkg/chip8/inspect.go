@@ -0,0 +1,111 @@
+package chip8
+
+import "fmt"
+
+// State is a snapshot of the registers of the machine, for the tools inspecting it.
+// The display is available through Framebuffer and the memory through Peek.
+type State struct {
+	// The general purpose registers V0 to VF.
+	V [16]byte
+	// The index register.
+	I uint16
+	// The program counter.
+	PC uint16
+	// The stack pointer, the number of return addresses on the stack.
+	SP byte
+	// The return addresses of the subroutines, only the first SP are in use.
+	Stack [16]uint16
+	// The delay timer.
+	DT byte
+	// The sound timer.
+	ST byte
+	// The state of the keys, true meaning pressed.
+	Keypad [16]bool
+	// The XO-CHIP bitplanes selected for drawing.
+	Planes byte
+	// Whether the SUPER-CHIP high resolution mode is on.
+	HiRes bool
+	// Whether the SUPER-CHIP exit instruction was executed.
+	Halted bool
+	// The user flags of the SUPER-CHIP and XO-CHIP.
+	RPL [16]byte
+	// The XO-CHIP audio pattern.
+	Pattern [16]byte
+	// The XO-CHIP audio pitch.
+	Pitch byte
+}
+
+// State return a snapshot of the registers of the machine.
+func (c *Chip8) State() State {
+	return State{
+		V:       c.v,
+		I:       c.i,
+		PC:      c.pc,
+		SP:      c.sp,
+		Stack:   c.stack,
+		DT:      c.dt,
+		ST:      c.st,
+		Keypad:  c.keypad,
+		Planes:  c.planes,
+		HiRes:   c.hires,
+		Halted:  c.halted,
+		RPL:     c.rpl,
+		Pattern: c.pattern,
+		Pitch:   c.pitch,
+	}
+}
+
+// MemorySize return the number of bytes of memory of the machine.
+func (c *Chip8) MemorySize() int {
+	return len(c.memory)
+}
+
+// Peek return the byte at the given memory address.
+func (c *Chip8) Peek(addr uint16) (byte, error) {
+	if int(addr) >= len(c.memory) {
+		return 0, fmt.Errorf("address out of memory: 0x%04X", addr)
+	}
+	return c.memory[addr], nil
+}
+
+// Poke writes a byte at the given memory address.
+func (c *Chip8) Poke(addr uint16, value byte) error {
+	if int(addr) >= len(c.memory) {
+		return fmt.Errorf("address out of memory: 0x%04X", addr)
+	}
+	c.memory[addr] = value
+	return nil
+}
+
+// SetRegister changes the value of the register Vx.
+func (c *Chip8) SetRegister(x int, value byte) error {
+	if x < 0 || x >= len(c.v) {
+		return fmt.Errorf("no such register: V%X", x)
+	}
+	c.v[x] = value
+	return nil
+}
+
+// SetIndex changes the value of the index register.
+func (c *Chip8) SetIndex(addr uint16) {
+	c.i = addr
+}
+
+// SetPC changes the address of the next instruction executed.
+func (c *Chip8) SetPC(addr uint16) error {
+	if int(addr) >= len(c.memory) {
+		return fmt.Errorf("address out of memory: 0x%04X", addr)
+	}
+	c.pc = addr
+	return nil
+}
+
+// SetDelayTimer changes the value of the delay timer.
+func (c *Chip8) SetDelayTimer(value byte) {
+	c.dt = value
+}
+
+// SetSoundTimer changes the value of the sound timer.
+func (c *Chip8) SetSoundTimer(value byte) {
+	c.st = value
+}
@@ -0,0 +1,97 @@
+package chip8
+
+import "testing"
+
+func TestChip8_State(t *testing.T) {
+	c := New(PlatformOcto)
+	if err := c.LoadGame([]byte{0x6A, 0x42, 0xA3, 0x00, 0x22, 0x08}); err != nil {
+		t.Fatalf("chip8.LoadGame() error = %v", err)
+	}
+	if _, err := c.RunCycles(3); err != nil {
+		t.Fatalf("chip8.RunCycles() error = %v", err)
+	}
+	c.SetDelayTimer(3)
+	c.SetSoundTimer(4)
+
+	s := c.State()
+	if s.V[0xA] != 0x42 || s.I != 0x300 || s.PC != 0x208 || s.SP != 1 || s.Stack[0] != 0x206 || s.DT != 3 || s.ST != 4 {
+		t.Errorf("chip8.State() = %+v", s)
+	}
+}
+
+func TestChip8_Peek(t *testing.T) {
+	tests := []struct {
+		name     string
+		platform Platform
+		addr     uint16
+		want     byte
+		wantErr  bool
+	}{
+		{"font", PlatformVIP, 0x0000, 0xF0, false},
+		{"last byte", PlatformVIP, 0x0FFF, 0x00, false},
+		{"out of memory", PlatformVIP, 0x1000, 0x00, true},
+		{"extended memory", PlatformXOCHIP, 0xFFFF, 0x00, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(tt.platform)
+			got, err := c.Peek(tt.addr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("chip8.Peek() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("chip8.Peek() = 0x%02X, want 0x%02X", got, tt.want)
+			}
+
+			err = c.Poke(tt.addr, 0xAB)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("chip8.Poke() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got, _ := c.Peek(tt.addr); !tt.wantErr && got != 0xAB {
+				t.Errorf("chip8.Peek() after chip8.Poke() = 0x%02X, want 0xAB", got)
+			}
+		})
+	}
+}
+
+func TestChip8_SetRegister(t *testing.T) {
+	tests := []struct {
+		name    string
+		x       int
+		wantErr bool
+	}{
+		{"V0", 0x0, false},
+		{"VF", 0xF, false},
+		{"negative", -1, true},
+		{"out of range", 0x10, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(PlatformOcto)
+			if err := c.SetRegister(tt.x, 0x12); (err != nil) != tt.wantErr {
+				t.Fatalf("chip8.SetRegister() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && c.State().V[tt.x] != 0x12 {
+				t.Errorf("V%X = 0x%02X, want 0x12", tt.x, c.State().V[tt.x])
+			}
+		})
+	}
+}
+
+func TestChip8_SetPC(t *testing.T) {
+	c := New(PlatformVIP)
+	if err := c.SetPC(0x0FFE); err != nil {
+		t.Errorf("chip8.SetPC() error = %v", err)
+	}
+	if err := c.SetPC(0x1000); err == nil {
+		t.Errorf("chip8.SetPC() out of memory error = nil, want an error")
+	}
+	if got := c.State().PC; got != 0x0FFE {
+		t.Errorf("PC = 0x%04X, want 0x0FFE", got)
+	}
+
+	c.SetIndex(0x0123)
+	if got := c.State().I; got != 0x0123 {
+		t.Errorf("I = 0x%04X, want 0x0123", got)
+	}
+}
@@ -1,18 +1,14 @@
 package chip8
 
-import (
-	"errors"
-	"fmt"
-	"math/bits"
-)
+import "math/bits"
 
 // Fetch return the next opCode and increment the program counter.
 // All instructions are 2 bytes long and are stored most-significant-byte first.
 // In memory, the first byte of each instruction should be located at an even addresses.
 // If a program includes sprite data, it should be padded so any instructions following it will be properly situated in RAM.
 func (c *Chip8) fetch() (uint16, error) {
-	if int(c.pc) == len(c.memory) {
-		return 0x0000, errors.New("segmentation fault")
+	if int(c.pc)+1 >= len(c.memory) {
+		return 0x0000, &MachineError{Err: ErrSegmentationFault, Addr: c.pc}
 	}
 
 	op := uint16(c.memory[c.pc])<<8 | uint16(c.memory[c.pc+1])
@@ -23,6 +19,7 @@ func (c *Chip8) fetch() (uint16, error) {
 
 // Decode and execute the provided opCode.
 // The original implementation of the Chip-8 language includes 36 different instructions.
+// The SUPER-CHIP 1.1 extension adds 10 more instructions and the XO-CHIP 7 more.
 func (c *Chip8) decodeExecute(op uint16) error {
 	// nnn or addr - A 12-bit value, the lowest 12 bits of the instruction
 	nnn := op & 0xFFF
@@ -36,10 +33,24 @@ func (c *Chip8) decodeExecute(op uint16) error {
 	y := byte(op >> 4 & 0xF)
 
 	switch {
+	case op&0xFFF0 == 0x00C0 && c.set >= SCHIP11:
+		c.scrollDown(n) // 00Cn
+	case op&0xFFF0 == 0x00D0 && c.set >= XOCHIP:
+		c.scrollUp(n) // 00Dn
 	case op == 0x00E0:
 		c.cls() // 00E0
 	case op == 0x00EE:
 		return c.ret() // 00EE
+	case op == 0x00FB && c.set >= SCHIP11:
+		c.scrollRight() // 00FB
+	case op == 0x00FC && c.set >= SCHIP11:
+		c.scrollLeft() // 00FC
+	case op == 0x00FD && c.set >= SCHIP10:
+		c.exit() // 00FD
+	case op == 0x00FE && c.set >= SCHIP10:
+		c.lowRes() // 00FE
+	case op == 0x00FF && c.set >= SCHIP10:
+		c.highRes() // 00FF
 	case op&0xF000 == 0x0000:
 		c.sys() // 0nnn
 	case op&0xF000 == 0x1000:
@@ -52,6 +63,10 @@ func (c *Chip8) decodeExecute(op uint16) error {
 		c.skipIfNotVx(x, kk) // 4xkk
 	case op&0xF00F == 0x5000:
 		c.skipIfVxVy(x, y) // 5xy0
+	case op&0xF00F == 0x5002 && c.set >= XOCHIP:
+		return c.writeRegsRange(x, y) // 5xy2
+	case op&0xF00F == 0x5003 && c.set >= XOCHIP:
+		return c.readRegsRange(x, y) // 5xy3
 	case op&0xF000 == 0x6000:
 		c.setVx(x, kk) // 6xkk
 	case op&0xF000 == 0x7000:
@@ -69,11 +84,11 @@ func (c *Chip8) decodeExecute(op uint16) error {
 	case op&0xF00F == 0x8005:
 		c.subVxVy(x, y) // 8xy5
 	case op&0xF00F == 0x8006:
-		c.shrVx(x) // 8xy6
+		c.shrVx(x, y) // 8xy6
 	case op&0xF00F == 0x8007:
 		c.subYX(x, y) // 8xy7
 	case op&0xF00F == 0x800E:
-		c.shlVx(x) // 8xyE
+		c.shlVx(x, y) // 8xyE
 	case op&0xF00F == 0x9000:
 		c.skipIfNotVcVy(x, y) // 9xy0
 	case op&0xF000 == 0xA000:
@@ -83,11 +98,17 @@ func (c *Chip8) decodeExecute(op uint16) error {
 	case op&0xF000 == 0xC000:
 		c.rndVx(x, kk) // Cxkk
 	case op&0xF000 == 0xD000:
-		c.drawSprite(x, y, n) // Dxyn
+		return c.drawSprite(x, y, n) // Dxyn
 	case op&0xF0FF == 0xE09E:
 		c.skipIfPressed(x) // Ex9E
 	case op&0xF0FF == 0xE0A1:
 		c.skipIfNotPressed(x) // ExA1
+	case op == 0xF000 && c.set >= XOCHIP:
+		return c.setILong() // F000 nnnn
+	case op&0xF0FF == 0xF001 && c.set >= XOCHIP:
+		c.selectPlanes(x) // Fn01
+	case op == 0xF002 && c.set >= XOCHIP:
+		return c.loadPattern() // F002
 	case op&0xF0FF == 0xF007:
 		c.setVxDT(x) // Fx07
 	case op&0xF0FF == 0xF00A:
@@ -100,29 +121,40 @@ func (c *Chip8) decodeExecute(op uint16) error {
 		c.addIVx(x) // Fx1E
 	case op&0xF0FF == 0xF029:
 		c.setIDigit(x) // Fx29
+	case op&0xF0FF == 0xF030 && c.set >= SCHIP10:
+		c.setIBigDigit(x) // Fx30
 	case op&0xF0FF == 0xF033:
-		c.bcd(x) // Fx33
+		return c.bcd(x) // Fx33
 	case op&0xF0FF == 0xF055:
-		c.writeRegs(x) // Fx55
+		return c.writeRegs(x) // Fx55
 	case op&0xF0FF == 0xF065:
-		c.readRegs(x) // Fx65
+		return c.readRegs(x) // Fx65
+	case op&0xF0FF == 0xF03A && c.set >= XOCHIP:
+		c.setPitch(x) // Fx3A
+	case op&0xF0FF == 0xF075 && c.set >= SCHIP10 && c.flagsAvailable(x):
+		c.writeFlags(x) // Fx75
+	case op&0xF0FF == 0xF085 && c.set >= SCHIP10 && c.flagsAvailable(x):
+		c.readFlags(x) // Fx85
 	default:
-		return fmt.Errorf("opcode not supported: 0x%04X", op)
+		return ErrUnknownOpcode
 	}
 	return nil
 }
 
-// Clear the display.
+// Clear the selected planes of the display.
 func (c *Chip8) cls() {
-	for i := range c.display {
-		c.display[i] = 0
+	for p := range c.display {
+		if c.planes&(1<<p) == 0 {
+			continue
+		}
+		c.display[p] = [DisplayHeight][DisplayWidth / 64]uint64{}
 	}
 }
 
 // Return from a subroutine.
 func (c *Chip8) ret() error {
 	if c.sp == 0 {
-		return errors.New("stack underflow")
+		return ErrStackUnderflow
 	}
 
 	c.sp--
@@ -142,7 +174,7 @@ func (c *Chip8) jump(addr uint16) {
 // Call a subroutine at address.
 func (c *Chip8) call(addr uint16) error {
 	if int(c.sp) == len(c.stack) {
-		return errors.New("stack overflow")
+		return ErrStackOverflow
 	}
 
 	c.stack[c.sp] = c.pc
@@ -154,21 +186,21 @@ func (c *Chip8) call(addr uint16) error {
 // Skip next instruction if Vx = kk.
 func (c *Chip8) skipIfVx(x, kk byte) {
 	if c.v[x] == kk {
-		c.pc += 2
+		c.skip()
 	}
 }
 
 // Skip next instruction if Vx != kk.
 func (c *Chip8) skipIfNotVx(x, kk byte) {
 	if c.v[x] != kk {
-		c.pc += 2
+		c.skip()
 	}
 }
 
 // Skip next instruction if Vx = Vy.
 func (c *Chip8) skipIfVxVy(x, y byte) {
 	if c.v[x] == c.v[y] {
-		c.pc += 2
+		c.skip()
 	}
 }
 
@@ -190,61 +222,81 @@ func (c *Chip8) setVxVy(x, y byte) {
 // Set Vx = Vx OR Vy.
 func (c *Chip8) setVxOrVy(x, y byte) {
 	c.v[x] |= c.v[y]
+	if c.quirks.ResetVF {
+		c.v[0xF] = 0
+	}
 }
 
 // Set Vx = Vx AND Vy.
 func (c *Chip8) setVxAndVy(x, y byte) {
 	c.v[x] &= c.v[y]
+	if c.quirks.ResetVF {
+		c.v[0xF] = 0
+	}
 }
 
 // Set Vx = Vx XOR Vy.
 func (c *Chip8) setVxXorVy(x, y byte) {
 	c.v[x] ^= c.v[y]
+	if c.quirks.ResetVF {
+		c.v[0xF] = 0
+	}
 }
 
 // Set Vx = Vx + Vy, set VF = carry.
+// VF is set after Vx, the flag wins when it is also the destination.
 func (c *Chip8) addVxVy(x, y byte) {
-	c.v[x] += c.v[y]
-	c.v[0xF] = 0
-	if c.v[x] < c.v[y] {
-		c.v[0xF] = 1
-	}
+	sum := uint16(c.v[x]) + uint16(c.v[y])
+	c.v[x] = byte(sum)
+	c.v[0xF] = byte(sum >> 8)
 }
 
 // Set Vx = Vx - Vy, set VF = NOT borrow.
 func (c *Chip8) subVxVy(x, y byte) {
-	c.v[0xF] = 1
+	var flag byte = 1
 	if c.v[x] < c.v[y] {
-		c.v[0xF] = 0
+		flag = 0
 	}
 	c.v[x] -= c.v[y]
+	c.v[0xF] = flag
 }
 
 // Set Vx = Vx SHR 1, set VF = LSB of Vx.
-func (c *Chip8) shrVx(x byte) {
-	c.v[0xF] = c.v[x] & 1
+// With the ShiftVy quirk Vx = Vy SHR 1 instead.
+func (c *Chip8) shrVx(x, y byte) {
+	if c.quirks.ShiftVy {
+		c.v[x] = c.v[y]
+	}
+	flag := c.v[x] & 1
 	c.v[x] >>= 1
+	c.v[0xF] = flag
 }
 
 // Set Vx = Vy - Vx, set VF = NOT borrow.
 func (c *Chip8) subYX(x, y byte) {
-	c.v[0xF] = 1
+	var flag byte = 1
 	if c.v[y] < c.v[x] {
-		c.v[0xF] = 0
+		flag = 0
 	}
 	c.v[x] = c.v[y] - c.v[x]
+	c.v[0xF] = flag
 }
 
 // Set Vx = Vx SHL 1, set VF = MSB of Vx.
-func (c *Chip8) shlVx(x byte) {
-	c.v[0xF] = c.v[x] >> 7
+// With the ShiftVy quirk Vx = Vy SHL 1 instead.
+func (c *Chip8) shlVx(x, y byte) {
+	if c.quirks.ShiftVy {
+		c.v[x] = c.v[y]
+	}
+	flag := c.v[x] >> 7
 	c.v[x] <<= 1
+	c.v[0xF] = flag
 }
 
 // Skip next instruction if Vx != Vy.
 func (c *Chip8) skipIfNotVcVy(x, y byte) {
 	if c.v[x] != c.v[y] {
-		c.pc += 2
+		c.skip()
 	}
 }
 
@@ -254,54 +306,91 @@ func (c *Chip8) setI(addr uint16) {
 }
 
 // Jump to addr + V0.
+// With the JumpVx quirk jump to addr + Vx instead, where x is the highest nibble of addr.
 func (c *Chip8) jumpV0(addr uint16) {
+	if c.quirks.JumpVx {
+		c.pc = addr + uint16(c.v[addr>>8])
+		return
+	}
 	c.pc = addr + uint16(c.v[0])
 }
 
 // Set Vx = random byte AND kk.
 func (c *Chip8) rndVx(x, kk byte) {
-	c.v[x] = byte(c.rand.Intn(256)) & kk
+	c.v[x] = c.rand.Byte() & kk
 }
 
 // Display n-byte sprite starting at memory location I at (Vx, Vy), set VF = collision.
+// If n is 0 a 16x16 sprite is drawn instead, using 2 bytes per row (SUPER-CHIP).
 // If the sprite is positioned so part of it is outside the coordinates of the display, it wraps around to the opposite side of the screen
-func (c *Chip8) drawSprite(x, y, n byte) {
-	sprite := c.memory[c.i : c.i+uint16(n)]
-	posX := c.v[x] % DisplayWidth  // wraps around to the opposite side of the screen
-	posY := c.v[y] % DisplayHeight // wraps around to the opposite side of the screen
-	collision := false
+// With the ClipSprites quirk that part is not drawn instead.
+func (c *Chip8) drawSprite(x, y, n byte) error {
+	width, height := c.Resolution()
+	rows, cols := int(n), 8
+	if n == 0 && c.set >= SCHIP10 {
+		rows, cols = 16, 16
+	}
 
-	for i := range sprite {
-		row := uint64(sprite[i])                     // Move sprite to the MSB
-		row = bits.RotateLeft64(row, int(64-8-posX)) // Move sprite to correct position and wrap around
+	size := rows * cols / 8
+	var data [64]byte // Up to 2 planes of 16x16 sprites
+	sprite := data[:size*bits.OnesCount8(c.planes&0x3)]
+	if err := c.load(sprite); err != nil {
+		return err
+	}
+	posX := int(c.v[x]) % width  // wraps around to the opposite side of the screen
+	posY := int(c.v[y]) % height // wraps around to the opposite side of the screen
+	collision := false
 
-		or := c.display[posY] | row
-		c.display[posY] ^= row
+	for p := range c.display {
+		if c.planes&(1<<p) == 0 {
+			continue
+		}
 
-		if c.display[posY] != or {
-			collision = true
+		for r := 0; r < rows; r++ {
+			row := uint16(sprite[r]) << 8 // Move sprite to the MSB
+			if cols == 16 {
+				row = uint16(sprite[2*r])<<8 | uint16(sprite[2*r+1])
+			}
+
+			if c.quirks.ClipSprites && posY+r >= height {
+				break
+			}
+
+			for b := 0; b < cols; b++ {
+				if row&(0x8000>>b) == 0 || c.quirks.ClipSprites && posX+b >= width {
+					continue
+				}
+
+				if c.flipPixel(p, (posX+b)%width, (posY+r)%height) {
+					collision = true
+				}
+			}
 		}
 
-		posY = (posY + 1) % DisplayHeight
+		sprite = sprite[size:] // The sprite of the next plane follows in memory
 	}
 
 	c.v[0xF] = 0
 	if collision {
 		c.v[0xF] = 1
 	}
+
+	c.vblank = c.quirks.DisplayWait
+	return nil
 }
 
 // Skip next instruction if key with the value of Vx is pressed.
+// Only the lowest 4 bits of Vx select the key, like on the COSMAC VIP.
 func (c *Chip8) skipIfPressed(x byte) {
-	if c.keypad[c.v[x]] {
-		c.pc += 2
+	if c.keypad[c.v[x]&0xF] {
+		c.skip()
 	}
 }
 
 // Skip next instruction if key with the value of Vx is not pressed.
 func (c *Chip8) skipIfNotPressed(x byte) {
-	if !c.keypad[c.v[x]] {
-		c.pc += 2
+	if !c.keypad[c.v[x]&0xF] {
+		c.skip()
 	}
 }
 
@@ -311,16 +400,23 @@ func (c *Chip8) setVxDT(x byte) {
 }
 
 // Wait for a key press, store the value of the key in Vx.
-// All execution stops until a key is pressed, then the value of that key is stored in Vx.
+// All execution stops until a key is pressed and released, like on the COSMAC VIP, then the value of that key is stored in Vx.
 func (c *Chip8) setVxKey(x byte) {
-	for i, k := range c.keypad {
-		if k {
-			c.v[x] = byte(i)
-			return
+	if c.keyPressed && !c.keypad[c.pressedKey] {
+		c.keyPressed = false
+		c.v[x] = c.pressedKey
+		return
+	}
+	if !c.keyPressed {
+		for i, k := range c.keypad {
+			if k {
+				c.keyPressed, c.pressedKey = true, byte(i)
+				break
+			}
 		}
 	}
 
-	c.pc -= 2 // Simulate halting if no key pressed
+	c.pc -= 2 // Simulate halting until the key is released
 }
 
 // Set delay timer = Vx.
@@ -344,18 +440,254 @@ func (c *Chip8) setIDigit(x byte) {
 }
 
 // Store BCD representation of Vx in memory locations I, I+1, and I+2.
-func (c *Chip8) bcd(x byte) {
-	c.memory[c.i] = c.v[x] / 100
-	c.memory[c.i+1] = (c.v[x] / 10) % 10
-	c.memory[c.i+2] = c.v[x] % 10
+func (c *Chip8) bcd(x byte) error {
+	return c.store([]byte{c.v[x] / 100, (c.v[x] / 10) % 10, c.v[x] % 10})
 }
 
 // Store registers V0 through Vx in memory starting at location I.
-func (c *Chip8) writeRegs(x byte) {
-	copy(c.memory[c.i:], c.v[:x+1])
+// With the IncrementI quirk I = I + x + 1 afterward.
+func (c *Chip8) writeRegs(x byte) error {
+	if err := c.store(c.v[:x+1]); err != nil {
+		return err
+	}
+	if c.quirks.IncrementI {
+		c.i += uint16(x) + 1
+	}
+	return nil
 }
 
 // Read registers V0 through Vx from memory starting at location I.
-func (c *Chip8) readRegs(x byte) {
-	copy(c.v[:x+1], c.memory[c.i:])
+// With the IncrementI quirk I = I + x + 1 afterward.
+func (c *Chip8) readRegs(x byte) error {
+	if err := c.load(c.v[:x+1]); err != nil {
+		return err
+	}
+	if c.quirks.IncrementI {
+		c.i += uint16(x) + 1
+	}
+	return nil
+}
+
+// Scroll the selected planes of the display n lines down.
+func (c *Chip8) scrollDown(n byte) {
+	_, height := c.Resolution()
+	for p := range c.display {
+		if c.planes&(1<<p) == 0 {
+			continue
+		}
+
+		for y := height - 1; y >= 0; y-- {
+			c.display[p][y] = [DisplayWidth / 64]uint64{}
+			if y >= int(n) {
+				c.display[p][y] = c.display[p][y-int(n)]
+			}
+		}
+	}
+}
+
+// Scroll the selected planes of the display n lines up.
+func (c *Chip8) scrollUp(n byte) {
+	_, height := c.Resolution()
+	for p := range c.display {
+		if c.planes&(1<<p) == 0 {
+			continue
+		}
+
+		for y := 0; y < height; y++ {
+			c.display[p][y] = [DisplayWidth / 64]uint64{}
+			if y+int(n) < height {
+				c.display[p][y] = c.display[p][y+int(n)]
+			}
+		}
+	}
+}
+
+// Scroll the selected planes of the display 4 pixels right.
+func (c *Chip8) scrollRight() {
+	width, height := c.Resolution()
+	for p := range c.display {
+		if c.planes&(1<<p) == 0 {
+			continue
+		}
+
+		for y := 0; y < height; y++ {
+			row := &c.display[p][y]
+			row[1] = row[1]>>4 | row[0]<<60
+			row[0] >>= 4
+			if width == LowResWidth {
+				row[1] = 0 // pixels scrolled out of the low resolution display are lost
+			}
+		}
+	}
+}
+
+// Scroll the selected planes of the display 4 pixels left.
+func (c *Chip8) scrollLeft() {
+	_, height := c.Resolution()
+	for p := range c.display {
+		if c.planes&(1<<p) == 0 {
+			continue
+		}
+
+		for y := 0; y < height; y++ {
+			row := &c.display[p][y]
+			row[0] = row[0]<<4 | row[1]>>60
+			row[1] <<= 4
+		}
+	}
+}
+
+// Exit interpreter.
+func (c *Chip8) exit() {
+	c.halted = true
+}
+
+// Disable high resolution graphic mode, all the planes of the display are cleared.
+func (c *Chip8) lowRes() {
+	c.hires = false
+	c.display = [2][DisplayHeight][DisplayWidth / 64]uint64{}
+}
+
+// Enable high resolution graphic mode, all the planes of the display are cleared.
+func (c *Chip8) highRes() {
+	c.hires = true
+	c.display = [2][DisplayHeight][DisplayWidth / 64]uint64{}
+}
+
+// Set I = location of the 10-byte sprite for digit Vx.
+func (c *Chip8) setIBigDigit(x byte) {
+	c.i = bigFontAddress + uint16(c.v[x])*10
+}
+
+// Store registers V0 through Vx in the RPL user flags.
+func (c *Chip8) writeFlags(x byte) {
+	copy(c.rpl[:], c.v[:x+1])
+}
+
+// Read registers V0 through Vx from the RPL user flags.
+func (c *Chip8) readFlags(x byte) {
+	copy(c.v[:x+1], c.rpl[:])
+}
+
+// Store registers Vx through Vy in memory starting at location I, in reverse order if x > y.
+func (c *Chip8) writeRegsRange(x, y byte) error {
+	regs := regsRange(x, y)
+	data := make([]byte, len(regs))
+	for k, r := range regs {
+		data[k] = c.v[r]
+	}
+	return c.store(data)
+}
+
+// Read registers Vx through Vy from memory starting at location I, in reverse order if x > y.
+func (c *Chip8) readRegsRange(x, y byte) error {
+	regs := regsRange(x, y)
+	data := make([]byte, len(regs))
+	if err := c.load(data); err != nil {
+		return err
+	}
+	for k, r := range regs {
+		c.v[r] = data[k]
+	}
+	return nil
+}
+
+// Set I = nnnn, the 16-bit address following the instruction.
+func (c *Chip8) setILong() error {
+	addr, err := c.fetch()
+	if err != nil {
+		return err
+	}
+
+	c.i = addr
+	return nil
+}
+
+// Select the planes to draw on with the bitmask n.
+func (c *Chip8) selectPlanes(n byte) {
+	c.planes = n
+}
+
+// Store the 16 bytes starting at location I in the audio pattern buffer.
+func (c *Chip8) loadPattern() error {
+	return c.load(c.pattern[:])
+}
+
+// Set the audio pattern playback rate = 4000*2^((Vx-64)/48) bits per second.
+func (c *Chip8) setPitch(x byte) {
+	c.pitch = c.v[x]
+}
+
+// Skip the next instruction, the XO-CHIP long load being 4 bytes long it must be skipped entirely.
+func (c *Chip8) skip() {
+	if c.set >= XOCHIP && int(c.pc)+1 < len(c.memory) && c.memory[c.pc] == 0xF0 && c.memory[c.pc+1] == 0x00 {
+		c.pc += 2
+	}
+	c.pc += 2
+}
+
+// Read the bytes starting at location I into data.
+// The addresses are 16-bit and wrap around, past the end of a smaller memory they are a segmentation fault.
+func (c *Chip8) load(data []byte) error {
+	if err := c.checkI(len(data)); err != nil {
+		return err
+	}
+	for k := range data {
+		data[k] = c.memory[c.i+uint16(k)]
+	}
+	return nil
+}
+
+// Write data in memory starting at location I, nothing is written if it doesn't fit.
+func (c *Chip8) store(data []byte) error {
+	if err := c.checkI(len(data)); err != nil {
+		return err
+	}
+	for k, b := range data {
+		c.memory[c.i+uint16(k)] = b
+	}
+	return nil
+}
+
+// Check that the n bytes starting at location I are in memory, the 64KB memory of the XO-CHIP covering every 16-bit address.
+func (c *Chip8) checkI(n int) error {
+	if len(c.memory) <= 0xFFFF && int(c.i)+n > len(c.memory) {
+		addr := c.i
+		if int(addr) < len(c.memory) {
+			addr = uint16(len(c.memory))
+		}
+		return &MachineError{Err: ErrSegmentationFault, Addr: addr}
+	}
+	return nil
+}
+
+// Report whether the registers V0 through Vx fit in the RPL user flags, the SUPER-CHIP only having 8 of them.
+func (c *Chip8) flagsAvailable(x byte) bool {
+	return x < 8 || c.set >= XOCHIP
+}
+
+// Report whether the pixel at (x, y) is set on plane p.
+func (c *Chip8) pixel(p, x, y int) bool {
+	return c.display[p][y][x/64]&(1<<(63-x%64)) != 0
+}
+
+// Toggle the pixel at (x, y) on plane p and report whether it was set before.
+func (c *Chip8) flipPixel(p, x, y int) bool {
+	set := c.pixel(p, x, y)
+	c.display[p][y][x/64] ^= 1 << (63 - x%64)
+	return set
+}
+
+// Return the registers from Vx to Vy, in reverse order if x > y.
+func regsRange(x, y byte) []byte {
+	regs := []byte{x}
+	for r := x; r != y; {
+		if x < y {
+			r++
+		} else {
+			r--
+		}
+		regs = append(regs, r)
+	}
+	return regs
 }
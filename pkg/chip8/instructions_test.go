@@ -1,7 +1,8 @@
 package chip8
 
 import (
-	"math/rand"
+	"bytes"
+	"errors"
 	"testing"
 )
 
@@ -29,7 +30,7 @@ func Test_chip8_fetch(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			c := &Chip8{
-				memory: tt.fields.memory,
+				memory: tt.fields.memory[:],
 				pc:     tt.fields.pc,
 			}
 			got, err := c.fetch()
@@ -49,17 +50,27 @@ func Test_chip8_fetch(t *testing.T) {
 
 func Test_chip8_decodeExecute(t *testing.T) {
 	type fields struct {
-		memory  [4096]byte
-		v       [16]byte
-		i       uint16
-		dt      byte
-		st      byte
-		pc      uint16
-		sp      byte
-		stack   [16]uint16
-		keypad  [16]bool
-		display [32]uint64
-		rand    *rand.Rand
+		memory     [4096]byte
+		v          [16]byte
+		i          uint16
+		dt         byte
+		st         byte
+		pc         uint16
+		sp         byte
+		stack      [16]uint16
+		keypad     [16]bool
+		keyPressed bool
+		pressedKey byte
+		display    [2][64][2]uint64
+		planes     byte
+		hires      bool
+		halted     bool
+		rpl        [16]byte
+		pattern    [16]byte
+		pitch      byte
+		quirks     Quirks
+		vblank     bool
+		rand       RandomSource
 	}
 	tests := []struct {
 		name    string
@@ -68,79 +79,138 @@ func Test_chip8_decodeExecute(t *testing.T) {
 		wants   fields
 		wantErr bool
 	}{
-		{name: "00E0", args: 0x00E0, fields: fields{display: [32]uint64{0: 1, 16: 1, 31: 1}}},
+		{name: "00Cn", args: 0x00C2, fields: fields{planes: 1, display: [2][64][2]uint64{{0: {1}, 30: {1}}}}, wants: fields{planes: 1, display: [2][64][2]uint64{{2: {1}}}}},
+		{name: "00Cn hires", args: 0x00C2, fields: fields{planes: 1, hires: true, display: [2][64][2]uint64{{0: {1}, 62: {1}}}}, wants: fields{planes: 1, hires: true, display: [2][64][2]uint64{{2: {1}}}}},
+		{name: "00Dn", args: 0x00D2, fields: fields{planes: 1, display: [2][64][2]uint64{{1: {1}, 2: {1}, 31: {1}}}}, wants: fields{planes: 1, display: [2][64][2]uint64{{0: {1}, 29: {1}}}}},
+		{name: "00E0 selected plane", args: 0x00E0, fields: fields{planes: 2, display: [2][64][2]uint64{{0: {1}}, {0: {1}}}}, wants: fields{planes: 2, display: [2][64][2]uint64{{0: {1}}}}},
+		{name: "00E0", args: 0x00E0, fields: fields{planes: 1, display: [2][64][2]uint64{{0: {1}, 16: {1}, 31: {1}}}}, wants: fields{planes: 1}},
 		{name: "00EE", args: 0x00EE, fields: fields{sp: 1, stack: [16]uint16{0xFF}}, wants: fields{pc: 0xFF}},
 		{name: "00EE stack underflow", args: 0x00EE, wantErr: true},
 		{name: "00EE stack underflow", args: 0x0000},
+		{name: "00FB", args: 0x00FB, fields: fields{planes: 1, display: [2][64][2]uint64{{{0xF0<<56 | 0xF}}}}, wants: fields{planes: 1, display: [2][64][2]uint64{{{0x0F << 56}}}}},
+		{name: "00FB hires", args: 0x00FB, fields: fields{planes: 1, hires: true, display: [2][64][2]uint64{{{0xF0<<56 | 0xF}}}}, wants: fields{planes: 1, hires: true, display: [2][64][2]uint64{{{0x0F << 56, 0xF << 60}}}}},
+		{name: "00FC", args: 0x00FC, fields: fields{planes: 1, display: [2][64][2]uint64{{{0xF0<<56 | 0xF}}}}, wants: fields{planes: 1, display: [2][64][2]uint64{{{0xF0}}}}},
+		{name: "00FC hires", args: 0x00FC, fields: fields{planes: 1, hires: true, display: [2][64][2]uint64{{{0xF0 << 56, 0xF << 60}}}}, wants: fields{planes: 1, hires: true, display: [2][64][2]uint64{{{0xF}}}}},
+		{name: "00FD", args: 0x00FD, wants: fields{halted: true}},
+		{name: "00FE", args: 0x00FE, fields: fields{hires: true, display: [2][64][2]uint64{{{1}}}}},
+		{name: "00FF", args: 0x00FF, fields: fields{display: [2][64][2]uint64{{{1}}}}, wants: fields{hires: true}},
 		{name: "1nnn", args: 0x1123, wants: fields{pc: 0x123}},
 		{name: "2nnn", args: 0x2123, fields: fields{pc: 0xFF}, wants: fields{pc: 0x123, sp: 1, stack: [16]uint16{0xFF}}},
 		{name: "2nnn stack overflow", args: 0x2000, fields: fields{sp: 16}, wants: fields{sp: 16}, wantErr: true},
 		{name: "3xkk skip", args: 0x32FF, fields: fields{v: [16]byte{2: 0xFF}}, wants: fields{v: [16]byte{2: 0xFF}, pc: 2}},
 		{name: "3xkk no skip", args: 0x32FF},
+		{name: "3xkk skip long load", args: 0x32FF, fields: fields{v: [16]byte{2: 0xFF}, memory: [4096]byte{0xF0, 0x00}}, wants: fields{v: [16]byte{2: 0xFF}, memory: [4096]byte{0xF0, 0x00}, pc: 4}},
 		{name: "4xkk skip", args: 0x42FF, wants: fields{pc: 2}},
 		{name: "4xkk no skip", args: 0x42FF, fields: fields{v: [16]byte{2: 0xFF}}, wants: fields{v: [16]byte{2: 0xFF}}},
 		{name: "5xy0 skip", args: 0x5120, fields: fields{v: [16]byte{1: 0xFF, 2: 0xFF}}, wants: fields{v: [16]byte{1: 0xFF, 2: 0xFF}, pc: 2}},
 		{name: "5xy0 no skip", args: 0x5120, fields: fields{v: [16]byte{1: 0xFF}}, wants: fields{v: [16]byte{1: 0xFF}}},
+		{name: "5xy2", args: 0x5132, fields: fields{i: 1, v: [16]byte{1, 2, 3, 4}}, wants: fields{memory: [4096]byte{0, 2, 3, 4}, i: 1, v: [16]byte{1, 2, 3, 4}}},
+		{name: "5xy2 reversed", args: 0x5312, fields: fields{i: 1, v: [16]byte{1, 2, 3, 4}}, wants: fields{memory: [4096]byte{0, 4, 3, 2}, i: 1, v: [16]byte{1, 2, 3, 4}}},
+		{name: "5xy3", args: 0x5133, fields: fields{memory: [4096]byte{0, 1, 2, 3}, i: 1}, wants: fields{memory: [4096]byte{0, 1, 2, 3}, i: 1, v: [16]byte{0, 1, 2, 3}}},
+		{name: "5xy3 reversed", args: 0x5313, fields: fields{memory: [4096]byte{0, 1, 2, 3}, i: 1}, wants: fields{memory: [4096]byte{0, 1, 2, 3}, i: 1, v: [16]byte{0, 3, 2, 1}}},
 		{name: "6xkk", args: 0x6212, fields: fields{v: [16]byte{2: 0xF0}}, wants: fields{v: [16]byte{2: 0x12}}},
 		{name: "7xkk", args: 0x7203, fields: fields{v: [16]byte{2: 10}}, wants: fields{v: [16]byte{2: 13}}},
 		{name: "8xy0", args: 0x8120, fields: fields{v: [16]byte{2: 0xFF}}, wants: fields{v: [16]byte{1: 0xFF, 2: 0xFF}}},
 		{name: "8xy1", args: 0x8121, fields: fields{v: [16]byte{1: 0xF0, 2: 0x0F}}, wants: fields{v: [16]byte{1: 0xFF, 2: 0x0F}}},
 		{name: "8xy2", args: 0x8122, fields: fields{v: [16]byte{1: 0x0F, 2: 0xFF}}, wants: fields{v: [16]byte{1: 0x0F, 2: 0xFF}}},
 		{name: "8xy3", args: 0x8123, fields: fields{v: [16]byte{1: 0x0F, 2: 0xFF}}, wants: fields{v: [16]byte{1: 0xF0, 2: 0xFF}}},
+		{name: "8xy1 reset VF", args: 0x8121, fields: fields{quirks: Quirks{ResetVF: true}, v: [16]byte{1: 0xF0, 2: 0x0F, 0xF: 1}}, wants: fields{quirks: Quirks{ResetVF: true}, v: [16]byte{1: 0xFF, 2: 0x0F}}},
+		{name: "8xy2 reset VF", args: 0x8122, fields: fields{quirks: Quirks{ResetVF: true}, v: [16]byte{1: 0x0F, 2: 0xFF, 0xF: 1}}, wants: fields{quirks: Quirks{ResetVF: true}, v: [16]byte{1: 0x0F, 2: 0xFF}}},
+		{name: "8xy3 reset VF", args: 0x8123, fields: fields{quirks: Quirks{ResetVF: true}, v: [16]byte{1: 0x0F, 2: 0xFF, 0xF: 1}}, wants: fields{quirks: Quirks{ResetVF: true}, v: [16]byte{1: 0xF0, 2: 0xFF}}},
 		{name: "8xy4 no carry", args: 0x8124, fields: fields{v: [16]byte{1: 1, 2: 3, 0xF: 1}}, wants: fields{v: [16]byte{1: 4, 2: 3}}},
 		{name: "8xy4 carry", args: 0x8124, fields: fields{v: [16]byte{1: 3, 2: 0xFF, 0xF: 0}}, wants: fields{v: [16]byte{1: 2, 2: 0xFF, 0xF: 1}}},
 		{name: "8xy5 no borrow", args: 0x8125, fields: fields{v: [16]byte{1: 3, 2: 1, 0xF: 0}}, wants: fields{v: [16]byte{1: 2, 2: 1, 0xF: 1}}},
 		{name: "8xy5 borrow", args: 0x8125, fields: fields{v: [16]byte{1: 3, 2: 0xFF, 0xF: 1}}, wants: fields{v: [16]byte{1: 4, 2: 0xFF, 0xF: 0}}},
 		{name: "8xy6 LSB", args: 0x8106, fields: fields{v: [16]byte{1: 0xFF, 0xF: 0}}, wants: fields{v: [16]byte{1: 0x7F, 0xF: 1}}},
 		{name: "8xy6 no LSB", args: 0x8106, fields: fields{v: [16]byte{1: 0xF0, 0xF: 1}}, wants: fields{v: [16]byte{1: 0x78, 0xF: 0}}},
+		{name: "8xy6 shift Vy", args: 0x8126, fields: fields{quirks: Quirks{ShiftVy: true}, v: [16]byte{1: 0xF0, 2: 0x0F}}, wants: fields{quirks: Quirks{ShiftVy: true}, v: [16]byte{1: 0x07, 2: 0x0F, 0xF: 1}}},
 		{name: "8xy7 no borrow", args: 0x8127, fields: fields{v: [16]byte{1: 1, 2: 3, 0xF: 0}}, wants: fields{v: [16]byte{1: 2, 2: 3, 0xF: 1}}},
 		{name: "8xy7 borrow", args: 0x8127, fields: fields{v: [16]byte{1: 0xFF, 2: 3, 0xF: 1}}, wants: fields{v: [16]byte{1: 4, 2: 3, 0xF: 0}}},
 		{name: "8xyE MSB", args: 0x810E, fields: fields{v: [16]byte{1: 0xFF, 0xF: 0}}, wants: fields{v: [16]byte{1: 0xFE, 0xF: 1}}},
 		{name: "8xyE no MSB", args: 0x810E, fields: fields{v: [16]byte{1: 0x0F, 0xF: 1}}, wants: fields{v: [16]byte{1: 0x1E, 0xF: 0}}},
+		{name: "8xyE shift Vy", args: 0x812E, fields: fields{quirks: Quirks{ShiftVy: true}, v: [16]byte{1: 0x0F, 2: 0xF0}}, wants: fields{quirks: Quirks{ShiftVy: true}, v: [16]byte{1: 0xE0, 2: 0xF0, 0xF: 1}}},
+		{name: "8xy4 VF destination", args: 0x8F14, fields: fields{v: [16]byte{1: 0xFF, 0xF: 2}}, wants: fields{v: [16]byte{1: 0xFF, 0xF: 1}}},
+		{name: "8xy5 VF destination", args: 0x8F15, fields: fields{v: [16]byte{1: 3, 0xF: 5}}, wants: fields{v: [16]byte{1: 3, 0xF: 1}}},
+		{name: "8xy6 VF destination", args: 0x8F06, fields: fields{v: [16]byte{0xF: 0xF0}}, wants: fields{v: [16]byte{0xF: 0}}},
+		{name: "8xy7 VF destination", args: 0x8F17, fields: fields{v: [16]byte{1: 3, 0xF: 5}}, wants: fields{v: [16]byte{1: 3, 0xF: 0}}},
+		{name: "8xyE VF destination", args: 0x8F0E, fields: fields{v: [16]byte{0xF: 0x0F}}, wants: fields{v: [16]byte{0xF: 0}}},
 		{name: "9xy0 skip", args: 0x9120, fields: fields{v: [16]byte{1: 0xFF}}, wants: fields{v: [16]byte{1: 0xFF}, pc: 2}},
 		{name: "9xy0 no skip", args: 0x9120, fields: fields{v: [16]byte{1: 0xFF, 2: 0xFF}}, wants: fields{v: [16]byte{1: 0xFF, 2: 0xFF}}},
 		{name: "Annn", args: 0xA123, wants: fields{i: 0x123}},
 		{name: "Bnnn", args: 0xB123, fields: fields{v: [16]byte{1}}, wants: fields{v: [16]byte{1}, pc: 0x124}},
-		{name: "Cxkk", args: 0xC1FF, fields: fields{rand: rand.New(rand.NewSource(0))}, wants: fields{v: [16]byte{1: 250}}},
-		{name: "Dxyn draw on top right", args: 0xD011, fields: fields{v: [16]byte{0, 0}, memory: [4096]byte{0xF0}}, wants: fields{display: [32]uint64{0xF0 << 56}, v: [16]byte{0, 0}, memory: [4096]byte{0xF0}}},
-		{name: "Dxyn draw on top left", args: 0xD011, fields: fields{v: [16]byte{56, 0}, memory: [4096]byte{0xF0}}, wants: fields{display: [32]uint64{0xF0}, v: [16]byte{56, 0}, memory: [4096]byte{0xF0}}},
-		{name: "Dxyn draw on bottom right", args: 0xD011, fields: fields{v: [16]byte{0, 31}, memory: [4096]byte{0xF0}}, wants: fields{display: [32]uint64{31: 0xF0 << 56}, v: [16]byte{0, 31}, memory: [4096]byte{0xF0}}},
-		{name: "Dxyn draw on bottom left", args: 0xD011, fields: fields{v: [16]byte{56, 31}, memory: [4096]byte{0xF0}}, wants: fields{display: [32]uint64{31: 0xF0}, v: [16]byte{56, 31}, memory: [4096]byte{0xF0}}},
-		{name: "Dxyn warp horizontaly", args: 0xD011, fields: fields{v: [16]byte{64, 0}, memory: [4096]byte{0xF0}}, wants: fields{display: [32]uint64{0xF0 << 56}, v: [16]byte{64, 0}, memory: [4096]byte{0xF0}}},
-		{name: "Dxyn warp verticaly", args: 0xD011, fields: fields{v: [16]byte{0, 32}, memory: [4096]byte{0xF0}}, wants: fields{display: [32]uint64{0xF0 << 56}, v: [16]byte{0, 32}, memory: [4096]byte{0xF0}}},
-		{name: "Dxyn partial warp horizontaly", args: 0xD011, fields: fields{v: [16]byte{63, 0}, memory: [4096]byte{0xF0}}, wants: fields{display: [32]uint64{0xE0<<56 | 1}, v: [16]byte{63, 0}, memory: [4096]byte{0xF0}}},
-		{name: "Dxyn partial warp verticaly", args: 0xD012, fields: fields{v: [16]byte{0, 31}, memory: [4096]byte{0xF0, 0x90}}, wants: fields{display: [32]uint64{0: 0x90 << 56, 31: 0xF0 << 56}, v: [16]byte{0, 31}, memory: [4096]byte{0xF0, 0x90}}},
-		{name: "Dxyn collision", args: 0xD011, fields: fields{v: [16]byte{0, 0}, memory: [4096]byte{0xF0}, display: [32]uint64{^uint64(0)}}, wants: fields{display: [32]uint64{^uint64(0xF0 << 56)}, v: [16]byte{0, 0, 0xF: 1}, memory: [4096]byte{0xF0}}},
+		{name: "Bnnn jump Vx", args: 0xB123, fields: fields{quirks: Quirks{JumpVx: true}, v: [16]byte{1, 2}}, wants: fields{quirks: Quirks{JumpVx: true}, v: [16]byte{1, 2}, pc: 0x125}},
+		{name: "Cxkk", args: 0xC1FF, fields: fields{rand: newRandSource(0)}, wants: fields{v: [16]byte{1: 0xE2}}},
+		{name: "Dxyn draw on top right", args: 0xD011, fields: fields{planes: 1, v: [16]byte{0, 0}, memory: [4096]byte{0xF0}}, wants: fields{planes: 1, display: [2][64][2]uint64{{{0xF0 << 56}}}, v: [16]byte{0, 0}, memory: [4096]byte{0xF0}}},
+		{name: "Dxyn draw on top left", args: 0xD011, fields: fields{planes: 1, v: [16]byte{56, 0}, memory: [4096]byte{0xF0}}, wants: fields{planes: 1, display: [2][64][2]uint64{{{0xF0}}}, v: [16]byte{56, 0}, memory: [4096]byte{0xF0}}},
+		{name: "Dxyn draw on bottom right", args: 0xD011, fields: fields{planes: 1, v: [16]byte{0, 31}, memory: [4096]byte{0xF0}}, wants: fields{planes: 1, display: [2][64][2]uint64{{31: {0xF0 << 56}}}, v: [16]byte{0, 31}, memory: [4096]byte{0xF0}}},
+		{name: "Dxyn draw on bottom left", args: 0xD011, fields: fields{planes: 1, v: [16]byte{56, 31}, memory: [4096]byte{0xF0}}, wants: fields{planes: 1, display: [2][64][2]uint64{{31: {0xF0}}}, v: [16]byte{56, 31}, memory: [4096]byte{0xF0}}},
+		{name: "Dxyn warp horizontaly", args: 0xD011, fields: fields{planes: 1, v: [16]byte{64, 0}, memory: [4096]byte{0xF0}}, wants: fields{planes: 1, display: [2][64][2]uint64{{{0xF0 << 56}}}, v: [16]byte{64, 0}, memory: [4096]byte{0xF0}}},
+		{name: "Dxyn warp verticaly", args: 0xD011, fields: fields{planes: 1, v: [16]byte{0, 32}, memory: [4096]byte{0xF0}}, wants: fields{planes: 1, display: [2][64][2]uint64{{{0xF0 << 56}}}, v: [16]byte{0, 32}, memory: [4096]byte{0xF0}}},
+		{name: "Dxyn partial warp horizontaly", args: 0xD011, fields: fields{planes: 1, v: [16]byte{63, 0}, memory: [4096]byte{0xF0}}, wants: fields{planes: 1, display: [2][64][2]uint64{{{0xE0<<56 | 1}}}, v: [16]byte{63, 0}, memory: [4096]byte{0xF0}}},
+		{name: "Dxyn partial warp verticaly", args: 0xD012, fields: fields{planes: 1, v: [16]byte{0, 31}, memory: [4096]byte{0xF0, 0x90}}, wants: fields{planes: 1, display: [2][64][2]uint64{{0: {0x90 << 56}, 31: {0xF0 << 56}}}, v: [16]byte{0, 31}, memory: [4096]byte{0xF0, 0x90}}},
+		{name: "Dxyn collision", args: 0xD011, fields: fields{planes: 1, v: [16]byte{0, 0}, memory: [4096]byte{0xF0}, display: [2][64][2]uint64{{{^uint64(0)}}}}, wants: fields{planes: 1, display: [2][64][2]uint64{{{^uint64(0xF0 << 56)}}}, v: [16]byte{0, 0, 0xF: 1}, memory: [4096]byte{0xF0}}},
+		{name: "Dxyn clip horizontaly", args: 0xD011, fields: fields{planes: 1, quirks: Quirks{ClipSprites: true}, v: [16]byte{62, 0}, memory: [4096]byte{0xF0}}, wants: fields{planes: 1, quirks: Quirks{ClipSprites: true}, display: [2][64][2]uint64{{{0x3}}}, v: [16]byte{62, 0}, memory: [4096]byte{0xF0}}},
+		{name: "Dxyn clip verticaly", args: 0xD012, fields: fields{planes: 1, quirks: Quirks{ClipSprites: true}, v: [16]byte{0, 31}, memory: [4096]byte{0xF0, 0x90}}, wants: fields{planes: 1, quirks: Quirks{ClipSprites: true}, display: [2][64][2]uint64{{31: {0xF0 << 56}}}, v: [16]byte{0, 31}, memory: [4096]byte{0xF0, 0x90}}},
+		{name: "Dxyn clip after warp", args: 0xD011, fields: fields{planes: 1, quirks: Quirks{ClipSprites: true}, v: [16]byte{126, 0}, memory: [4096]byte{0xF0}}, wants: fields{planes: 1, quirks: Quirks{ClipSprites: true}, display: [2][64][2]uint64{{{0x3}}}, v: [16]byte{126, 0}, memory: [4096]byte{0xF0}}},
+		{name: "Dxyn display wait", args: 0xD011, fields: fields{planes: 1, quirks: Quirks{DisplayWait: true}, memory: [4096]byte{0xF0}}, wants: fields{planes: 1, quirks: Quirks{DisplayWait: true}, display: [2][64][2]uint64{{{0xF0 << 56}}}, memory: [4096]byte{0xF0}, vblank: true}},
+		{name: "Dxy0 draw 16x16", args: 0xD010, fields: fields{planes: 1, hires: true, memory: [4096]byte{0xFF, 0xFF, 31: 0x01}}, wants: fields{planes: 1, hires: true, display: [2][64][2]uint64{{0: {0xFFFF << 48}, 15: {1 << 48}}}, memory: [4096]byte{0xFF, 0xFF, 31: 0x01}}},
+		{name: "Dxy0 warp horizontaly", args: 0xD010, fields: fields{planes: 1, hires: true, v: [16]byte{120}, memory: [4096]byte{0xFF, 0xFF}}, wants: fields{planes: 1, hires: true, display: [2][64][2]uint64{{{0xFF << 56, 0xFF}}}, v: [16]byte{120}, memory: [4096]byte{0xFF, 0xFF}}},
+		{name: "Dxyn draw in hires", args: 0xD011, fields: fields{planes: 1, hires: true, v: [16]byte{64, 63}, memory: [4096]byte{0xF0}}, wants: fields{planes: 1, hires: true, display: [2][64][2]uint64{{63: {0, 0xF0 << 56}}}, v: [16]byte{64, 63}, memory: [4096]byte{0xF0}}},
+		{name: "Dxyn draw on second plane", args: 0xD011, fields: fields{planes: 2, memory: [4096]byte{0xF0}}, wants: fields{planes: 2, display: [2][64][2]uint64{{}, {{0xF0 << 56}}}, memory: [4096]byte{0xF0}}},
+		{name: "Dxyn draw on both planes", args: 0xD011, fields: fields{planes: 3, memory: [4096]byte{0xF0, 0x0F}}, wants: fields{planes: 3, display: [2][64][2]uint64{{{0xF0 << 56}}, {{0x0F << 56}}}, memory: [4096]byte{0xF0, 0x0F}}},
+		{name: "Dxyn collision on second plane", args: 0xD011, fields: fields{planes: 3, memory: [4096]byte{0xF0, 0x0F}, display: [2][64][2]uint64{{}, {{0x01 << 56}}}}, wants: fields{planes: 3, display: [2][64][2]uint64{{{0xF0 << 56}}, {{0x0E << 56}}}, v: [16]byte{0xF: 1}, memory: [4096]byte{0xF0, 0x0F}}},
 		{name: "Ex9E skip", args: 0xE09E, fields: fields{v: [16]byte{1}, keypad: [16]bool{1: true}}, wants: fields{pc: 2, v: [16]byte{1}}},
 		{name: "Ex9E no skip", args: 0xE09E, fields: fields{v: [16]byte{1}}, wants: fields{v: [16]byte{1}}},
 		{name: "ExA1 skip", args: 0xE0A1, fields: fields{v: [16]byte{1}}, wants: fields{pc: 2, v: [16]byte{1}}},
 		{name: "ExA1 no skip", args: 0xE0A1, fields: fields{v: [16]byte{1}, keypad: [16]bool{1: true}}, wants: fields{v: [16]byte{1}}},
+		{name: "F000 nnnn", args: 0xF000, fields: fields{memory: [4096]byte{0x12, 0x34}}, wants: fields{memory: [4096]byte{0x12, 0x34}, i: 0x1234, pc: 2}},
+		{name: "Fn01", args: 0xF201, wants: fields{planes: 2}},
+		{name: "F002", args: 0xF002, fields: fields{i: 1, memory: [4096]byte{1: 0xFF, 16: 0x0F}}, wants: fields{i: 1, memory: [4096]byte{1: 0xFF, 16: 0x0F}, pattern: [16]byte{0xFF, 15: 0x0F}}},
 		{name: "Fx07", args: 0xF107, fields: fields{dt: 0xFF}, wants: fields{v: [16]byte{1: 0xFF}, dt: 0xFF}},
-		{name: "Fx0A key pressed", args: 0xF10A, fields: fields{keypad: [16]bool{3: true}}, wants: fields{v: [16]byte{1: 3}}},
+		{name: "Fx0A key pressed", args: 0xF10A, fields: fields{pc: 2, keypad: [16]bool{3: true}}, wants: fields{keypad: [16]bool{3: true}, keyPressed: true, pressedKey: 3}},
+		{name: "Fx0A key held", args: 0xF10A, fields: fields{pc: 2, keypad: [16]bool{3: true}, keyPressed: true, pressedKey: 3}, wants: fields{keypad: [16]bool{3: true}, keyPressed: true, pressedKey: 3}},
+		{name: "Fx0A key released", args: 0xF10A, fields: fields{pc: 2, keyPressed: true, pressedKey: 3}, wants: fields{pc: 2, v: [16]byte{1: 3}, pressedKey: 3}},
 		{name: "Fx0A no key pressed", args: 0xF10A, fields: fields{pc: 2}, wants: fields{pc: 0}},
 		{name: "Fx15", args: 0xF115, fields: fields{v: [16]byte{1: 0xFF}}, wants: fields{dt: 0xFF, v: [16]byte{1: 0xFF}}},
 		{name: "Fx18", args: 0xF118, fields: fields{v: [16]byte{1: 0xFF}}, wants: fields{st: 0xFF, v: [16]byte{1: 0xFF}}},
 		{name: "Fx1E", args: 0xF11E, fields: fields{i: 1, v: [16]byte{1: 0xFE}}, wants: fields{i: 0xFF, v: [16]byte{1: 0xFE}}},
 		{name: "Fx29", args: 0xF129, fields: fields{v: [16]byte{1: 1}}, wants: fields{i: 5, v: [16]byte{1: 1}}},
+		{name: "Fx30", args: 0xF130, fields: fields{v: [16]byte{1: 2}}, wants: fields{i: bigFontAddress + 20, v: [16]byte{1: 2}}},
 		{name: "Fx33", args: 0xF133, fields: fields{v: [16]byte{1: 123}}, wants: fields{memory: [4096]byte{1, 2, 3}, v: [16]byte{1: 123}}},
 		{name: "Fx55", args: 0xF255, fields: fields{i: 1, v: [16]byte{1, 2, 3, 4}}, wants: fields{memory: [4096]byte{0, 1, 2, 3}, i: 1, v: [16]byte{1, 2, 3, 4}}},
+		{name: "Fx55 increment I", args: 0xF255, fields: fields{quirks: Quirks{IncrementI: true}, i: 1, v: [16]byte{1, 2, 3, 4}}, wants: fields{quirks: Quirks{IncrementI: true}, memory: [4096]byte{0, 1, 2, 3}, i: 4, v: [16]byte{1, 2, 3, 4}}},
+		{name: "Fx65 increment I", args: 0xF265, fields: fields{quirks: Quirks{IncrementI: true}, memory: [4096]byte{0, 1, 2, 3, 4}, i: 1}, wants: fields{quirks: Quirks{IncrementI: true}, memory: [4096]byte{0, 1, 2, 3, 4}, i: 4, v: [16]byte{1, 2, 3}}},
 		{name: "Fx65", args: 0xF265, fields: fields{memory: [4096]byte{0, 1, 2, 3, 4}, i: 1}, wants: fields{memory: [4096]byte{0, 1, 2, 3, 4}, i: 1, v: [16]byte{1, 2, 3}}},
+		{name: "Fx3A", args: 0xF13A, fields: fields{v: [16]byte{1: 100}}, wants: fields{pitch: 100, v: [16]byte{1: 100}}},
+		{name: "Fx75", args: 0xF275, fields: fields{v: [16]byte{1, 2, 3, 4}}, wants: fields{rpl: [16]byte{1, 2, 3}, v: [16]byte{1, 2, 3, 4}}},
+		{name: "Fx85", args: 0xF285, fields: fields{rpl: [16]byte{1, 2, 3, 4}}, wants: fields{rpl: [16]byte{1, 2, 3, 4}, v: [16]byte{1, 2, 3}}},
+		{name: "Fx75 all flags", args: 0xFF75, fields: fields{v: [16]byte{15: 0xFF}}, wants: fields{rpl: [16]byte{15: 0xFF}, v: [16]byte{15: 0xFF}}},
 		{name: "Unkown opcode", args: 0xF088, wantErr: true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			c := &Chip8{
-				memory:  tt.fields.memory,
-				v:       tt.fields.v,
-				i:       tt.fields.i,
-				dt:      tt.fields.dt,
-				st:      tt.fields.st,
-				pc:      tt.fields.pc,
-				sp:      tt.fields.sp,
-				stack:   tt.fields.stack,
-				keypad:  tt.fields.keypad,
-				display: tt.fields.display,
-				rand:    tt.fields.rand,
+				memory:     tt.fields.memory[:],
+				v:          tt.fields.v,
+				i:          tt.fields.i,
+				dt:         tt.fields.dt,
+				st:         tt.fields.st,
+				pc:         tt.fields.pc,
+				sp:         tt.fields.sp,
+				stack:      tt.fields.stack,
+				keypad:     tt.fields.keypad,
+				keyPressed: tt.fields.keyPressed,
+				pressedKey: tt.fields.pressedKey,
+				display:    tt.fields.display,
+				planes:     tt.fields.planes,
+				hires:      tt.fields.hires,
+				halted:     tt.fields.halted,
+				rpl:        tt.fields.rpl,
+				pattern:    tt.fields.pattern,
+				pitch:      tt.fields.pitch,
+				set:        XOCHIP,
+				quirks:     tt.fields.quirks,
+				rand:       tt.fields.rand,
 			}
 			if err := c.decodeExecute(tt.args); (err != nil) != tt.wantErr {
 				t.Errorf("chip8.decodeExecute() error = %v, wantErr %v", err, tt.wantErr)
@@ -164,12 +234,137 @@ func Test_chip8_decodeExecute(t *testing.T) {
 			if c.sp != tt.wants.sp {
 				t.Errorf("c.sp = %v, want %v", c.sp, tt.wants.sp)
 			}
-			if c.memory != tt.wants.memory {
+			if !bytes.Equal(c.memory, tt.wants.memory[:]) {
 				t.Errorf("c.memory = %v, want %v", c.memory, tt.wants.memory)
 			}
 			if c.display != tt.wants.display {
 				t.Errorf("c.display = %v, want %v", c.display, tt.wants.display)
 			}
+			if c.planes != tt.wants.planes {
+				t.Errorf("c.planes = %v, want %v", c.planes, tt.wants.planes)
+			}
+			if c.hires != tt.wants.hires {
+				t.Errorf("c.hires = %v, want %v", c.hires, tt.wants.hires)
+			}
+			if c.keyPressed != tt.wants.keyPressed || c.pressedKey != tt.wants.pressedKey {
+				t.Errorf("c.keyPressed, c.pressedKey = %v, %v, want %v, %v", c.keyPressed, c.pressedKey, tt.wants.keyPressed, tt.wants.pressedKey)
+			}
+			if c.halted != tt.wants.halted {
+				t.Errorf("c.halted = %v, want %v", c.halted, tt.wants.halted)
+			}
+			if c.rpl != tt.wants.rpl {
+				t.Errorf("c.rpl = %v, want %v", c.rpl, tt.wants.rpl)
+			}
+			if c.pattern != tt.wants.pattern {
+				t.Errorf("c.pattern = %v, want %v", c.pattern, tt.wants.pattern)
+			}
+			if c.pitch != tt.wants.pitch {
+				t.Errorf("c.pitch = %v, want %v", c.pitch, tt.wants.pitch)
+			}
+			if c.vblank != tt.wants.vblank {
+				t.Errorf("c.vblank = %v, want %v", c.vblank, tt.wants.vblank)
+			}
+		})
+	}
+}
+
+func Test_chip8_decodeExecute_instructionSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    uint16
+		set     InstructionSet
+		wantPC  uint16
+		wantErr bool
+	}{
+		{name: "00Cn ignored by CHIP-8", args: 0x00C1, set: CHIP8},
+		{name: "00Cn ignored by SUPER-CHIP 1.0", args: 0x00C1, set: SCHIP10},
+		{name: "00Cn supported by SUPER-CHIP 1.1", args: 0x00C1, set: SCHIP11},
+		{name: "00FF ignored by CHIP-8", args: 0x00FF, set: CHIP8},
+		{name: "Fx30 not supported by CHIP-8", args: 0xF130, set: CHIP8, wantErr: true},
+		{name: "Fx30 supported by SUPER-CHIP 1.0", args: 0xF130, set: SCHIP10},
+		{name: "Fx75 supported by SUPER-CHIP 1.1", args: 0xF775, set: SCHIP11},
+		{name: "Fx75 out of the SUPER-CHIP flags", args: 0xF875, set: SCHIP11, wantErr: true},
+		{name: "Fx75 supported by XO-CHIP", args: 0xFF75, set: XOCHIP},
+		{name: "5xy2 not supported by SUPER-CHIP 1.1", args: 0x5122, set: SCHIP11, wantErr: true},
+		{name: "5xy2 supported by XO-CHIP", args: 0x5122, set: XOCHIP},
+		{name: "F000 not supported by SUPER-CHIP 1.1", args: 0xF000, set: SCHIP11, wantErr: true},
+		{name: "F000 supported by XO-CHIP", args: 0xF000, set: XOCHIP, wantPC: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Chip8{
+				memory: make([]byte, 4096),
+				planes: 1,
+				set:    tt.set,
+			}
+			if err := c.decodeExecute(tt.args); (err != nil) != tt.wantErr {
+				t.Errorf("chip8.decodeExecute() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if c.pc != tt.wantPC {
+				t.Errorf("c.pc = %v, want %v", c.pc, tt.wantPC)
+			}
+		})
+	}
+}
+
+func Test_chip8_memoryAccess(t *testing.T) {
+	tests := []struct {
+		name     string
+		platform Platform
+		program  []byte
+		steps    int
+		wantErr  error
+		// A byte expected in memory afterward.
+		addr uint16
+		want byte
+	}{
+		{name: "Dxyn wraps around", platform: PlatformXOCHIP, program: []byte{0xF0, 0x00, 0xFF, 0xFF, 0xD0, 0x15}, steps: 2},
+		{name: "5xy2 wraps around", platform: PlatformXOCHIP, program: []byte{0x61, 0x42, 0xF0, 0x00, 0xFF, 0xFF, 0x50, 0xF2}, steps: 3, addr: 0x0000, want: 0x42},
+		{name: "5xy3 wraps around", platform: PlatformXOCHIP, program: []byte{0xF0, 0x00, 0xFF, 0xFF, 0x50, 0xF3}, steps: 2},
+		{name: "Fx33 wraps around", platform: PlatformXOCHIP, program: []byte{0x60, 0x7B, 0xF0, 0x00, 0xFF, 0xFF, 0xF0, 0x33}, steps: 3, addr: 0x0001, want: 3},
+		{name: "Fx33 out of memory", platform: PlatformVIP, program: []byte{0xAF, 0xFF, 0xF0, 0x33}, steps: 2, wantErr: ErrSegmentationFault},
+		{name: "Fx55 out of memory", platform: PlatformVIP, program: []byte{0xAF, 0xFF, 0xF1, 0x55}, steps: 2, wantErr: ErrSegmentationFault},
+		{name: "Fx65 out of memory", platform: PlatformVIP, program: []byte{0xAF, 0xFF, 0xF1, 0x65}, steps: 2, wantErr: ErrSegmentationFault},
+		{name: "Dxyn out of memory", platform: PlatformVIP, program: []byte{0xAF, 0xF0, 0x60, 0xFF, 0xF0, 0x1E, 0xD0, 0x11}, steps: 4, wantErr: ErrSegmentationFault},
+		{name: "Fx65 at the end of memory", platform: PlatformVIP, program: []byte{0xAF, 0xFF, 0xF0, 0x65}, steps: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(tt.platform)
+			if err := c.LoadGame(tt.program); err != nil {
+				t.Fatal(err)
+			}
+			var err error
+			for i := 0; i < tt.steps && err == nil; i++ {
+				err = c.Step()
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("chip8.Step() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := c.memory[tt.addr]; tt.want != 0 && got != tt.want {
+				t.Errorf("c.memory[0x%04X] = %v, want %v", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, p := range Platforms {
+		t.Run(p.Name, func(t *testing.T) {
+			c := New(p)
+			if len(c.memory) != p.MemorySize {
+				t.Errorf("len(c.memory) = %v, want %v", len(c.memory), p.MemorySize)
+			}
+			if c.pc != p.LoadAddress {
+				t.Errorf("c.pc = 0x%04X, want 0x%04X", c.pc, p.LoadAddress)
+			}
+			if !bytes.Equal(c.memory[fontAddress:fontAddress+len(p.Font.Small)], p.Font.Small[:]) {
+				t.Errorf("small font not loaded at 0x%04X", fontAddress)
+			}
+			if !bytes.Equal(c.memory[bigFontAddress:bigFontAddress+len(p.Font.Big)], p.Font.Big[:]) {
+				t.Errorf("big font not loaded at 0x%04X", bigFontAddress)
+			}
 		})
 	}
 }
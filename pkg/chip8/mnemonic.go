@@ -0,0 +1,138 @@
+package chip8
+
+import "fmt"
+
+// Disassemble return the instruction at addr in the syntax of Cowgod's Chip-8 Technical Reference, and its size in bytes.
+// The instructions not supported by the instruction set of the machine are shown as data.
+func (c *Chip8) Disassemble(addr uint16) (string, int, error) {
+	if int(addr)+1 >= len(c.memory) {
+		return "", 0, fmt.Errorf("address out of memory: 0x%04X", addr)
+	}
+
+	op := uint16(c.memory[addr])<<8 | uint16(c.memory[addr+1])
+	if op == 0xF000 && c.set >= XOCHIP {
+		if int(addr)+3 >= len(c.memory) {
+			return "", 0, fmt.Errorf("address out of memory: 0x%04X", addr+2)
+		}
+		long := uint16(c.memory[addr+2])<<8 | uint16(c.memory[addr+3])
+		return fmt.Sprintf("LD I, long 0x%04X", long), 4, nil
+	}
+
+	return Mnemonic(op, c.set), 2, nil
+}
+
+// Mnemonic return an instruction of the given instruction set in the syntax of Cowgod's Chip-8 Technical Reference.
+// The address of the XO-CHIP long load follows the instruction so it is left out.
+func Mnemonic(op uint16, set InstructionSet) string {
+	nnn := op & 0xFFF
+	kk := op & 0xFF
+	n := op & 0xF
+	x := op >> 8 & 0xF
+	y := op >> 4 & 0xF
+
+	switch {
+	case op&0xFFF0 == 0x00C0 && set >= SCHIP11:
+		return fmt.Sprintf("SCD %d", n)
+	case op&0xFFF0 == 0x00D0 && set >= XOCHIP:
+		return fmt.Sprintf("SCU %d", n)
+	case op == 0x00E0:
+		return "CLS"
+	case op == 0x00EE:
+		return "RET"
+	case op == 0x00FB && set >= SCHIP11:
+		return "SCR"
+	case op == 0x00FC && set >= SCHIP11:
+		return "SCL"
+	case op == 0x00FD && set >= SCHIP10:
+		return "EXIT"
+	case op == 0x00FE && set >= SCHIP10:
+		return "LOW"
+	case op == 0x00FF && set >= SCHIP10:
+		return "HIGH"
+	case op&0xF000 == 0x0000:
+		return fmt.Sprintf("SYS 0x%03X", nnn)
+	case op&0xF000 == 0x1000:
+		return fmt.Sprintf("JP 0x%03X", nnn)
+	case op&0xF000 == 0x2000:
+		return fmt.Sprintf("CALL 0x%03X", nnn)
+	case op&0xF000 == 0x3000:
+		return fmt.Sprintf("SE V%X, 0x%02X", x, kk)
+	case op&0xF000 == 0x4000:
+		return fmt.Sprintf("SNE V%X, 0x%02X", x, kk)
+	case op&0xF00F == 0x5000:
+		return fmt.Sprintf("SE V%X, V%X", x, y)
+	case op&0xF00F == 0x5002 && set >= XOCHIP:
+		return fmt.Sprintf("SAVE V%X - V%X", x, y)
+	case op&0xF00F == 0x5003 && set >= XOCHIP:
+		return fmt.Sprintf("LOAD V%X - V%X", x, y)
+	case op&0xF000 == 0x6000:
+		return fmt.Sprintf("LD V%X, 0x%02X", x, kk)
+	case op&0xF000 == 0x7000:
+		return fmt.Sprintf("ADD V%X, 0x%02X", x, kk)
+	case op&0xF00F == 0x8000:
+		return fmt.Sprintf("LD V%X, V%X", x, y)
+	case op&0xF00F == 0x8001:
+		return fmt.Sprintf("OR V%X, V%X", x, y)
+	case op&0xF00F == 0x8002:
+		return fmt.Sprintf("AND V%X, V%X", x, y)
+	case op&0xF00F == 0x8003:
+		return fmt.Sprintf("XOR V%X, V%X", x, y)
+	case op&0xF00F == 0x8004:
+		return fmt.Sprintf("ADD V%X, V%X", x, y)
+	case op&0xF00F == 0x8005:
+		return fmt.Sprintf("SUB V%X, V%X", x, y)
+	case op&0xF00F == 0x8006:
+		return fmt.Sprintf("SHR V%X, V%X", x, y)
+	case op&0xF00F == 0x8007:
+		return fmt.Sprintf("SUBN V%X, V%X", x, y)
+	case op&0xF00F == 0x800E:
+		return fmt.Sprintf("SHL V%X, V%X", x, y)
+	case op&0xF00F == 0x9000:
+		return fmt.Sprintf("SNE V%X, V%X", x, y)
+	case op&0xF000 == 0xA000:
+		return fmt.Sprintf("LD I, 0x%03X", nnn)
+	case op&0xF000 == 0xB000:
+		return fmt.Sprintf("JP V0, 0x%03X", nnn)
+	case op&0xF000 == 0xC000:
+		return fmt.Sprintf("RND V%X, 0x%02X", x, kk)
+	case op&0xF000 == 0xD000:
+		return fmt.Sprintf("DRW V%X, V%X, %d", x, y, n)
+	case op&0xF0FF == 0xE09E:
+		return fmt.Sprintf("SKP V%X", x)
+	case op&0xF0FF == 0xE0A1:
+		return fmt.Sprintf("SKNP V%X", x)
+	case op == 0xF000 && set >= XOCHIP:
+		return "LD I, long"
+	case op&0xF0FF == 0xF001 && set >= XOCHIP:
+		return fmt.Sprintf("PLANE %d", x)
+	case op == 0xF002 && set >= XOCHIP:
+		return "AUDIO"
+	case op&0xF0FF == 0xF007:
+		return fmt.Sprintf("LD V%X, DT", x)
+	case op&0xF0FF == 0xF00A:
+		return fmt.Sprintf("LD V%X, K", x)
+	case op&0xF0FF == 0xF015:
+		return fmt.Sprintf("LD DT, V%X", x)
+	case op&0xF0FF == 0xF018:
+		return fmt.Sprintf("LD ST, V%X", x)
+	case op&0xF0FF == 0xF01E:
+		return fmt.Sprintf("ADD I, V%X", x)
+	case op&0xF0FF == 0xF029:
+		return fmt.Sprintf("LD F, V%X", x)
+	case op&0xF0FF == 0xF030 && set >= SCHIP10:
+		return fmt.Sprintf("LD HF, V%X", x)
+	case op&0xF0FF == 0xF033:
+		return fmt.Sprintf("LD B, V%X", x)
+	case op&0xF0FF == 0xF055:
+		return fmt.Sprintf("LD [I], V%X", x)
+	case op&0xF0FF == 0xF065:
+		return fmt.Sprintf("LD V%X, [I]", x)
+	case op&0xF0FF == 0xF03A && set >= XOCHIP:
+		return fmt.Sprintf("PITCH V%X", x)
+	case op&0xF0FF == 0xF075 && set >= SCHIP10 && (x < 8 || set >= XOCHIP):
+		return fmt.Sprintf("LD R, V%X", x)
+	case op&0xF0FF == 0xF085 && set >= SCHIP10 && (x < 8 || set >= XOCHIP):
+		return fmt.Sprintf("LD V%X, R", x)
+	}
+	return fmt.Sprintf("DW 0x%04X", op)
+}
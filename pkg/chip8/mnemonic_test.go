@@ -0,0 +1,60 @@
+package chip8
+
+import "testing"
+
+func TestMnemonic(t *testing.T) {
+	tests := []struct {
+		op   uint16
+		set  InstructionSet
+		want string
+	}{
+		{0x00E0, CHIP8, "CLS"},
+		{0x00EE, CHIP8, "RET"},
+		{0x0123, CHIP8, "SYS 0x123"},
+		{0x1234, CHIP8, "JP 0x234"},
+		{0x2345, CHIP8, "CALL 0x345"},
+		{0x3A42, CHIP8, "SE VA, 0x42"},
+		{0x5AB0, CHIP8, "SE VA, VB"},
+		{0x8AB6, CHIP8, "SHR VA, VB"},
+		{0xB300, CHIP8, "JP V0, 0x300"},
+		{0xD125, CHIP8, "DRW V1, V2, 5"},
+		{0xF165, CHIP8, "LD V1, [I]"},
+		{0x00FF, CHIP8, "SYS 0x0FF"},
+		{0x00FF, SCHIP10, "HIGH"},
+		{0x00C4, SCHIP10, "SYS 0x0C4"},
+		{0x00C4, SCHIP11, "SCD 4"},
+		{0xF830, SCHIP10, "LD HF, V8"},
+		{0xF875, SCHIP11, "DW 0xF875"},
+		{0xF875, XOCHIP, "LD R, V8"},
+		{0x5122, SCHIP11, "DW 0x5122"},
+		{0x5122, XOCHIP, "SAVE V1 - V2"},
+		{0xF201, XOCHIP, "PLANE 2"},
+		{0xF002, XOCHIP, "AUDIO"},
+		{0xF000, XOCHIP, "LD I, long"},
+		{0xFFFF, XOCHIP, "DW 0xFFFF"},
+	}
+	for _, tt := range tests {
+		if got := Mnemonic(tt.op, tt.set); got != tt.want {
+			t.Errorf("Mnemonic(0x%04X, %v) = %q, want %q", tt.op, tt.set, got, tt.want)
+		}
+	}
+}
+
+func TestChip8_Disassemble(t *testing.T) {
+	c := New(PlatformOcto)
+	if err := c.LoadGame([]byte{0xF0, 0x00, 0x12, 0x34, 0x00, 0xE0}); err != nil {
+		t.Fatalf("chip8.LoadGame() error = %v", err)
+	}
+
+	got, size, err := c.Disassemble(0x200)
+	if err != nil || got != "LD I, long 0x1234" || size != 4 {
+		t.Errorf("chip8.Disassemble(0x200) = %q, %v, %v, want %q, 4, nil", got, size, err, "LD I, long 0x1234")
+	}
+	got, size, err = c.Disassemble(0x204)
+	if err != nil || got != "CLS" || size != 2 {
+		t.Errorf("chip8.Disassemble(0x204) = %q, %v, %v, want %q, 2, nil", got, size, err, "CLS")
+	}
+	if _, _, err := c.Disassemble(0xFFFF); err == nil {
+		t.Errorf("chip8.Disassemble(0xFFFF) error = nil, want an error")
+	}
+}
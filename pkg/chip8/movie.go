@@ -0,0 +1,298 @@
+package chip8
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+)
+
+const (
+	// movieMagic identifies a movie.
+	movieMagic = "CH8M"
+	// movieVersion is incremented each time the layout of the movies changes.
+	movieVersion = 1
+)
+
+// Movie Layout, all values are little-endian:
+// +--------------------+
+// | magic "CH8M"       | 4 bytes
+// | version            | uint16
+// | ROM SHA-256        | 32 bytes
+// | platform name size | uint8
+// | platform name      | platform name size bytes
+// | seed               | int64
+// | VIP random         | bool
+// | frame count        | uint32
+// | frames             | frame count movieFrame
+// +--------------------+
+
+// movieFrame is a frame in a movie file.
+type movieFrame struct {
+	// Keys has the bit n set when the key n is pressed.
+	Keys     uint16
+	Cycles   uint32
+	Checksum uint32
+}
+
+// ErrMovieEnded is returned when playing a movie past its last frame.
+var ErrMovieEnded = errors.New("end of the movie")
+
+// Movie is a recording of a session: the configuration of the machine and the keys pressed on each frame, so the
+// session can be played again exactly. The state of the machine after each frame is stored as a checksum to detect
+// when the playback differs from the recording.
+type Movie struct {
+	// ROM is the SHA-256 of the program.
+	ROM [sha256.Size]byte
+	// Platform is the name of the platform emulated.
+	Platform string
+	// Seed is the seed of the random numbers.
+	Seed int64
+	// VIPRandom tells whether the random numbers are generated like the COSMAC VIP interpreter.
+	VIPRandom bool
+	Frames    []MovieFrame
+}
+
+// MovieFrame is the input of a frame and the checksum of the state of the machine at its end.
+type MovieFrame struct {
+	Keys [16]bool
+	// Cycles is the number of instructions executed per frame.
+	Cycles   int
+	Checksum uint32
+}
+
+// NewMovie return an empty movie of a program running on a platform, the machine being created with WithSeed(seed)
+// and WithVIPRandom() if vipRandom.
+func NewMovie(rom []byte, platform Platform, seed int64, vipRandom bool) *Movie {
+	return &Movie{
+		ROM:       sha256.Sum256(rom),
+		Platform:  platform.Name,
+		Seed:      seed,
+		VIPRandom: vipRandom,
+	}
+}
+
+// Machine return a machine configured like the recording, with the program loaded.
+// The program must be the one recorded.
+func (m *Movie) Machine(rom []byte) (*Chip8, error) {
+	if sha256.Sum256(rom) != m.ROM {
+		return nil, errors.New("the movie was recorded with another ROM")
+	}
+	platform, ok := PlatformByName(m.Platform)
+	if !ok {
+		return nil, fmt.Errorf("unknown platform of the movie: %v", m.Platform)
+	}
+
+	opts := []Option{WithSeed(m.Seed)}
+	if m.VIPRandom {
+		opts = append(opts, WithVIPRandom())
+	}
+	c := New(platform, opts...)
+	if err := c.LoadGame(rom); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// MarshalBinary encodes the movie.
+func (m *Movie) MarshalBinary() ([]byte, error) {
+	if len(m.Platform) > 0xFF {
+		return nil, errors.New("platform name too long")
+	}
+
+	frames := make([]movieFrame, len(m.Frames))
+	for i, f := range m.Frames {
+		for key, pressed := range f.Keys {
+			if pressed {
+				frames[i].Keys |= 1 << key
+			}
+		}
+		frames[i].Cycles = uint32(f.Cycles)
+		frames[i].Checksum = f.Checksum
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(movieMagic)
+	for _, data := range []interface{}{uint16(movieVersion), m.ROM, uint8(len(m.Platform)), []byte(m.Platform),
+		m.Seed, m.VIPRandom, uint32(len(frames)), frames} {
+		if err := binary.Write(&buf, binary.LittleEndian, data); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a movie returned by MarshalBinary.
+func (m *Movie) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+
+	magic := make([]byte, len(movieMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != movieMagic {
+		return errors.New("not a movie")
+	}
+
+	var version uint16
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return err
+	}
+	if version != movieVersion {
+		return fmt.Errorf("movie version not supported: %d", version)
+	}
+
+	var movie Movie
+	var nameSize uint8
+	for _, data := range []interface{}{&movie.ROM, &nameSize} {
+		if err := binary.Read(r, binary.LittleEndian, data); err != nil {
+			return err
+		}
+	}
+	name := make([]byte, nameSize)
+	var count uint32
+	for _, data := range []interface{}{name, &movie.Seed, &movie.VIPRandom, &count} {
+		if err := binary.Read(r, binary.LittleEndian, data); err != nil {
+			return err
+		}
+	}
+	movie.Platform = string(name)
+
+	if int64(count)*int64(binary.Size(movieFrame{})) != int64(r.Len()) {
+		return errors.New("invalid number of frames in the movie")
+	}
+	frames := make([]movieFrame, count)
+	if err := binary.Read(r, binary.LittleEndian, frames); err != nil {
+		return err
+	}
+	for _, f := range frames {
+		var keys [16]bool
+		for key := range keys {
+			keys[key] = f.Keys&(1<<key) != 0
+		}
+		movie.Frames = append(movie.Frames, MovieFrame{Keys: keys, Cycles: int(f.Cycles), Checksum: f.Checksum})
+	}
+
+	*m = movie
+	return nil
+}
+
+// LoadMovie reads a movie from a file.
+func LoadMovie(path string) (*Movie, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &Movie{}
+	if err := m.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Save writes the movie to a file.
+func (m *Movie) Save(path string) error {
+	data, err := m.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// checksum return the CRC-32 of the state of the machine.
+func checksum(c *Chip8) (uint32, error) {
+	state, err := c.MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+	return crc32.ChecksumIEEE(state), nil
+}
+
+// Recorder records the frames of a machine in a movie.
+type Recorder struct {
+	c     *Chip8
+	movie *Movie
+}
+
+// NewRecorder return a Recorder adding the frames of the machine to a movie.
+// The machine must be configured like the movie and not have run yet.
+func NewRecorder(c *Chip8, m *Movie) *Recorder {
+	return &Recorder{c: c, movie: m}
+}
+
+// GetNextFrame runs the machine for one frame, like Chip8.GetNextFrame, and adds it to the movie,
+// even when the machine stops with an error.
+func (r *Recorder) GetNextFrame(inputs [16]bool) ([]uint32, []int16, error) {
+	cycles := r.c.CyclesPerFrame()
+	fb, sb, err := r.c.GetNextFrame(inputs)
+
+	// The frame ran up to the error, it is added anyway so the movie still plays back to the same state
+	sum, serr := checksum(r.c)
+	if serr != nil {
+		return nil, nil, serr
+	}
+	r.movie.Frames = append(r.movie.Frames, MovieFrame{Keys: inputs, Cycles: cycles, Checksum: sum})
+	return fb, sb, err
+}
+
+// DesyncError tells that the state of the machine differs from the recording, the playback no longer reproduces it.
+type DesyncError struct {
+	// Frame is the first frame ending with another state, the first frame of the movie being 1.
+	Frame int
+}
+
+func (e *DesyncError) Error() string {
+	return fmt.Sprintf("desync at frame %d: the state of the machine differs from the recording", e.Frame)
+}
+
+// Player plays the frames of a movie on a machine.
+type Player struct {
+	c        *Chip8
+	movie    *Movie
+	frame    int
+	desynced bool
+}
+
+// NewPlayer return a Player running a machine with the inputs of a movie.
+// The machine must be the one returned by Movie.Machine, which has not run yet.
+func NewPlayer(c *Chip8, m *Movie) *Player {
+	return &Player{c: c, movie: m}
+}
+
+// Done tells whether all the frames of the movie were played.
+func (p *Player) Done() bool {
+	return p.frame >= len(p.movie.Frames)
+}
+
+// Frame return the number of frames played.
+func (p *Player) Frame() int {
+	return p.frame
+}
+
+// GetNextFrame runs the machine for the next frame of the movie, like Chip8.GetNextFrame, and compares its state
+// with the recording. The first frame whose state differs returns its video and audio data with a *DesyncError,
+// the following ones are no longer compared. ErrMovieEnded is returned once all the frames were played.
+func (p *Player) GetNextFrame() ([]uint32, []int16, error) {
+	if p.Done() {
+		return nil, nil, ErrMovieEnded
+	}
+	f := p.movie.Frames[p.frame]
+	p.frame++
+
+	p.c.SetCyclesPerFrame(f.Cycles)
+	fb, sb, err := p.c.GetNextFrame(f.Keys)
+	if err != nil || p.desynced {
+		return fb, sb, err
+	}
+
+	sum, err := checksum(p.c)
+	if err != nil {
+		return nil, nil, err
+	}
+	if sum != f.Checksum {
+		p.desynced = true
+		return fb, sb, &DesyncError{Frame: p.frame}
+	}
+	return fb, sb, nil
+}
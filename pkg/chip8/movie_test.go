@@ -0,0 +1,161 @@
+package chip8
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// recordMovie records frames of randomSprites with a key pressed on each frame, and the speed doubled halfway.
+func recordMovie(t *testing.T, frames int) (*Movie, [][]uint32) {
+	m := NewMovie(randomSprites, PlatformSCHIP11, 42, false)
+	c, err := m.Machine(randomSprites)
+	if err != nil {
+		t.Fatalf("Movie.Machine() error = %v", err)
+	}
+
+	r := NewRecorder(c, m)
+	var fbs [][]uint32
+	for i := 0; i < frames; i++ {
+		if i == frames/2 {
+			c.SetCyclesPerFrame(c.CyclesPerFrame() * 2)
+		}
+		fb, _, err := r.GetNextFrame(pressed(byte(i % 16)))
+		if err != nil {
+			t.Fatalf("Recorder.GetNextFrame() error = %v", err)
+		}
+		fbs = append(fbs, fb)
+	}
+	return m, fbs
+}
+
+func TestMovie_MarshalBinary(t *testing.T) {
+	m, _ := recordMovie(t, 10)
+	data, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Movie.MarshalBinary() error = %v", err)
+	}
+
+	var got Movie
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatalf("Movie.UnmarshalBinary() error = %v", err)
+	}
+	if !reflect.DeepEqual(&got, m) {
+		t.Errorf("Movie.UnmarshalBinary() = %+v, want %+v", got, m)
+	}
+
+	for _, data := range [][]byte{nil, []byte("CH8S"), data[:len(data)-1]} {
+		if err := got.UnmarshalBinary(data); err == nil {
+			t.Errorf("Movie.UnmarshalBinary(%q) error = nil, want an error", data)
+		}
+	}
+}
+
+func TestMovie_Save(t *testing.T) {
+	dir, err := ioutil.TempDir("", "movie")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m, _ := recordMovie(t, 10)
+	path := filepath.Join(dir, "game.movie")
+	if err := m.Save(path); err != nil {
+		t.Fatalf("Movie.Save() error = %v", err)
+	}
+	got, err := LoadMovie(path)
+	if err != nil {
+		t.Fatalf("LoadMovie() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, m) {
+		t.Errorf("LoadMovie() = %+v, want %+v", got, m)
+	}
+
+	if _, err := LoadMovie(filepath.Join(dir, "missing.movie")); err == nil {
+		t.Errorf("LoadMovie() error = nil for a missing file, want an error")
+	}
+}
+
+func TestPlayer(t *testing.T) {
+	m, want := recordMovie(t, 20)
+	c, err := m.Machine(randomSprites)
+	if err != nil {
+		t.Fatalf("Movie.Machine() error = %v", err)
+	}
+
+	p := NewPlayer(c, m)
+	for i := 0; !p.Done(); i++ {
+		fb, _, err := p.GetNextFrame()
+		if err != nil {
+			t.Fatalf("Player.GetNextFrame() error = %v on frame %d", err, i+1)
+		}
+		if !reflect.DeepEqual(fb, want[i]) {
+			t.Errorf("Player.GetNextFrame() played another frame %d than recorded", i+1)
+		}
+	}
+	if _, _, err := p.GetNextFrame(); err != ErrMovieEnded {
+		t.Errorf("Player.GetNextFrame() error = %v after the movie, want %v", err, ErrMovieEnded)
+	}
+
+	if _, err := m.Machine([]byte{0x00, 0xE0}); err == nil {
+		t.Errorf("Movie.Machine() error = nil with another ROM, want an error")
+	}
+}
+
+func TestRecorder_error(t *testing.T) {
+	// The second frame stops on an unknown opcode
+	rom := []byte{0x60, 0x01, 0xF0, 0x15, 0xF0, 0x07, 0x30, 0x00, 0x12, 0x04, 0xF0, 0x88}
+	m := NewMovie(rom, PlatformVIP, 42, false)
+	c, err := m.Machine(rom)
+	if err != nil {
+		t.Fatalf("Movie.Machine() error = %v", err)
+	}
+	r := NewRecorder(c, m)
+	for i := 0; i < 3; i++ {
+		if _, _, err := r.GetNextFrame(pressed(byte(i))); (err != nil) != (i == 1) {
+			t.Fatalf("Recorder.GetNextFrame() error = %v on frame %d", err, i+1)
+		}
+	}
+	if len(m.Frames) != 3 {
+		t.Fatalf("Recorder.GetNextFrame() recorded %d frames, want 3", len(m.Frames))
+	}
+
+	c, err = m.Machine(rom)
+	if err != nil {
+		t.Fatalf("Movie.Machine() error = %v", err)
+	}
+	p := NewPlayer(c, m)
+	for !p.Done() {
+		if _, _, err := p.GetNextFrame(); err != nil && !errors.Is(err, ErrUnknownOpcode) {
+			t.Fatalf("Player.GetNextFrame() error = %v, want nil or %v", err, ErrUnknownOpcode)
+		}
+	}
+}
+
+func TestPlayer_desync(t *testing.T) {
+	m, _ := recordMovie(t, 20)
+	// The state differs from the frame 5 on
+	m.Frames[4].Checksum++
+
+	c, err := m.Machine(randomSprites)
+	if err != nil {
+		t.Fatalf("Movie.Machine() error = %v", err)
+	}
+	p := NewPlayer(c, m)
+	var desyncs []int
+	for !p.Done() {
+		if _, _, err := p.GetNextFrame(); err != nil {
+			d, ok := err.(*DesyncError)
+			if !ok {
+				t.Fatalf("Player.GetNextFrame() error = %v, want a *DesyncError", err)
+			}
+			desyncs = append(desyncs, d.Frame)
+		}
+	}
+	if !reflect.DeepEqual(desyncs, []int{5}) {
+		t.Errorf("Player.GetNextFrame() desynced at frames %v, want only 5", desyncs)
+	}
+}
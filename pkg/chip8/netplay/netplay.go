@@ -0,0 +1,480 @@
+// Package netplay lets two players on separate machines play the same CHIP-8 program over UDP.
+//
+// Each player runs its own machine, and the keypad of every frame is the keys pressed by both players. The keys of
+// the other player take time to arrive, so instead of waiting for them the session predicts they did not change and
+// runs the frame at once. When the keys arrive and differ from the prediction, the machine is rolled back to its
+// save state before the frame and the frames since are run again. The random numbers are generated from the same
+// seed on both machines, so they stay in the same state.
+//
+// The host chooses the configuration of the machines and waits for the guest to join. The keys pressed locally can
+// be delayed by a few frames, which gives them time to reach the other player before they are needed.
+package netplay
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/Bit-Doctor/emulation/pkg/chip8"
+)
+
+// MaxPrediction is the number of frames the session runs ahead of the last frame with the keys of the other player,
+// after which it waits for them.
+const MaxPrediction = 8
+
+// Timeout is how long the session waits for the other player before giving up.
+var Timeout = 5 * time.Second
+
+// JoinTimeout is how long the guest tries to reach the host.
+var JoinTimeout = 30 * time.Second
+
+// retryInterval is the time between two sends of the packets still waiting for an answer.
+const retryInterval = 50 * time.Millisecond
+
+// pollInterval is how long to wait for the packets already arrived, as a deadline already passed fails at once.
+const pollInterval = time.Millisecond
+
+// lingerRetries is the number of retry intervals without a packet after which the session is synchronized.
+const lingerRetries = 3
+
+// maxKeypads is the number of keypads sent in an input packet at most.
+const maxKeypads = 0xFF
+
+var (
+	// ErrTimeout is returned when the other player does not answer.
+	ErrTimeout = errors.New("the other player does not answer")
+	// ErrDisconnected is returned when the other player left the session.
+	ErrDisconnected = errors.New("the other player left")
+)
+
+// Config is the configuration of the machines of a session.
+type Config struct {
+	Platform chip8.Platform
+	// Seed is the seed of the random numbers.
+	Seed int64
+	// VIPRandom tells whether the random numbers are generated like the COSMAC VIP interpreter.
+	VIPRandom bool
+	// Cycles is the number of instructions executed per frame, the default of the platform when 0.
+	Cycles int
+	// Delay is the number of frames before the keys pressed locally are used.
+	Delay int
+}
+
+// Session runs a machine with the keys of both players.
+type Session struct {
+	c        *chip8.Chip8
+	platform chip8.Platform
+	conn     net.PacketConn
+	peer     net.Addr
+	// The welcome packet resent by the host when the guest did not receive it.
+	welcome []byte
+
+	// The number of frames run.
+	frame int
+	// The keypads of the local player by frame, including the delayed ones not run yet.
+	local []uint16
+	// The keypads of the other player received, by frame.
+	remote []uint16
+	// The number of frames of the local player received by the other player.
+	acked int
+	// The keypads of the other player predicted for the frames run without its keys, from len(remote) on.
+	predicted map[int]uint16
+	// The save states before the frames from len(remote) on.
+	states map[int][]byte
+	// The first frame run with a wrong prediction, -1 for none.
+	rollback int
+	closed   bool
+}
+
+// Host waits for a guest on conn and return the session running the program with the configuration.
+func Host(conn net.PacketConn, rom []byte, config Config) (*Session, error) {
+	platform := config.Platform
+	w := welcome{
+		ROM:       sha256.Sum256(rom),
+		Platform:  platform.Name,
+		Seed:      config.Seed,
+		VIPRandom: config.VIPRandom,
+		Cycles:    config.Cycles,
+		Delay:     config.Delay,
+	}
+	if w.Cycles == 0 {
+		w.Cycles = platform.CyclePerFrame
+	}
+	if len(w.Platform) > 0xFF || w.Cycles < 1 || w.Delay < 0 || w.Delay > MaxPrediction {
+		return nil, errors.New("invalid netplay configuration")
+	}
+
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return nil, err
+		}
+		kind, r, err := decodePacket(buf[:n])
+		if err != nil || kind != helloPacket {
+			continue
+		}
+		hash, err := decodeHello(r)
+		if err != nil {
+			continue
+		}
+
+		// The guest is told the configuration even when it has another ROM, so it can report it
+		packet := encodeWelcome(w)
+		if _, err := conn.WriteTo(packet, addr); err != nil {
+			return nil, err
+		}
+		if hash != w.ROM {
+			return nil, fmt.Errorf("the guest %v has another ROM", addr)
+		}
+
+		s, err := newSession(conn, addr, rom, w)
+		if err != nil {
+			return nil, err
+		}
+		s.welcome = packet
+		return s, nil
+	}
+}
+
+// Join joins the session of a host and return it, running the program with the configuration of the host.
+func Join(conn net.PacketConn, host net.Addr, rom []byte) (*Session, error) {
+	hello := encodeHello(sha256.Sum256(rom))
+	buf := make([]byte, maxPacketSize)
+	deadline := time.Now().Add(JoinTimeout)
+	for time.Now().Before(deadline) {
+		if _, err := conn.WriteTo(hello, host); err != nil {
+			return nil, err
+		}
+
+		conn.SetReadDeadline(time.Now().Add(retryInterval))
+		n, addr, err := conn.ReadFrom(buf)
+		if isTimeout(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if addr.String() != host.String() {
+			continue
+		}
+		kind, r, err := decodePacket(buf[:n])
+		if err != nil || kind != welcomePacket {
+			continue
+		}
+		w, err := decodeWelcome(r)
+		if err != nil {
+			continue
+		}
+		if w.ROM != sha256.Sum256(rom) {
+			return nil, errors.New("the host has another ROM")
+		}
+		return newSession(conn, host, rom, w)
+	}
+	return nil, ErrTimeout
+}
+
+// Connect hosts a session on the UDP address host if not empty, or joins the session hosted at the UDP address join.
+// The configuration is only used by the host.
+func Connect(host, join string, rom []byte, config Config) (*Session, error) {
+	if host != "" {
+		conn, err := net.ListenPacket("udp", host)
+		if err != nil {
+			return nil, err
+		}
+		return Host(conn, rom, config)
+	}
+
+	addr, err := net.ResolveUDPAddr("udp", join)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenPacket("udp", ":0")
+	if err != nil {
+		return nil, err
+	}
+	return Join(conn, addr, rom)
+}
+
+// newSession return a session with the remote player, running a new machine with the configuration.
+func newSession(conn net.PacketConn, peer net.Addr, rom []byte, w welcome) (*Session, error) {
+	platform, ok := chip8.PlatformByName(w.Platform)
+	if !ok {
+		return nil, fmt.Errorf("unknown platform: %v", w.Platform)
+	}
+	opts := []chip8.Option{chip8.WithSeed(w.Seed)}
+	if w.VIPRandom {
+		opts = append(opts, chip8.WithVIPRandom())
+	}
+	c := chip8.New(platform, opts...)
+	c.SetCyclesPerFrame(w.Cycles)
+	if err := c.LoadGame(rom); err != nil {
+		return nil, err
+	}
+
+	return &Session{
+		c:        c,
+		platform: platform,
+		conn:     conn,
+		peer:     peer,
+		// Nobody presses a key during the delay at the start
+		local:     make([]uint16, w.Delay),
+		remote:    make([]uint16, w.Delay),
+		predicted: make(map[int]uint16),
+		states:    make(map[int][]byte),
+		rollback:  -1,
+	}, nil
+}
+
+// Machine return the machine run by the session.
+// It must only be run by the session, and its speed must not change.
+func (s *Session) Machine() *chip8.Chip8 {
+	return s.c
+}
+
+// Platform return the platform emulated by the machine.
+func (s *Session) Platform() chip8.Platform {
+	return s.platform
+}
+
+// Frame return the number of frames run.
+func (s *Session) Frame() int {
+	return s.frame
+}
+
+// GetNextFrame runs the machine for one frame with the keys pressed locally, like Chip8.GetNextFrame, once the
+// frames with a wrong prediction of the keys of the other player were run again. It waits for the other player when
+// it is MaxPrediction frames behind.
+func (s *Session) GetNextFrame(inputs [16]bool) ([]uint32, []int16, error) {
+	var keypad uint16
+	for key, pressed := range inputs {
+		if pressed {
+			keypad |= 1 << key
+		}
+	}
+	s.local = append(s.local, keypad)
+
+	if err := s.send(); err != nil {
+		return nil, nil, err
+	}
+	if _, err := s.receive(false); err != nil {
+		return nil, nil, err
+	}
+	if err := s.wait(func() bool { return s.frame-len(s.remote) < MaxPrediction }); err != nil {
+		return nil, nil, err
+	}
+	if err := s.resimulate(); err != nil {
+		return nil, nil, err
+	}
+
+	fb, sb, err := s.run()
+	if err != nil {
+		return nil, nil, err
+	}
+	return fb, sb, nil
+}
+
+// Sync waits for the keys of the other player for all the frames run, and for the other player to receive all the
+// local keys, then runs again the frames with a wrong prediction.
+// The machine is then in the same state for both players once they ran the same number of frames.
+func (s *Session) Sync() error {
+	if err := s.wait(func() bool { return len(s.remote) >= s.frame && s.acked == len(s.local) }); err != nil {
+		return err
+	}
+	if err := s.resimulate(); err != nil {
+		return err
+	}
+
+	// The other player may still wait for the acknowledgement of its keys, it is sent as long as it is asked for
+	for quiet := 0; quiet < lingerRetries; quiet++ {
+		asked, err := s.receive(true)
+		if err == ErrDisconnected {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if asked {
+			if err := s.send(); err != nil {
+				return err
+			}
+			quiet = -1
+		}
+	}
+	return nil
+}
+
+// Close tells the other player that the session ended.
+// The connection is left open.
+func (s *Session) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	_, err := s.conn.WriteTo(encodeBye(), s.peer)
+	return err
+}
+
+// run runs the next frame, with the prediction of the keys of the other player when they have not arrived.
+func (s *Session) run() ([]uint32, []int16, error) {
+	frame := s.frame
+	remote, known := s.remoteKeypad(frame)
+	if !known {
+		state, err := s.c.MarshalBinary()
+		if err != nil {
+			return nil, nil, err
+		}
+		s.states[frame] = state
+		s.predicted[frame] = remote
+	}
+
+	var inputs [16]bool
+	for key := range inputs {
+		inputs[key] = (s.local[frame]|remote)&(1<<key) != 0
+	}
+	s.frame++
+	return s.c.GetNextFrame(inputs)
+}
+
+// remoteKeypad return the keypad of the other player on a frame and whether it was received, or its prediction,
+// the last keypad received.
+func (s *Session) remoteKeypad(frame int) (uint16, bool) {
+	if frame < len(s.remote) {
+		return s.remote[frame], true
+	}
+	if len(s.remote) == 0 {
+		return 0, false
+	}
+	return s.remote[len(s.remote)-1], false
+}
+
+// resimulate rolls the machine back to the first frame run with a wrong prediction and runs the frames again.
+func (s *Session) resimulate() error {
+	if s.rollback >= 0 {
+		if err := s.c.UnmarshalBinary(s.states[s.rollback]); err != nil {
+			return err
+		}
+		end := s.frame
+		s.frame = s.rollback
+		s.rollback = -1
+		for s.frame < end {
+			if _, _, err := s.run(); err != nil {
+				return err
+			}
+		}
+	}
+
+	// The states before the frames with the keys of both players are no longer needed
+	for frame := range s.states {
+		if frame < len(s.remote) {
+			delete(s.states, frame)
+			delete(s.predicted, frame)
+		}
+	}
+	return nil
+}
+
+// send sends the keypads of the local player not received yet by the other player.
+func (s *Session) send() error {
+	in := input{Ack: len(s.remote), Start: s.acked, Keypads: s.local[s.acked:]}
+	if len(in.Keypads) > maxKeypads {
+		in.Keypads = in.Keypads[:maxKeypads]
+	}
+	_, err := s.conn.WriteTo(encodeInput(in), s.peer)
+	return err
+}
+
+// wait receives the packets of the other player until done return true, sending the local keypads again
+// periodically.
+func (s *Session) wait(done func() bool) error {
+	deadline := time.Now().Add(Timeout)
+	for !done() {
+		if time.Now().After(deadline) {
+			return ErrTimeout
+		}
+		if err := s.send(); err != nil {
+			return err
+		}
+		if _, err := s.receive(true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// receive handles the packets of the other player which arrived, or which arrive during retryInterval if block.
+// It return whether the other player still waits for local keys or for the acknowledgement of its own.
+func (s *Session) receive(block bool) (bool, error) {
+	buf := make([]byte, maxPacketSize)
+	wait := pollInterval
+	if block {
+		wait = retryInterval
+	}
+	s.conn.SetReadDeadline(time.Now().Add(wait))
+	asked := false
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if isTimeout(err) {
+			return asked, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if addr.String() != s.peer.String() {
+			continue
+		}
+
+		kind, r, err := decodePacket(buf[:n])
+		if err != nil {
+			continue
+		}
+		switch kind {
+		case helloPacket:
+			// The guest did not receive the configuration
+			if s.welcome != nil {
+				if _, err := s.conn.WriteTo(s.welcome, s.peer); err != nil {
+					return false, err
+				}
+			}
+		case inputPacket:
+			in, err := decodeInput(r)
+			if err != nil {
+				continue
+			}
+			s.input(in)
+			asked = asked || len(in.Keypads) > 0 || in.Ack < len(s.local)
+		case byePacket:
+			return false, ErrDisconnected
+		}
+		// Only wait for the first packet, those arrived meanwhile are read without waiting
+		s.conn.SetReadDeadline(time.Now().Add(pollInterval))
+	}
+}
+
+// input records the keypads of the other player, and the first frame to run again when they differ from the
+// predictions.
+func (s *Session) input(in input) {
+	if in.Ack > s.acked && in.Ack <= len(s.local) {
+		s.acked = in.Ack
+	}
+
+	// The keypads start at most at the first frame missing, as it was acknowledged
+	if in.Start > len(s.remote) {
+		return
+	}
+	for i := len(s.remote) - in.Start; i < len(in.Keypads); i++ {
+		frame := len(s.remote)
+		keypad := in.Keypads[i]
+		s.remote = append(s.remote, keypad)
+		if predicted, ok := s.predicted[frame]; ok && predicted != keypad && s.rollback < 0 {
+			s.rollback = frame
+		}
+	}
+}
+
+func isTimeout(err error) bool {
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout()
+}
@@ -0,0 +1,228 @@
+package netplay
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"testing"
+
+	"github.com/Bit-Doctor/emulation/pkg/chip8"
+)
+
+// lossyConn drops one packet out of every drop written.
+type lossyConn struct {
+	net.PacketConn
+	drop int
+	n    int
+}
+
+func (c *lossyConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	c.n++
+	if c.drop > 0 && c.n%c.drop == 0 {
+		return len(p), nil
+	}
+	return c.PacketConn.WriteTo(p, addr)
+}
+
+// keys return the keys pressed by a player on a frame: the first player moves its paddle with 1 and 4, the second
+// with C and D, each at its own pace.
+func keys(player, frame int) [16]bool {
+	var inputs [16]bool
+	if player == 0 {
+		inputs[0x1] = frame/20%2 == 0
+		inputs[0x4] = frame/20%2 == 1
+	} else {
+		inputs[0xC] = frame/13%3 == 0
+		inputs[0xD] = frame/13%3 == 1
+	}
+	return inputs
+}
+
+// listen return a connection on a free port of the loopback interface.
+func listen(t *testing.T, drop int) *lossyConn {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &lossyConn{PacketConn: conn, drop: drop}
+}
+
+// play runs a session of pong2 between two players for a number of frames and return the states of their machines.
+func play(t *testing.T, rom []byte, config Config, frames, drop int) [2][]byte {
+	hostConn, guestConn := listen(t, drop), listen(t, drop)
+	defer hostConn.Close()
+	defer guestConn.Close()
+
+	var states [2][]byte
+	errs := make(chan error, 2)
+	run := func(player int, connect func() (*Session, error)) {
+		s, err := connect()
+		if err != nil {
+			errs <- err
+			return
+		}
+		for frame := 0; frame < frames; frame++ {
+			if _, _, err := s.GetNextFrame(keys(player, frame)); err != nil {
+				errs <- err
+				return
+			}
+		}
+		if err := s.Sync(); err != nil {
+			errs <- err
+			return
+		}
+		states[player], err = s.Machine().MarshalBinary()
+		errs <- err
+	}
+	go run(0, func() (*Session, error) { return Host(hostConn, rom, config) })
+	go run(1, func() (*Session, error) { return Join(guestConn, hostConn.LocalAddr(), rom) })
+
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("session error = %v", err)
+		}
+	}
+	return states
+}
+
+func TestSession(t *testing.T) {
+	rom, err := ioutil.ReadFile("../../../roms/pong2.ch8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		delay int
+		drop  int
+	}{
+		{"no delay", 0, 0},
+		{"delay", 2, 0},
+		{"lost packets", 0, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const frames = 300
+			config := Config{Platform: chip8.PlatformVIP, Seed: 42, Delay: tt.delay}
+			states := play(t, rom, config, frames, tt.drop)
+
+			// A single machine with the keys of both players reaches the same state
+			c := chip8.New(chip8.PlatformVIP, chip8.WithSeed(42))
+			if err := c.LoadGame(rom); err != nil {
+				t.Fatal(err)
+			}
+			for frame := 0; frame < frames; frame++ {
+				var inputs [16]bool
+				if frame >= tt.delay {
+					host, guest := keys(0, frame-tt.delay), keys(1, frame-tt.delay)
+					for key := range inputs {
+						inputs[key] = host[key] || guest[key]
+					}
+				}
+				if _, _, err := c.GetNextFrame(inputs); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := c.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(states[0], states[1]) {
+				t.Errorf("the machines of the players are in different states")
+			}
+			if !bytes.Equal(states[0], want) {
+				t.Errorf("the machines of the players are in another state than with the keys of both players")
+			}
+		})
+	}
+}
+
+func TestJoin_anotherROM(t *testing.T) {
+	hostConn, guestConn := listen(t, 0), listen(t, 0)
+	defer hostConn.Close()
+	defer guestConn.Close()
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := Host(hostConn, []byte{0x12, 0x00}, Config{Platform: chip8.PlatformOcto})
+		errs <- err
+	}()
+	if _, err := Join(guestConn, hostConn.LocalAddr(), []byte{0x00, 0xE0}); err == nil {
+		t.Errorf("Join() error = nil with another ROM, want an error")
+	}
+	if err := <-errs; err == nil {
+		t.Errorf("Host() error = nil with another ROM, want an error")
+	}
+}
+
+func TestConnect(t *testing.T) {
+	// The port of a closed connection is free for the host
+	conn := listen(t, 0)
+	host := conn.LocalAddr().String()
+	conn.Close()
+
+	rom := []byte{0x12, 0x00}
+	errs := make(chan error, 1)
+	go func() {
+		s, err := Connect(host, "", rom, Config{Platform: chip8.PlatformOcto})
+		if err == nil {
+			err = s.Close()
+		}
+		errs <- err
+	}()
+	s, err := Connect("", host, rom, Config{})
+	if err != nil {
+		t.Fatalf("Connect() to join error = %v", err)
+	}
+	if s.Platform().Name != chip8.PlatformOcto.Name {
+		t.Errorf("Connect() to join platform = %v, want the platform of the host", s.Platform().Name)
+	}
+	if err := <-errs; err != nil {
+		t.Errorf("Connect() to host error = %v", err)
+	}
+
+	if _, err := Connect("", "no port", rom, Config{}); err == nil {
+		t.Errorf("Connect() error = nil joining an invalid address, want an error")
+	}
+	if _, err := Connect("no port", "", rom, Config{Platform: chip8.PlatformOcto}); err == nil {
+		t.Errorf("Connect() error = nil hosting on an invalid address, want an error")
+	}
+}
+
+func TestSession_Close(t *testing.T) {
+	hostConn, guestConn := listen(t, 0), listen(t, 0)
+	defer hostConn.Close()
+	defer guestConn.Close()
+
+	rom := []byte{0x12, 0x00}
+	sessions := make(chan *Session, 1)
+	go func() {
+		s, err := Host(hostConn, rom, Config{Platform: chip8.PlatformOcto})
+		if err != nil {
+			t.Error(err)
+		}
+		sessions <- s
+	}()
+	guest, err := Join(guestConn, hostConn.LocalAddr(), rom)
+	if err != nil {
+		t.Fatalf("Join() error = %v", err)
+	}
+	host := <-sessions
+	if host == nil {
+		t.FailNow()
+	}
+
+	if err := guest.Close(); err != nil {
+		t.Fatalf("Session.Close() error = %v", err)
+	}
+	var inputs [16]bool
+	for i := 0; i <= MaxPrediction; i++ {
+		if _, _, err = host.GetNextFrame(inputs); err != nil {
+			break
+		}
+	}
+	if err != ErrDisconnected {
+		t.Errorf("Session.GetNextFrame() error = %v after the other player left, want %v", err, ErrDisconnected)
+	}
+}
@@ -0,0 +1,163 @@
+package netplay
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	// packetMagic identifies the packets of the protocol.
+	packetMagic = "CH8N"
+	// protocolVersion is incremented each time the layout of the packets changes.
+	protocolVersion = 1
+	// maxPacketSize is larger than any packet, the inputs sent being limited by the frames predicted.
+	maxPacketSize = 1024
+)
+
+// Packet Layout, all values are little-endian:
+// +------------------+
+// | magic "CH8N"     | 4 bytes
+// | version          | uint16
+// | type             | uint8
+// | payload          | depends on the type
+// +------------------+
+//
+// hello, from the guest:   ROM SHA-256
+// welcome, from the host:  ROM SHA-256, platform name size (uint8), platform name, seed (int64), VIP random (bool),
+//                          cycles per frame (uint32), input delay (uint8)
+// input, from both:        ack (uint32), start (uint32), count (uint8), count keypads (uint16)
+// bye, from both:          nothing
+
+// The types of the packets.
+const (
+	helloPacket = iota + 1
+	welcomePacket
+	inputPacket
+	byePacket
+)
+
+// welcome is the configuration of a session sent by the host.
+type welcome struct {
+	ROM       [sha256.Size]byte
+	Platform  string
+	Seed      int64
+	VIPRandom bool
+	Cycles    int
+	Delay     int
+}
+
+// input carries the keypads of consecutive frames of a player.
+type input struct {
+	// Ack is the number of frames of the other player received, the inputs from it on are still needed.
+	Ack int
+	// Start is the frame of the first keypad.
+	Start   int
+	Keypads []uint16
+}
+
+var errInvalidPacket = errors.New("invalid packet")
+
+// encodePacket return a packet of a type with its payload, a sequence of values encoded by encoding/binary.
+func encodePacket(kind byte, payload ...interface{}) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(packetMagic)
+	for _, data := range append([]interface{}{uint16(protocolVersion), kind}, payload...) {
+		// Writing fixed size values to a buffer cannot fail
+		binary.Write(&buf, binary.LittleEndian, data)
+	}
+	return buf.Bytes()
+}
+
+func encodeHello(rom [sha256.Size]byte) []byte {
+	return encodePacket(helloPacket, rom)
+}
+
+func encodeWelcome(w welcome) []byte {
+	return encodePacket(welcomePacket, w.ROM, uint8(len(w.Platform)), []byte(w.Platform), w.Seed, w.VIPRandom,
+		uint32(w.Cycles), uint8(w.Delay))
+}
+
+func encodeInput(in input) []byte {
+	return encodePacket(inputPacket, uint32(in.Ack), uint32(in.Start), uint8(len(in.Keypads)), in.Keypads)
+}
+
+func encodeBye() []byte {
+	return encodePacket(byePacket)
+}
+
+// decodePacket return the type of a packet, and a reader of its payload.
+func decodePacket(data []byte) (byte, *bytes.Reader, error) {
+	r := bytes.NewReader(data)
+	magic := make([]byte, len(packetMagic))
+	var version uint16
+	var kind byte
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != packetMagic {
+		return 0, nil, errInvalidPacket
+	}
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil || version != protocolVersion {
+		return 0, nil, errInvalidPacket
+	}
+	if err := binary.Read(r, binary.LittleEndian, &kind); err != nil {
+		return 0, nil, errInvalidPacket
+	}
+	return kind, r, nil
+}
+
+// read decodes the values of a payload, which must have no other data.
+func read(r *bytes.Reader, values ...interface{}) error {
+	for _, v := range values {
+		if err := binary.Read(r, binary.LittleEndian, v); err != nil {
+			return errInvalidPacket
+		}
+	}
+	if r.Len() != 0 {
+		return errInvalidPacket
+	}
+	return nil
+}
+
+func decodeHello(r *bytes.Reader) ([sha256.Size]byte, error) {
+	var rom [sha256.Size]byte
+	return rom, read(r, &rom)
+}
+
+func decodeWelcome(r *bytes.Reader) (welcome, error) {
+	var w welcome
+	var size uint8
+	if err := binary.Read(r, binary.LittleEndian, &w.ROM); err != nil {
+		return w, errInvalidPacket
+	}
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return w, errInvalidPacket
+	}
+	name := make([]byte, size)
+	var cycles uint32
+	var delay uint8
+	if err := read(r, name, &w.Seed, &w.VIPRandom, &cycles, &delay); err != nil {
+		return w, err
+	}
+	w.Platform, w.Cycles, w.Delay = string(name), int(cycles), int(delay)
+	return w, nil
+}
+
+func decodeInput(r *bytes.Reader) (input, error) {
+	var ack, start uint32
+	var count uint8
+	if err := binary.Read(r, binary.LittleEndian, &ack); err != nil {
+		return input{}, errInvalidPacket
+	}
+	if err := binary.Read(r, binary.LittleEndian, &start); err != nil {
+		return input{}, errInvalidPacket
+	}
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return input{}, errInvalidPacket
+	}
+	keypads := make([]uint16, count)
+	if err := read(r, keypads); err != nil {
+		return input{}, err
+	}
+	return input{Ack: int(ack), Start: int(start), Keypads: keypads}, nil
+}
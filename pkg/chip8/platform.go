@@ -0,0 +1,217 @@
+package chip8
+
+// InstructionSet is a version of the CHIP-8 language, each one extending the previous.
+type InstructionSet int
+
+const (
+	// CHIP8 is the original instruction set of the COSMAC VIP interpreter.
+	CHIP8 InstructionSet = iota
+	// SCHIP10 adds the high resolution mode, 16x16 sprites, big digits, exit and the RPL flags.
+	SCHIP10
+	// SCHIP11 adds the scrolling instructions.
+	SCHIP11
+	// XOCHIP adds the bitplanes, audio patterns, long loads, registers ranges and scrolling up.
+	XOCHIP
+)
+
+// Font holds the sprites of the digits provided by an interpreter.
+type Font struct {
+	// Small are the 8x5 sprites of the hexadecimal digits 0 through F.
+	Small [16 * 5]byte
+	// Big are the 8x10 sprites of the digits 0 through 9, or 0 through F on some interpreters.
+	Big [16 * 10]byte
+}
+
+// Platform describes an interpreter of the CHIP-8 language.
+type Platform struct {
+	// Name identifies the platform.
+	Name string
+	// InstructionSet is the set of instructions understood by the interpreter, the others are rejected.
+	InstructionSet InstructionSet
+	// Quirks is the semantic of the ambiguous instructions on this interpreter.
+	Quirks Quirks
+	// MemorySize is the number of bytes of memory.
+	MemorySize int
+	// LoadAddress is the memory location where programs are loaded and started.
+	LoadAddress uint16
+	// CyclePerFrame is the number of CPU cycle per frame.
+	CyclePerFrame int
+	// Font is the digits sprites stored in the interpreter area of the memory.
+	Font Font
+	// DisplayWidth is the number of pixels in a row of the largest display mode.
+	DisplayWidth int
+	// DisplayHeight is the number of pixels in a column of the largest display mode.
+	DisplayHeight int
+}
+
+// FontVIP is the font of the COSMAC VIP interpreter, it has no big digits.
+var FontVIP = Font{
+	Small: [16 * 5]byte{
+		0xF0, 0x90, 0x90, 0x90, 0xF0, //0
+		0x60, 0x20, 0x20, 0x20, 0x70, //1
+		0xF0, 0x10, 0xF0, 0x80, 0xF0, //2
+		0xF0, 0x10, 0x70, 0x10, 0xF0, //3
+		0xA0, 0xA0, 0xF0, 0x20, 0x20, //4
+		0xF0, 0x80, 0xF0, 0x10, 0xF0, //5
+		0xF0, 0x80, 0xF0, 0x90, 0xF0, //6
+		0xF0, 0x10, 0x10, 0x10, 0x10, //7
+		0xF0, 0x90, 0xF0, 0x90, 0xF0, //8
+		0xF0, 0x90, 0xF0, 0x10, 0xF0, //9
+		0xF0, 0x90, 0xF0, 0x90, 0x90, //A
+		0xF0, 0x50, 0x70, 0x50, 0xF0, //B
+		0xF0, 0x80, 0x80, 0x80, 0xF0, //C
+		0xF0, 0x50, 0x50, 0x50, 0xF0, //D
+		0xF0, 0x80, 0xF0, 0x80, 0xF0, //E
+		0xF0, 0x80, 0xF0, 0x80, 0x80, //F
+	},
+}
+
+// FontSCHIP is the font of the CHIP-48 and SUPER-CHIP interpreters, its big digits only go from 0 through 9.
+var FontSCHIP = Font{
+	Small: [16 * 5]byte{
+		0xF0, 0x90, 0x90, 0x90, 0xF0, //0
+		0x20, 0x60, 0x20, 0x20, 0x70, //1
+		0xF0, 0x10, 0xF0, 0x80, 0xF0, //2
+		0xF0, 0x10, 0xF0, 0x10, 0xF0, //3
+		0x90, 0x90, 0xF0, 0x10, 0x10, //4
+		0xF0, 0x80, 0xF0, 0x10, 0xF0, //5
+		0xF0, 0x80, 0xF0, 0x90, 0xF0, //6
+		0xF0, 0x10, 0x20, 0x40, 0x40, //7
+		0xF0, 0x90, 0xF0, 0x90, 0xF0, //8
+		0xF0, 0x90, 0xF0, 0x10, 0xF0, //9
+		0xF0, 0x90, 0xF0, 0x90, 0x90, //A
+		0xE0, 0x90, 0xE0, 0x90, 0xE0, //B
+		0xF0, 0x80, 0x80, 0x80, 0xF0, //C
+		0xE0, 0x90, 0x90, 0x90, 0xE0, //D
+		0xF0, 0x80, 0xF0, 0x80, 0xF0, //E
+		0xF0, 0x80, 0xF0, 0x80, 0x80, //F
+	},
+	Big: [16 * 10]byte{
+		0x3C, 0x7E, 0xE7, 0xC3, 0xC3, 0xC3, 0xC3, 0xE7, 0x7E, 0x3C, //0
+		0x18, 0x38, 0x58, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x3C, //1
+		0x3E, 0x7F, 0xC3, 0x06, 0x0C, 0x18, 0x30, 0x60, 0xFF, 0xFF, //2
+		0x3C, 0x7E, 0xC3, 0x03, 0x0E, 0x0E, 0x03, 0xC3, 0x7E, 0x3C, //3
+		0x06, 0x0E, 0x1E, 0x36, 0x66, 0xC6, 0xFF, 0xFF, 0x06, 0x06, //4
+		0xFF, 0xFF, 0xC0, 0xC0, 0xFC, 0xFE, 0x03, 0xC3, 0x7E, 0x3C, //5
+		0x3E, 0x7C, 0xC0, 0xC0, 0xFC, 0xFE, 0xC3, 0xC3, 0x7E, 0x3C, //6
+		0xFF, 0xFF, 0x03, 0x06, 0x0C, 0x18, 0x30, 0x60, 0x60, 0x60, //7
+		0x3C, 0x7E, 0xC3, 0xC3, 0x7E, 0x7E, 0xC3, 0xC3, 0x7E, 0x3C, //8
+		0x3C, 0x7E, 0xC3, 0xC3, 0x7F, 0x3F, 0x03, 0x03, 0x3E, 0x7C, //9
+	},
+}
+
+// FontOcto is the font of the Octo interpreter, its big digits go from 0 through F.
+var FontOcto = Font{
+	Small: FontSCHIP.Small,
+	Big: [16 * 10]byte{
+		0xFF, 0xFF, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xFF, 0xFF, //0
+		0x18, 0x78, 0x78, 0x18, 0x18, 0x18, 0x18, 0x18, 0xFF, 0xFF, //1
+		0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, //2
+		0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, //3
+		0xC3, 0xC3, 0xC3, 0xC3, 0xFF, 0xFF, 0x03, 0x03, 0x03, 0x03, //4
+		0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, //5
+		0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, //6
+		0xFF, 0xFF, 0x03, 0x03, 0x06, 0x0C, 0x18, 0x18, 0x18, 0x18, //7
+		0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, //8
+		0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, //9
+		0x7E, 0xFF, 0xC3, 0xC3, 0xC3, 0xFF, 0xFF, 0xC3, 0xC3, 0xC3, //A
+		0xFC, 0xFC, 0xC3, 0xC3, 0xFC, 0xFC, 0xC3, 0xC3, 0xFC, 0xFC, //B
+		0x3C, 0xFF, 0xC3, 0xC0, 0xC0, 0xC0, 0xC0, 0xC3, 0xFF, 0x3C, //C
+		0xFC, 0xFE, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xFE, 0xFC, //D
+		0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, //E
+		0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC0, 0xC0, 0xC0, 0xC0, //F
+	},
+}
+
+var (
+	// PlatformVIP is the original CHIP-8 interpreter of the COSMAC VIP.
+	PlatformVIP = Platform{
+		Name:           "vip",
+		InstructionSet: CHIP8,
+		Quirks:         Quirks{ShiftVy: true, IncrementI: true, ResetVF: true, DisplayWait: true, ClipSprites: true},
+		MemorySize:     0x1000,
+		LoadAddress:    0x200,
+		CyclePerFrame:  15,
+		Font:           FontVIP,
+		DisplayWidth:   LowResWidth,
+		DisplayHeight:  LowResHeight,
+	}
+
+	// PlatformCHIP48 is the CHIP-48 interpreter of the HP48 calculators.
+	PlatformCHIP48 = Platform{
+		Name:           "chip48",
+		InstructionSet: CHIP8,
+		Quirks:         Quirks{JumpVx: true, ClipSprites: true},
+		MemorySize:     0x1000,
+		LoadAddress:    0x200,
+		CyclePerFrame:  30,
+		Font:           FontSCHIP,
+		DisplayWidth:   LowResWidth,
+		DisplayHeight:  LowResHeight,
+	}
+
+	// PlatformSCHIP10 is the SUPER-CHIP 1.0 interpreter of the HP48 calculators.
+	PlatformSCHIP10 = Platform{
+		Name:           "schip10",
+		InstructionSet: SCHIP10,
+		Quirks:         Quirks{JumpVx: true, ClipSprites: true},
+		MemorySize:     0x1000,
+		LoadAddress:    0x200,
+		CyclePerFrame:  30,
+		Font:           FontSCHIP,
+		DisplayWidth:   DisplayWidth,
+		DisplayHeight:  DisplayHeight,
+	}
+
+	// PlatformSCHIP11 is the SUPER-CHIP 1.1 interpreter of the HP48 calculators.
+	PlatformSCHIP11 = Platform{
+		Name:           "schip11",
+		InstructionSet: SCHIP11,
+		Quirks:         Quirks{JumpVx: true, ClipSprites: true},
+		MemorySize:     0x1000,
+		LoadAddress:    0x200,
+		CyclePerFrame:  30,
+		Font:           FontSCHIP,
+		DisplayWidth:   DisplayWidth,
+		DisplayHeight:  DisplayHeight,
+	}
+
+	// PlatformXOCHIP follows the XO-CHIP specification.
+	PlatformXOCHIP = Platform{
+		Name:           "xochip",
+		InstructionSet: XOCHIP,
+		Quirks:         Quirks{ShiftVy: true, IncrementI: true},
+		MemorySize:     MemorySize,
+		LoadAddress:    0x200,
+		CyclePerFrame:  1000,
+		Font:           FontOcto,
+		DisplayWidth:   DisplayWidth,
+		DisplayHeight:  DisplayHeight,
+	}
+
+	// PlatformOcto is the default configuration of the Octo interpreter.
+	PlatformOcto = Platform{
+		Name:           "octo",
+		InstructionSet: XOCHIP,
+		Quirks:         Quirks{},
+		MemorySize:     MemorySize,
+		LoadAddress:    0x200,
+		CyclePerFrame:  20,
+		Font:           FontOcto,
+		DisplayWidth:   DisplayWidth,
+		DisplayHeight:  DisplayHeight,
+	}
+)
+
+// Platforms lists the known platforms.
+var Platforms = []Platform{PlatformVIP, PlatformCHIP48, PlatformSCHIP10, PlatformSCHIP11, PlatformXOCHIP, PlatformOcto}
+
+// PlatformByName return the known platform with the given name.
+func PlatformByName(name string) (Platform, bool) {
+	for _, p := range Platforms {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Platform{}, false
+}
@@ -0,0 +1,153 @@
+package chip8
+
+import (
+	"compress/gzip"
+	"io"
+)
+
+// The profiles of pprof are protocol buffers described in
+// https://github.com/google/pprof/blob/master/proto/profile.proto, only the fields used are encoded.
+
+// protobuf encodes the fields of a protocol buffer message.
+type protobuf struct {
+	data []byte
+}
+
+func (b *protobuf) varint(x uint64) {
+	for x >= 0x80 {
+		b.data = append(b.data, byte(x)|0x80)
+		x >>= 7
+	}
+	b.data = append(b.data, byte(x))
+}
+
+func (b *protobuf) uint64(tag int, x uint64) {
+	b.varint(uint64(tag) << 3)
+	b.varint(x)
+}
+
+func (b *protobuf) int64(tag int, x int64) {
+	b.uint64(tag, uint64(x))
+}
+
+func (b *protobuf) bytes(tag int, data []byte) {
+	b.varint(uint64(tag)<<3 | 2)
+	b.varint(uint64(len(data)))
+	b.data = append(b.data, data...)
+}
+
+// packed encodes a repeated integer field.
+func (b *protobuf) packed(tag int, xs []uint64) {
+	var p protobuf
+	for _, x := range xs {
+		p.varint(x)
+	}
+	b.bytes(tag, p.data)
+}
+
+// pprof builds a profile with a single kind of value, the addresses being in a single mapping of the memory.
+type pprof struct {
+	profile   protobuf
+	strings   []string
+	stringIDs map[string]int64
+	functions map[string]uint64
+	locations map[pprofLocation]uint64
+}
+
+type pprofLocation struct {
+	addr     uint16
+	function string
+}
+
+func newPprof(kind, unit string) *pprof {
+	p := &pprof{
+		strings:   []string{""},
+		stringIDs: map[string]int64{"": 0},
+		functions: make(map[string]uint64),
+		locations: make(map[pprofLocation]uint64),
+	}
+
+	var valueType protobuf
+	valueType.int64(1, p.string(kind))
+	valueType.int64(2, p.string(unit))
+	p.profile.bytes(1, valueType.data)  // sample_type
+	p.profile.bytes(11, valueType.data) // period_type
+	p.profile.int64(12, 1)              // period
+
+	var mapping protobuf
+	mapping.uint64(1, 1)                 // id
+	mapping.uint64(3, 0x10000)           // memory_limit
+	mapping.int64(5, p.string("memory")) // filename
+	mapping.uint64(7, 1)                 // has_functions
+	p.profile.bytes(3, mapping.data)
+	return p
+}
+
+// string return the index of s in the string table.
+func (p *pprof) string(s string) int64 {
+	if id, ok := p.stringIDs[s]; ok {
+		return id
+	}
+	id := int64(len(p.strings))
+	p.strings = append(p.strings, s)
+	p.stringIDs[s] = id
+	return id
+}
+
+// function return the id of a function.
+func (p *pprof) function(name string) uint64 {
+	if id, ok := p.functions[name]; ok {
+		return id
+	}
+	id := uint64(len(p.functions) + 1)
+	p.functions[name] = id
+
+	var function protobuf
+	function.uint64(1, id)            // id
+	function.int64(2, p.string(name)) // name
+	function.int64(3, p.string(name)) // system_name
+	p.profile.bytes(5, function.data)
+	return id
+}
+
+// location return the id of the location of an address in a function.
+func (p *pprof) location(addr uint16, function string) uint64 {
+	key := pprofLocation{addr, function}
+	if id, ok := p.locations[key]; ok {
+		return id
+	}
+	id := uint64(len(p.locations) + 1)
+	p.locations[key] = id
+
+	var line protobuf
+	line.uint64(1, p.function(function)) // function_id
+	var location protobuf
+	location.uint64(1, id)           // id
+	location.uint64(2, 1)            // mapping_id
+	location.uint64(3, uint64(addr)) // address
+	location.bytes(4, line.data)     // line
+	p.profile.bytes(4, location.data)
+	return id
+}
+
+// sample adds a value for a call stack, given by its locations from the innermost.
+func (p *pprof) sample(locations []uint64, value int64) {
+	var sample protobuf
+	sample.packed(1, locations)               // location_id
+	sample.packed(2, []uint64{uint64(value)}) // value
+	p.profile.bytes(2, sample.data)
+}
+
+// write writes the gzipped profile.
+func (p *pprof) write(w io.Writer) error {
+	profile := p.profile
+	for _, s := range p.strings {
+		profile.bytes(6, []byte(s)) // string_table
+	}
+
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(profile.data); err != nil {
+		return err
+	}
+	return gz.Close()
+}
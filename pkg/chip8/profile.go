@@ -0,0 +1,180 @@
+package chip8
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Profiler counts the instructions executed by address, by opcode class and by call stack.
+//
+// The call stacks are read from the stack of the machine: each return address follows the call of a subroutine, which
+// gives the subroutine entered. The instructions executed before the first call belong to main.
+type Profiler struct {
+	addresses [0x10000]uint64
+	classes   map[string]uint64
+	samples   map[string]*profileSample
+	total     uint64
+	// A buffer to build the keys of the samples without allocating.
+	key []byte
+}
+
+// profileSample is the number of instructions executed with a call stack.
+type profileSample struct {
+	// The address of the instruction executed then of the calls, innermost first.
+	addrs []uint16
+	// The entries of the subroutines of the addresses, -1 for main.
+	entries []int
+	count   uint64
+}
+
+// NewProfiler return an empty profiler.
+func NewProfiler() *Profiler {
+	return &Profiler{classes: make(map[string]uint64), samples: make(map[string]*profileSample)}
+}
+
+// SetProfiler counts the instructions executed with p, or stops counting them if p is nil.
+func (c *Chip8) SetProfiler(p *Profiler) {
+	c.profiler = p
+}
+
+// Total return the number of instructions executed.
+func (p *Profiler) Total() uint64 {
+	return p.total
+}
+
+// Executions return the number of times the instruction at addr was executed.
+func (p *Profiler) Executions(addr uint16) uint64 {
+	return p.addresses[addr]
+}
+
+// Classes return the number of instructions executed by opcode class, like 8xy4 or Dxyn.
+func (p *Profiler) Classes() map[string]uint64 {
+	classes := make(map[string]uint64, len(p.classes))
+	for class, n := range p.classes {
+		classes[class] = n
+	}
+	return classes
+}
+
+// instruction counts the instruction at the program counter.
+func (p *Profiler) instruction(c *Chip8) {
+	if int(c.pc)+1 >= len(c.memory) {
+		return
+	}
+	op := uint16(c.memory[c.pc])<<8 | uint16(c.memory[c.pc+1])
+
+	p.total++
+	p.addresses[c.pc]++
+	p.classes[OpcodeClass(op)]++
+
+	p.key = append(p.key[:0], byte(c.pc>>8), byte(c.pc))
+	for i := int(c.sp) - 1; i >= 0; i-- {
+		p.key = append(p.key, byte(c.stack[i]>>8), byte(c.stack[i]))
+	}
+	if s, ok := p.samples[string(p.key)]; ok {
+		s.count++
+		return
+	}
+	p.samples[string(p.key)] = p.newSample(c)
+}
+
+// newSample return the sample of the current call stack, counting one instruction.
+func (p *Profiler) newSample(c *Chip8) *profileSample {
+	// The entry of each frame, from the outermost
+	entries := []int{-1}
+	for i := 0; i < int(c.sp); i++ {
+		entry := -1
+		if call := int(c.stack[i]) - 2; call >= 0 && call+1 < len(c.memory) && c.memory[call]&0xF0 == 0x20 {
+			entry = int(c.memory[call]&0x0F)<<8 | int(c.memory[call+1])
+		}
+		entries = append(entries, entry)
+	}
+
+	s := &profileSample{addrs: []uint16{c.pc}, entries: []int{entries[c.sp]}, count: 1}
+	for i := int(c.sp) - 1; i >= 0; i-- {
+		s.addrs = append(s.addrs, c.stack[i]-2)
+		s.entries = append(s.entries, entries[i])
+	}
+	return s
+}
+
+// OpcodeClass return the class of an opcode, the opcode with its operands replaced by their names as in 8xy4, Dxyn or
+// 00Cn, or 0nnn for the machine code routines.
+func OpcodeClass(op uint16) string {
+	switch op >> 12 {
+	case 0x0:
+		switch {
+		case op&0xFFF0 == 0x00C0:
+			return "00Cn"
+		case op&0xFFF0 == 0x00D0:
+			return "00Dn"
+		case op == 0x00E0, op == 0x00EE, op >= 0x00FB && op <= 0x00FF:
+			return fmt.Sprintf("%04X", op)
+		}
+		return "0nnn"
+	case 0x1, 0x2, 0xA, 0xB:
+		return fmt.Sprintf("%Xnnn", op>>12)
+	case 0x3, 0x4, 0x6, 0x7, 0xC:
+		return fmt.Sprintf("%Xxkk", op>>12)
+	case 0x5, 0x8, 0x9:
+		return fmt.Sprintf("%Xxy%X", op>>12, op&0xF)
+	case 0xD:
+		return "Dxyn"
+	case 0xE:
+		return fmt.Sprintf("Ex%02X", op&0xFF)
+	}
+	switch {
+	case op == 0xF000, op == 0xF002:
+		return fmt.Sprintf("%04X", op)
+	case op&0xFF == 0x01:
+		return "Fn01"
+	}
+	return fmt.Sprintf("Fx%02X", op&0xFF)
+}
+
+// Symbolizer names the subroutines in the profiles, the symbol tables of the symbols package implement it.
+type Symbolizer interface {
+	LabelOf(addr uint16) (string, bool)
+}
+
+// WriteProfile writes the call stacks in the gzipped protocol buffer format of pprof, so that they can be explored with
+// go tool pprof. The subroutines are named after their label when sym is not nil and has one.
+func (p *Profiler) WriteProfile(w io.Writer, sym Symbolizer) error {
+	name := func(entry int) string {
+		if entry < 0 {
+			return "main"
+		}
+		if sym != nil {
+			if label, ok := sym.LabelOf(uint16(entry)); ok {
+				return label
+			}
+		}
+		return fmt.Sprintf("sub_%03X", entry)
+	}
+
+	samples := make([]*profileSample, 0, len(p.samples))
+	for _, s := range p.samples {
+		samples = append(samples, s)
+	}
+	sort.Slice(samples, func(i, j int) bool { return lessAddresses(samples[i].addrs, samples[j].addrs) })
+
+	prof := newPprof("instructions", "count")
+	for _, s := range samples {
+		var locations []uint64
+		for i, addr := range s.addrs {
+			locations = append(locations, prof.location(addr, name(s.entries[i])))
+		}
+		prof.sample(locations, int64(s.count))
+	}
+	return prof.write(w)
+}
+
+func lessAddresses(a, b []uint16) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
+}
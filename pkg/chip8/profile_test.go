@@ -0,0 +1,99 @@
+package chip8
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+// The program calls a subroutine, which calls another, forever.
+var profiledProgram = []byte{
+	0x22, 0x06, // 0x200 main: call 0x206
+	0x12, 0x00, // 0x202 jump main
+	0x00, 0x00, // 0x204
+	0x60, 0x01, // 0x206 draw: v0 := 1
+	0x22, 0x0C, // 0x208 call 0x20C
+	0x00, 0xEE, // 0x20A return
+	0x70, 0x01, // 0x20C v0 += 1
+	0x00, 0xEE, // 0x20E return
+}
+
+type labels map[uint16]string
+
+func (l labels) LabelOf(addr uint16) (string, bool) {
+	name, ok := l[addr]
+	return name, ok
+}
+
+func TestProfiler(t *testing.T) {
+	c := New(PlatformOcto)
+	c.LoadGame(profiledProgram)
+	p := NewProfiler()
+	c.SetProfiler(p)
+
+	// Two rounds of the 7 instructions of the loop
+	if _, err := c.RunCycles(14); err != nil {
+		t.Fatalf("chip8.RunCycles() error = %v", err)
+	}
+
+	if got := p.Total(); got != 14 {
+		t.Errorf("Profiler.Total() = %v, want 14", got)
+	}
+	if got := p.Executions(0x20C); got != 2 {
+		t.Errorf("Profiler.Executions(0x20C) = %v, want 2", got)
+	}
+	wantClasses := map[string]uint64{"2nnn": 4, "1nnn": 2, "6xkk": 2, "7xkk": 2, "00EE": 4}
+	if got := p.Classes(); !reflect.DeepEqual(got, wantClasses) {
+		t.Errorf("Profiler.Classes() = %v, want %v", got, wantClasses)
+	}
+
+	// The innermost subroutine is called from draw, called from main
+	s := p.samples[string([]byte{0x02, 0x0C, 0x02, 0x0A, 0x02, 0x02})]
+	if s == nil || !reflect.DeepEqual(s.addrs, []uint16{0x20C, 0x208, 0x200}) || !reflect.DeepEqual(s.entries, []int{0x20C, 0x206, -1}) {
+		t.Errorf("sample of 0x20C = %+v, want the stack 0x20C in 0x20C, 0x208 in 0x206, 0x200 in main", s)
+	}
+
+	var buf bytes.Buffer
+	if err := p.WriteProfile(&buf, labels{0x206: "draw"}); err != nil {
+		t.Fatalf("Profiler.WriteProfile() error = %v", err)
+	}
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("Profiler.WriteProfile() is not gzipped: %v", err)
+	}
+	data, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"main", "draw", "sub_20C", "instructions"} {
+		if !bytes.Contains(data, []byte(name)) {
+			t.Errorf("Profiler.WriteProfile() does not name %v", name)
+		}
+	}
+}
+
+func TestOpcodeClass(t *testing.T) {
+	tests := []struct {
+		op   uint16
+		want string
+	}{
+		{0x00E0, "00E0"},
+		{0x00C4, "00Cn"},
+		{0x0123, "0nnn"},
+		{0x2ABC, "2nnn"},
+		{0x7A01, "7xkk"},
+		{0x8AB4, "8xy4"},
+		{0xD125, "Dxyn"},
+		{0xE19E, "Ex9E"},
+		{0xF000, "F000"},
+		{0xF201, "Fn01"},
+		{0xF31E, "Fx1E"},
+	}
+	for _, tt := range tests {
+		if got := OpcodeClass(tt.op); got != tt.want {
+			t.Errorf("OpcodeClass(0x%04X) = %v, want %v", tt.op, got, tt.want)
+		}
+	}
+}
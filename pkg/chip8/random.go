@@ -0,0 +1,82 @@
+package chip8
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// RandomSource generates the random numbers of the Cxkk instruction.
+// If it also implements encoding.BinaryMarshaler and encoding.BinaryUnmarshaler its state is saved along with the machine.
+type RandomSource interface {
+	// Byte return the next random byte.
+	Byte() byte
+}
+
+// randSource is a splitmix64 pseudo-random number generator.
+// Its whole state is a single word so it can be saved along with the machine.
+type randSource struct {
+	state uint64
+}
+
+func newRandSource(seed int64) *randSource {
+	return &randSource{state: uint64(seed)}
+}
+
+// Byte return the next random byte.
+func (r *randSource) Byte() byte {
+	r.state += 0x9E3779B97F4A7C15
+	z := r.state
+	z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+	z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+	return byte((z ^ (z >> 31)) >> 56)
+}
+
+// MarshalBinary return the state of the generator.
+func (r *randSource) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 8)
+	binary.LittleEndian.PutUint64(data, r.state)
+	return data, nil
+}
+
+// UnmarshalBinary restore a state returned by MarshalBinary.
+func (r *randSource) UnmarshalBinary(data []byte) error {
+	if len(data) != 8 {
+		return errors.New("invalid random generator state")
+	}
+	r.state = binary.LittleEndian.Uint64(data)
+	return nil
+}
+
+// vipRandom emulates the random number generator of the COSMAC VIP interpreter.
+// Instead of computing a pseudo-random sequence the VIP walks through a page of code,
+// adding each byte to the previous random number then adding the sum to itself shifted right with the carry.
+// The VIP reads its own interpreter in page 0x100, this interpreter has no code in memory
+// so the first page of the program plays the same role: code is rarely a run of zeros, the fonts were.
+type vipRandom struct {
+	memory  []byte
+	pointer byte
+	value   byte
+}
+
+// Byte return the next random byte.
+func (r *vipRandom) Byte() byte {
+	r.pointer++
+	sum := uint16(r.value) + uint16(r.memory[0x200+int(r.pointer)])
+	low := byte(sum)
+	r.value = (low>>1 | byte(sum>>8)<<7) + low
+	return r.value
+}
+
+// MarshalBinary return the state of the generator.
+func (r *vipRandom) MarshalBinary() ([]byte, error) {
+	return []byte{r.pointer, r.value}, nil
+}
+
+// UnmarshalBinary restore a state returned by MarshalBinary.
+func (r *vipRandom) UnmarshalBinary(data []byte) error {
+	if len(data) != 2 {
+		return errors.New("invalid random generator state")
+	}
+	r.pointer, r.value = data[0], data[1]
+	return nil
+}
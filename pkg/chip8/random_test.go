@@ -0,0 +1,111 @@
+package chip8
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// constantSource is a random source without state always returning the same number.
+type constantSource byte
+
+func (s constantSource) Byte() byte { return byte(s) }
+
+func TestNew_random(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want []byte
+	}{
+		{"seed", []Option{WithSeed(0)}, []byte{0xE2, 0x6E, 0x06, 0xF8}},
+		{"source", []Option{WithRandomSource(constantSource(4))}, []byte{4, 4, 4, 4}},
+		{"vip", []Option{WithVIPRandom()}, []byte{0x50, 0x98, 0x62, 0xAE}},
+		{"last option wins", []Option{WithVIPRandom(), WithSeed(0)}, []byte{0xE2, 0x6E, 0x06, 0xF8}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(PlatformVIP, tt.opts...)
+			if err := c.LoadGame([]byte{0x00, 0xE0, 0xC0, 0xFF, 0x12, 0x02}); err != nil {
+				t.Fatal(err)
+			}
+			var got []byte
+			for range tt.want {
+				got = append(got, c.rand.Byte())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("random numbers = % X, want % X", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVipRandom_Byte(t *testing.T) {
+	// The generator must not settle on a value, as it did when reading the zeros after the fonts
+	rom, err := ioutil.ReadFile(filepath.Join("testdata", "conformance", "3-corax+.ch8"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := New(PlatformVIP, WithVIPRandom())
+	if err := c.LoadGame(rom); err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[byte]bool)
+	var last byte
+	run, longest := 0, 0
+	for i := 0; i < 4096; i++ {
+		b := c.rand.Byte()
+		seen[b] = true
+		if b == last {
+			run++
+		} else {
+			run = 1
+		}
+		if run > longest {
+			longest = run
+		}
+		last = b
+	}
+	if len(seen) < 128 || longest > 3 {
+		t.Errorf("vipRandom.Byte() gave %d different numbers and repeated one %d times, want at least 128 and at most 3", len(seen), longest)
+	}
+}
+
+func TestChip8_MarshalBinary_random(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"seed", []Option{WithSeed(42)}},
+		{"source", []Option{WithRandomSource(constantSource(4))}},
+		{"vip", []Option{WithVIPRandom()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(PlatformVIP, tt.opts...)
+			c.rand.Byte()
+			state, err := c.MarshalBinary()
+			if err != nil {
+				t.Fatalf("chip8.MarshalBinary() error = %v", err)
+			}
+
+			want := []byte{c.rand.Byte(), c.rand.Byte()}
+			if err := c.UnmarshalBinary(state); err != nil {
+				t.Fatalf("chip8.UnmarshalBinary() error = %v", err)
+			}
+			if got := []byte{c.rand.Byte(), c.rand.Byte()}; !reflect.DeepEqual(got, want) {
+				t.Errorf("random numbers after restoring = % X, want % X", got, want)
+			}
+		})
+	}
+
+	state, err := New(PlatformVIP, WithVIPRandom()).MarshalBinary()
+	if err != nil {
+		t.Fatalf("chip8.MarshalBinary() error = %v", err)
+	}
+	for _, opt := range []Option{WithSeed(0), WithRandomSource(constantSource(4))} {
+		if err := New(PlatformVIP, opt).UnmarshalBinary(state); err == nil {
+			t.Errorf("chip8.UnmarshalBinary() of a state from another random source error = nil, want an error")
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package chip8
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Rewinder records the state of a machine before each frame so the gameplay can be played backward.
+// Only the most recent state is kept whole, the older ones are stored as compressed differences in a ring buffer.
+type Rewinder struct {
+	c *Chip8
+
+	// The state of the machine before its last frame.
+	last []byte
+
+	// Ring buffer of the differences between consecutive states, the newest one being before head.
+	// Applying a difference to a state gives the state of the previous frame.
+	deltas [][]byte
+	head   int
+	count  int
+}
+
+// NewRewinder return a Rewinder able to go back up to the given number of frames of the machine.
+// It panics if the number of frames is not positive.
+func NewRewinder(c *Chip8, frames int) *Rewinder {
+	if frames <= 0 {
+		panic(fmt.Sprintf("chip8: NewRewinder with %d frames, want at least 1", frames))
+	}
+	return &Rewinder{
+		c:      c,
+		deltas: make([][]byte, frames),
+	}
+}
+
+// GetNextFrame records the state of the machine then runs it for one frame, like Chip8.GetNextFrame.
+func (r *Rewinder) GetNextFrame(inputs [16]bool) ([]uint32, []int16, error) {
+	state, err := r.c.MarshalBinary()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if r.last != nil {
+		delta, err := diff(state, r.last)
+		if err != nil {
+			return nil, nil, err
+		}
+		r.push(delta)
+	}
+	r.last = state
+
+	return r.c.GetNextFrame(inputs)
+}
+
+// Rewind restores the machine to its state one frame earlier and return its video data.
+// It return false when there is no recorded state left.
+func (r *Rewinder) Rewind() ([]uint32, bool, error) {
+	if r.last == nil {
+		return nil, false, nil
+	}
+
+	if err := r.c.UnmarshalBinary(r.last); err != nil {
+		return nil, false, err
+	}
+
+	state := r.last
+	r.last = nil
+	if delta, ok := r.pop(); ok {
+		if err := patch(state, delta); err != nil {
+			return nil, false, err
+		}
+		r.last = state
+	}
+
+	return r.c.Framebuffer(), true, nil
+}
+
+// Frames return the number of frames the machine can be rewound.
+func (r *Rewinder) Frames() int {
+	if r.last == nil {
+		return 0
+	}
+	return r.count + 1
+}
+
+func (r *Rewinder) push(delta []byte) {
+	r.deltas[r.head] = delta
+	r.head = (r.head + 1) % len(r.deltas)
+	if r.count < len(r.deltas) {
+		r.count++
+	}
+}
+
+func (r *Rewinder) pop() ([]byte, bool) {
+	if r.count == 0 {
+		return nil, false
+	}
+
+	r.head = (r.head + len(r.deltas) - 1) % len(r.deltas)
+	delta := r.deltas[r.head]
+	r.deltas[r.head] = nil
+	r.count--
+	return delta, true
+}
+
+// diff encodes the XOR of two states of the same size, as a sequence of runs made of
+// the number of identical bytes, the number of different bytes and the XOR of these different bytes.
+func diff(a, b []byte) ([]byte, error) {
+	if len(a) != len(b) {
+		return nil, errors.New("the size of the state changed")
+	}
+
+	var delta []byte
+	var buf [binary.MaxVarintLen64]byte
+	for i := 0; i < len(a); {
+		same := i
+		for i < len(a) && a[i] == b[i] {
+			i++
+		}
+
+		different := i
+		for i < len(a) && a[i] != b[i] {
+			i++
+		}
+
+		delta = append(delta, buf[:binary.PutUvarint(buf[:], uint64(different-same))]...)
+		delta = append(delta, buf[:binary.PutUvarint(buf[:], uint64(i-different))]...)
+		for k := different; k < i; k++ {
+			delta = append(delta, a[k]^b[k])
+		}
+	}
+
+	return delta, nil
+}
+
+// patch applies in place a delta returned by diff to one of the two states, giving the other one.
+func patch(state, delta []byte) error {
+	var i int
+	for len(delta) > 0 {
+		same, n := binary.Uvarint(delta)
+		if n <= 0 {
+			return errors.New("corrupted state delta")
+		}
+		delta = delta[n:]
+
+		different, n := binary.Uvarint(delta)
+		if n <= 0 || uint64(len(delta)-n) < different || uint64(len(state)-i) < same+different {
+			return errors.New("corrupted state delta")
+		}
+		delta = delta[n:]
+
+		i += int(same)
+		for k := 0; k < int(different); k++ {
+			state[i+k] ^= delta[k]
+		}
+		i += int(different)
+		delta = delta[different:]
+	}
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package chip8
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestRewinder_Rewind(t *testing.T) {
+	c := New(PlatformOcto)
+	if err := c.LoadGame(randomSprites); err != nil {
+		t.Fatalf("chip8.LoadGame() error = %v", err)
+	}
+
+	r := NewRewinder(c, 8)
+	var states [][]byte
+	for i := 0; i < 12; i++ {
+		state, err := c.MarshalBinary()
+		if err != nil {
+			t.Fatalf("chip8.MarshalBinary() error = %v", err)
+		}
+		states = append(states, state)
+
+		if _, _, err := r.GetNextFrame(pressed(byte(i % 16))); err != nil {
+			t.Fatalf("Rewinder.GetNextFrame() error = %v", err)
+		}
+	}
+
+	if got := r.Frames(); got != 9 {
+		t.Errorf("Rewinder.Frames() = %v, want %v", got, 9)
+	}
+
+	// Only the 8 differences and the last state are kept, the oldest states are lost.
+	for i := len(states) - 1; i >= len(states)-9; i-- {
+		fb, ok, err := r.Rewind()
+		if err != nil || !ok {
+			t.Fatalf("Rewinder.Rewind() = %v, %v, want true, nil", ok, err)
+		}
+
+		state, err := c.MarshalBinary()
+		if err != nil {
+			t.Fatalf("chip8.MarshalBinary() error = %v", err)
+		}
+		if !bytes.Equal(state, states[i]) {
+			t.Errorf("Rewinder.Rewind() restored a different state than before frame %d", i)
+		}
+		if !reflect.DeepEqual(fb, c.Framebuffer()) {
+			t.Errorf("Rewinder.Rewind() returned a different frame than the restored state")
+		}
+	}
+
+	if _, ok, err := r.Rewind(); ok || err != nil {
+		t.Errorf("Rewinder.Rewind() = %v, %v, want false, nil", ok, err)
+	}
+
+	// The gameplay can go on after rewinding and be rewound again.
+	if _, _, err := r.GetNextFrame([16]bool{}); err != nil {
+		t.Fatalf("Rewinder.GetNextFrame() error = %v", err)
+	}
+	if _, ok, err := r.Rewind(); !ok || err != nil {
+		t.Errorf("Rewinder.Rewind() = %v, %v, want true, nil", ok, err)
+	}
+	state, err := c.MarshalBinary()
+	if err != nil {
+		t.Fatalf("chip8.MarshalBinary() error = %v", err)
+	}
+	if !bytes.Equal(state, states[len(states)-9]) {
+		t.Errorf("Rewinder.Rewind() restored a different state than before the new frame")
+	}
+}
+
+func Test_diff(t *testing.T) {
+	tests := []struct {
+		name    string
+		a       []byte
+		b       []byte
+		wantErr bool
+	}{
+		{"identical", []byte{1, 2, 3, 4}, []byte{1, 2, 3, 4}, false},
+		{"all different", []byte{1, 2, 3, 4}, []byte{4, 3, 2, 1}, false},
+		{"runs", []byte{1, 2, 3, 4, 5, 6, 7, 8}, []byte{1, 0, 0, 4, 5, 6, 0, 8}, false},
+		{"empty", []byte{}, []byte{}, false},
+		{"size mismatch", []byte{1, 2, 3}, []byte{1, 2}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delta, err := diff(tt.a, tt.b)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("diff() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			got := append([]byte(nil), tt.b...)
+			if err := patch(got, delta); err != nil {
+				t.Fatalf("patch() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.a) {
+				t.Errorf("patch(b, diff(a, b)) = %v, want %v", got, tt.a)
+			}
+
+			got = append([]byte(nil), tt.a...)
+			if err := patch(got, delta); err != nil {
+				t.Fatalf("patch() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.b) {
+				t.Errorf("patch(a, diff(a, b)) = %v, want %v", got, tt.b)
+			}
+		})
+	}
+}
+
+func Test_patch(t *testing.T) {
+	tests := []struct {
+		name  string
+		delta []byte
+	}{
+		{"truncated run", []byte{0x80}},
+		{"missing bytes", []byte{0, 4, 1, 2}},
+		{"out of bounds", []byte{8, 1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := patch(make([]byte, 4), tt.delta); err == nil {
+				t.Errorf("patch() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestNewRewinder_noFrames(t *testing.T) {
+	for _, frames := range []int{0, -1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("NewRewinder(c, %d) did not panic", frames)
+				}
+			}()
+			NewRewinder(New(PlatformOcto), frames)
+		}()
+	}
+}
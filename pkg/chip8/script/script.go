@@ -0,0 +1,212 @@
+// Package script runs CHIP-8 programs without a display, with the keypad driven by a script.
+//
+// A script lists events by frame, the first frame being 1, one per line:
+//
+//	# Start the game then move left
+//	1   press 5
+//	10  release 5
+//	60  press 4 7
+//	90  release 4 7
+//	120 screenshot left.png
+//
+// The keys are hexadecimal digits, pressed from the start of the frame of the event until released.
+// A screenshot saves the display at the end of its frame in a PNG file, relative to the script file.
+// Everything after a # is a comment.
+package script
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Bit-Doctor/emulation/pkg/chip8"
+)
+
+// Action is what an event does.
+type Action int
+
+// The actions of the events.
+const (
+	Press Action = iota
+	Release
+	Screenshot
+)
+
+var actionNames = map[string]Action{
+	"press":      Press,
+	"release":    Release,
+	"screenshot": Screenshot,
+}
+
+// Event is a line of a script.
+type Event struct {
+	Frame  int
+	Action Action
+	// Keys are the keys pressed or released.
+	Keys []byte
+	// Path is the file of a screenshot.
+	Path string
+	// Line is the line of the event in the script.
+	Line int
+}
+
+// Script is a list of events, by frame.
+type Script struct {
+	Events []Event
+}
+
+// Parse reads a script.
+func Parse(r io.Reader) (*Script, error) {
+	s := &Script{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.IndexByte(text, '#'); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+
+		e, err := parseEvent(fields)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		e.Line = line
+		s.Events = append(s.Events, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(s.Events, func(i, j int) bool { return s.Events[i].Frame < s.Events[j].Frame })
+	return s, nil
+}
+
+// parseEvent return the event of the fields of a line.
+func parseEvent(fields []string) (Event, error) {
+	if len(fields) < 2 {
+		return Event{}, fmt.Errorf("missing action")
+	}
+
+	frame, err := strconv.Atoi(fields[0])
+	if err != nil || frame < 1 {
+		return Event{}, fmt.Errorf("invalid frame: %v", fields[0])
+	}
+	action, ok := actionNames[fields[1]]
+	if !ok {
+		return Event{}, fmt.Errorf("unknown action: %v", fields[1])
+	}
+
+	e := Event{Frame: frame, Action: action}
+	args := fields[2:]
+	switch action {
+	case Press, Release:
+		if len(args) == 0 {
+			return Event{}, fmt.Errorf("missing keys")
+		}
+		for _, arg := range args {
+			key, err := strconv.ParseUint(arg, 16, 4)
+			if err != nil {
+				return Event{}, fmt.Errorf("invalid key: %v", arg)
+			}
+			e.Keys = append(e.Keys, byte(key))
+		}
+	case Screenshot:
+		if len(args) != 1 {
+			return Event{}, fmt.Errorf("expected a file for the screenshot")
+		}
+		e.Path = args[0]
+	}
+	return e, nil
+}
+
+// Load reads a script from a file, the relative paths of the screenshots are resolved against the directory of the
+// script file.
+func Load(path string) (*Script, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s, err := Parse(f)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Dir(path)
+	for i, e := range s.Events {
+		if e.Action == Screenshot && !filepath.IsAbs(e.Path) {
+			s.Events[i].Path = filepath.Join(dir, e.Path)
+		}
+	}
+	return s, nil
+}
+
+// Frames return the last frame with an event, 0 for an empty script.
+func (s *Script) Frames() int {
+	if len(s.Events) == 0 {
+		return 0
+	}
+	return s.Events[len(s.Events)-1].Frame
+}
+
+// Machine runs the frames of a script, like *chip8.Chip8 or the *chip8.Recorder of a machine.
+type Machine interface {
+	GetNextFrame(inputs [16]bool) ([]uint32, []int16, error)
+}
+
+// Run runs the machine for a number of frames with the keys of the script, and calls screenshot with the display at
+// the end of the frames having a screenshot event. It stops at the first error of the machine or of screenshot.
+func (s *Script) Run(c *chip8.Chip8, frames int, screenshot func(e Event, img image.Image) error) error {
+	return s.RunFrames(c, c, frames, screenshot)
+}
+
+// RunFrames is like Run with the frames of c run by m.
+func (s *Script) RunFrames(c *chip8.Chip8, m Machine, frames int, screenshot func(e Event, img image.Image) error) error {
+	var keys [16]bool
+	events := s.Events
+	for frame := 1; frame <= frames; frame++ {
+		var shots []Event
+		for ; len(events) > 0 && events[0].Frame == frame; events = events[1:] {
+			e := events[0]
+			switch e.Action {
+			case Press, Release:
+				for _, key := range e.Keys {
+					keys[key] = e.Action == Press
+				}
+			case Screenshot:
+				shots = append(shots, e)
+			}
+		}
+
+		if _, _, err := m.GetNextFrame(keys); err != nil {
+			return fmt.Errorf("frame %d: %w", frame, err)
+		}
+		for _, e := range shots {
+			if err := screenshot(e, Image(c)); err != nil {
+				return fmt.Errorf("line %d: cannot save the screenshot: %w", e.Line, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Image return the display of the machine as it is now, one pixel per pixel of the current display mode.
+func Image(c *chip8.Chip8) *image.RGBA {
+	width, height := c.Resolution()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i, pixel := range c.Framebuffer() {
+		img.Set(i%width, i/width, color.RGBA{R: byte(pixel >> 16), G: byte(pixel >> 8), B: byte(pixel), A: 0xFF})
+	}
+	return img
+}
@@ -0,0 +1,105 @@
+package script
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Bit-Doctor/emulation/pkg/chip8"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		want    []Event
+		wantErr bool
+	}{
+		{"empty", "# nothing\n\n", nil, false},
+		{"events", "10 release 5 # up\n1 press 5 A\n10 screenshot up.png\n", []Event{
+			{Frame: 1, Action: Press, Keys: []byte{0x5, 0xA}, Line: 2},
+			{Frame: 10, Action: Release, Keys: []byte{0x5}, Line: 1},
+			{Frame: 10, Action: Screenshot, Path: "up.png", Line: 3},
+		}, false},
+		{"frame zero", "0 press 5", nil, true},
+		{"unknown action", "1 hold 5", nil, true},
+		{"missing keys", "1 press", nil, true},
+		{"invalid key", "1 press 10", nil, true},
+		{"missing file", "1 screenshot", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(tt.script))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got.Events, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got.Events, tt.want)
+			}
+		})
+	}
+}
+
+// The program waits for the key 5 then draws the digit 5.
+var program = []byte{
+	0x60, 0x05, // 0x200 v0 := 5
+	0xE0, 0x9E, // 0x202 if v0 -key then
+	0x12, 0x02, // 0x204 jump 0x202
+	0xF0, 0x29, // 0x206 i := hex v0
+	0xD1, 0x15, // 0x208 sprite v1 v1 5
+	0x12, 0x0A, // 0x20A jump 0x20A
+}
+
+func TestScript_Run(t *testing.T) {
+	s, err := Parse(strings.NewReader("3 press 5\n2 screenshot before.png\n4 screenshot after.png\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Frames(); got != 4 {
+		t.Errorf("Script.Frames() = %v, want 4", got)
+	}
+
+	c := chip8.New(chip8.PlatformOcto)
+	if err := c.LoadGame(program); err != nil {
+		t.Fatal(err)
+	}
+	shots := make(map[string]image.Image)
+	err = s.Run(c, s.Frames(), func(e Event, img image.Image) error {
+		shots[e.Path] = img
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Script.Run() error = %v", err)
+	}
+
+	off := color.RGBA{R: 0x00, G: 0x17, B: 0x1F, A: 0xFF}
+	on := color.RGBA{R: 0xF2, G: 0xF4, B: 0xF3, A: 0xFF}
+	for _, tt := range []struct {
+		path string
+		want color.Color
+	}{{"before.png", off}, {"after.png", on}} {
+		img, ok := shots[tt.path]
+		if !ok {
+			t.Errorf("Script.Run() did not take %v", tt.path)
+			continue
+		}
+		if got := img.Bounds(); got != image.Rect(0, 0, chip8.LowResWidth, chip8.LowResHeight) {
+			t.Errorf("%v bounds = %v, want the low resolution", tt.path, got)
+		}
+		if got := img.At(0, 0); got != tt.want {
+			t.Errorf("%v pixel (0, 0) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestScript_Run_error(t *testing.T) {
+	c := chip8.New(chip8.PlatformOcto)
+	if err := c.LoadGame([]byte{0x00, 0xEE}); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&Script{}).Run(c, 1, nil); err == nil {
+		t.Errorf("Script.Run() error = nil, want the stack underflow")
+	}
+}
@@ -0,0 +1,185 @@
+package chip8
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+const (
+	// stateMagic identifies a save state.
+	stateMagic = "CH8S"
+	// stateVersion is incremented each time the layout of the save states changes.
+	stateVersion = 2
+)
+
+// Save State Layout, all values are little-endian:
+// +------------------+
+// | magic "CH8S"     | 4 bytes
+// | version          | uint16
+// | memory size      | uint32
+// | memory           | memory size bytes
+// | machineState     | fixed size
+// | random state size| uint16
+// | random state     | random state size bytes
+// +------------------+
+
+// machineState is the fixed size part of a save state.
+type machineState struct {
+	V      [16]byte
+	I      uint16
+	DT     byte
+	ST     byte
+	PC     uint16
+	SP     byte
+	Stack  [16]uint16
+	Keypad [16]bool
+	// The key pressed while Fx0A waits for its release.
+	KeyPressed bool
+	PressedKey byte
+	Display    [2][DisplayHeight][DisplayWidth / 64]uint64
+	Planes     byte
+	HiRes      bool
+	Halted     bool
+	VBlank     bool
+	RPL        [16]byte
+	Pattern    [16]byte
+	Pitch      byte
+	Phase      float64
+}
+
+// MarshalBinary return a snapshot of the whole state of the machine.
+func (c *Chip8) MarshalBinary() ([]byte, error) {
+	// The state of a random source which cannot be saved is left out.
+	var rand []byte
+	if m, ok := c.rand.(encoding.BinaryMarshaler); ok {
+		var err error
+		if rand, err = m.MarshalBinary(); err != nil {
+			return nil, err
+		}
+	}
+
+	s := machineState{
+		V:          c.v,
+		I:          c.i,
+		DT:         c.dt,
+		ST:         c.st,
+		PC:         c.pc,
+		SP:         c.sp,
+		Stack:      c.stack,
+		Keypad:     c.keypad,
+		KeyPressed: c.keyPressed,
+		PressedKey: c.pressedKey,
+		Display:    c.display,
+		Planes:     c.planes,
+		HiRes:      c.hires,
+		Halted:     c.halted,
+		VBlank:     c.vblank,
+		RPL:        c.rpl,
+		Pattern:    c.pattern,
+		Pitch:      c.pitch,
+		Phase:      c.phase,
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(stateMagic)
+	for _, data := range []interface{}{uint16(stateVersion), uint32(len(c.memory)), c.memory, &s, uint16(len(rand)), rand} {
+		if err := binary.Write(&buf, binary.LittleEndian, data); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary restore a snapshot returned by MarshalBinary.
+// The snapshot must come from a machine emulating a platform with the same memory size.
+// If the snapshot is invalid the machine is left untouched.
+func (c *Chip8) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+
+	magic := make([]byte, len(stateMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != stateMagic {
+		return errors.New("not a save state")
+	}
+
+	var version uint16
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return err
+	}
+	if version != stateVersion {
+		return fmt.Errorf("save state version not supported: %d", version)
+	}
+
+	var size uint32
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return err
+	}
+	if int(size) != len(c.memory) {
+		return fmt.Errorf("save state memory size is %d bytes, expected %d", size, len(c.memory))
+	}
+
+	memory := make([]byte, size)
+	var s machineState
+	var randSize uint16
+	for _, data := range []interface{}{memory, &s, &randSize} {
+		if err := binary.Read(r, binary.LittleEndian, data); err != nil {
+			return err
+		}
+	}
+
+	rand := make([]byte, randSize)
+	if _, err := io.ReadFull(r, rand); err != nil {
+		return err
+	}
+
+	if r.Len() != 0 {
+		return errors.New("unexpected data at the end of the save state")
+	}
+	if int(s.SP) > len(c.stack) {
+		return fmt.Errorf("save state stack pointer out of range: %d", s.SP)
+	}
+	if int(s.PressedKey) >= len(c.keypad) {
+		return fmt.Errorf("save state pressed key out of range: %d", s.PressedKey)
+	}
+	if math.IsNaN(s.Phase) || s.Phase < 0 || s.Phase >= float64(len(c.pattern)*8) {
+		return fmt.Errorf("save state audio phase out of range: %v", s.Phase)
+	}
+	if len(rand) != 0 {
+		u, ok := c.rand.(encoding.BinaryUnmarshaler)
+		if !ok {
+			return errors.New("the random source cannot restore the save state")
+		}
+		if err := u.UnmarshalBinary(rand); err != nil {
+			return err
+		}
+	}
+
+	copy(c.memory, memory)
+	c.v = s.V
+	c.i = s.I
+	c.dt = s.DT
+	c.st = s.ST
+	c.pc = s.PC
+	c.sp = s.SP
+	c.stack = s.Stack
+	c.keypad = s.Keypad
+	c.keyPressed = s.KeyPressed
+	c.pressedKey = s.PressedKey
+	c.display = s.Display
+	c.planes = s.Planes
+	c.hires = s.HiRes
+	c.halted = s.Halted
+	c.vblank = s.VBlank
+	c.rpl = s.RPL
+	c.pattern = s.Pattern
+	c.pitch = s.Pitch
+	c.phase = s.Phase
+	// A snapshot starts a new frame
+	c.frameLeft = 0
+	return nil
+}
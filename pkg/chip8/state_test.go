@@ -0,0 +1,123 @@
+package chip8
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// randomSprites draws the hexadecimal digits at random positions forever.
+var randomSprites = []byte{
+	0xC0, 0xFF, // v0 := random 0xFF
+	0xC1, 0x3F, // v1 := random 0x3F
+	0xC2, 0x1F, // v2 := random 0x1F
+	0xF0, 0x29, // i := hex v0
+	0xD1, 0x25, // sprite v1 v2 5
+	0x12, 0x00, // jump 0x200
+}
+
+func TestChip8_MarshalBinary(t *testing.T) {
+	c := New(PlatformOcto)
+	if err := c.LoadGame(randomSprites); err != nil {
+		t.Fatalf("chip8.LoadGame() error = %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		if _, _, err := c.GetNextFrame([16]bool{}); err != nil {
+			t.Fatalf("chip8.GetNextFrame() error = %v", err)
+		}
+	}
+
+	state, err := c.MarshalBinary()
+	if err != nil {
+		t.Fatalf("chip8.MarshalBinary() error = %v", err)
+	}
+
+	var frames [][]uint32
+	for i := 0; i < 5; i++ {
+		fb, _, err := c.GetNextFrame(pressed(byte(i)))
+		if err != nil {
+			t.Fatalf("chip8.GetNextFrame() error = %v", err)
+		}
+		frames = append(frames, fb)
+	}
+
+	if err := c.UnmarshalBinary(state); err != nil {
+		t.Fatalf("chip8.UnmarshalBinary() error = %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		fb, _, err := c.GetNextFrame(pressed(byte(i)))
+		if err != nil {
+			t.Fatalf("chip8.GetNextFrame() error = %v", err)
+		}
+		if !reflect.DeepEqual(fb, frames[i]) {
+			t.Errorf("frame %d differs after restoring the save state", i)
+		}
+	}
+}
+
+func TestChip8_UnmarshalBinary(t *testing.T) {
+	octo, err := New(PlatformOcto).MarshalBinary()
+	if err != nil {
+		t.Fatalf("chip8.MarshalBinary() error = %v", err)
+	}
+
+	badVersion := append([]byte{}, octo...)
+	badVersion[len(stateMagic)] = 0xFF
+
+	badStack := append([]byte{}, octo...)
+	badStack[len(stateMagic)+2+4+MemorySize+16+2+2+2] = 17 // SP follows V, I, DT, ST and PC
+
+	badKey := append([]byte{}, octo...)
+	badKey[len(stateMagic)+2+4+MemorySize+16+2+2+2+1+32+16+1] = 16 // The pressed key follows the stack, the keypad and KeyPressed
+
+	// The phase ends the machine state, before the 8 bytes of the random state and their size
+	withPhase := func(phase float64) []byte {
+		data := append([]byte{}, octo...)
+		binary.LittleEndian.PutUint64(data[len(data)-8-2-8:], math.Float64bits(phase))
+		return data
+	}
+
+	tests := []struct {
+		name     string
+		platform Platform
+		data     []byte
+		wantErr  bool
+	}{
+		{name: "valid", platform: PlatformXOCHIP, data: octo},
+		{name: "empty", platform: PlatformOcto, data: nil, wantErr: true},
+		{name: "bad magic", platform: PlatformOcto, data: append([]byte("CH8X"), octo[len(stateMagic):]...), wantErr: true},
+		{name: "bad version", platform: PlatformOcto, data: badVersion, wantErr: true},
+		{name: "memory size mismatch", platform: PlatformVIP, data: octo, wantErr: true},
+		{name: "truncated", platform: PlatformOcto, data: octo[:len(octo)-1], wantErr: true},
+		{name: "trailing data", platform: PlatformOcto, data: append(append([]byte{}, octo...), 0), wantErr: true},
+		{name: "stack pointer out of range", platform: PlatformOcto, data: badStack, wantErr: true},
+		{name: "pressed key out of range", platform: PlatformOcto, data: badKey, wantErr: true},
+		{name: "phase in range", platform: PlatformOcto, data: withPhase(127.5)},
+		{name: "phase not a number", platform: PlatformOcto, data: withPhase(math.NaN()), wantErr: true},
+		{name: "phase infinite", platform: PlatformOcto, data: withPhase(math.Inf(1)), wantErr: true},
+		{name: "phase negative", platform: PlatformOcto, data: withPhase(-1), wantErr: true},
+		{name: "phase out of the pattern", platform: PlatformOcto, data: withPhase(128), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(tt.platform)
+			c.v[0] = 0xFF
+			if err := c.UnmarshalBinary(tt.data); (err != nil) != tt.wantErr {
+				t.Errorf("chip8.UnmarshalBinary() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && c.v[0] != 0xFF {
+				t.Errorf("chip8.UnmarshalBinary() modified the machine on error")
+			}
+		})
+	}
+}
+
+// pressed return the state of the keypad when only the given key is pressed.
+func pressed(key byte) [16]bool {
+	var keypad [16]bool
+	keypad[key] = true
+	return keypad
+}
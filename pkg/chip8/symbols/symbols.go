@@ -0,0 +1,125 @@
+// Package symbols reads and writes the symbol files mapping the addresses of a CHIP-8 program to its source.
+//
+// A symbol file is a JSON document listing the labels of the program and the source line of each instruction:
+//
+//	{
+//	  "version": 1,
+//	  "labels": {"main": 512, "draw": 530},
+//	  "lines": [
+//	    {"address": 512, "file": "game.8o", "line": 3},
+//	    {"address": 514, "file": "game.8o", "line": 4}
+//	  ]
+//	}
+//
+// The addresses are in decimal, as JSON has no hexadecimal numbers.
+// The source files are relative to the directory of the symbol file.
+package symbols
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Version is the version of the format of the symbol files written by this package.
+const Version = 1
+
+// Line is the source line of the instruction at an address.
+type Line struct {
+	Address uint16 `json:"address"`
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+}
+
+// Table maps the addresses of a program to its labels and source lines.
+type Table struct {
+	Version int               `json:"version"`
+	Labels  map[string]uint16 `json:"labels"`
+	Lines   []Line            `json:"lines"`
+}
+
+// New return an empty table.
+func New() *Table {
+	return &Table{Version: Version, Labels: make(map[string]uint16)}
+}
+
+// Read decodes a symbol file.
+func Read(r io.Reader) (*Table, error) {
+	t := New()
+	if err := json.NewDecoder(r).Decode(t); err != nil {
+		return nil, fmt.Errorf("invalid symbol file: %v", err)
+	}
+	if t.Version != Version {
+		return nil, fmt.Errorf("symbol file version not supported: %d", t.Version)
+	}
+	if t.Labels == nil {
+		t.Labels = make(map[string]uint16)
+	}
+	sort.SliceStable(t.Lines, func(i, j int) bool { return t.Lines[i].Address < t.Lines[j].Address })
+	return t, nil
+}
+
+// Load reads a symbol file, the source files are made relative to the working directory.
+func Load(path string) (*Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	t, err := Read(f)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Dir(path)
+	for i, l := range t.Lines {
+		if !filepath.IsAbs(l.File) {
+			t.Lines[i].File = filepath.Join(dir, l.File)
+		}
+	}
+	return t, nil
+}
+
+// Write encodes the table as a symbol file.
+func (t *Table) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(t)
+}
+
+// LineOf return the source line of the instruction at addr.
+func (t *Table) LineOf(addr uint16) (Line, bool) {
+	i := sort.Search(len(t.Lines), func(i int) bool { return t.Lines[i].Address >= addr })
+	if i < len(t.Lines) && t.Lines[i].Address == addr {
+		return t.Lines[i], true
+	}
+	return Line{}, false
+}
+
+// AddressesOf return the addresses of the instructions of a source line.
+// The files are compared after cleaning their paths.
+func (t *Table) AddressesOf(file string, line int) []uint16 {
+	file = filepath.Clean(file)
+	var addrs []uint16
+	for _, l := range t.Lines {
+		if l.Line == line && filepath.Clean(l.File) == file {
+			addrs = append(addrs, l.Address)
+		}
+	}
+	return addrs
+}
+
+// LabelOf return the label at addr, the first in alphabetical order if there are several.
+func (t *Table) LabelOf(addr uint16) (string, bool) {
+	var label string
+	for name, a := range t.Labels {
+		if a == addr && (label == "" || name < label) {
+			label = name
+		}
+	}
+	return label, label != ""
+}
@@ -0,0 +1,107 @@
+package symbols
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const example = `{
+  "version": 1,
+  "labels": {"main": 512, "loop": 514, "again": 514},
+  "lines": [
+    {"address": 514, "file": "game.8o", "line": 4},
+    {"address": 512, "file": "game.8o", "line": 3},
+    {"address": 516, "file": "game.8o", "line": 4}
+  ]
+}`
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{"valid", example, false},
+		{"empty", `{"version": 1}`, false},
+		{"version", `{"version": 2}`, true},
+		{"not json", `main = 512`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(strings.NewReader(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Read() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTable_LineOf(t *testing.T) {
+	table, err := Read(strings.NewReader(example))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+
+	tests := []struct {
+		addr   uint16
+		want   Line
+		wantOK bool
+	}{
+		{512, Line{512, "game.8o", 3}, true},
+		{516, Line{516, "game.8o", 4}, true},
+		{513, Line{}, false},
+		{600, Line{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := table.LineOf(tt.addr)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("Table.LineOf(%v) = %v, %v, want %v, %v", tt.addr, got, ok, tt.want, tt.wantOK)
+		}
+	}
+
+	if got, want := table.AddressesOf("./game.8o", 4), []uint16{514, 516}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Table.AddressesOf() = %v, want %v", got, want)
+	}
+	if got, ok := table.LabelOf(514); got != "again" || !ok {
+		t.Errorf("Table.LabelOf() = %v, %v, want again, true", got, ok)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "symbols")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "game.sym")
+	if err := ioutil.WriteFile(path, []byte(example), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	table, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := table.AddressesOf(filepath.Join(dir, "game.8o"), 3); !reflect.DeepEqual(got, []uint16{512}) {
+		t.Errorf("Table.AddressesOf() = %v, want the source relative to the symbol file", got)
+	}
+
+	// Writing and reading back gives the same table
+	var buf bytes.Buffer
+	if err := table.Write(&buf); err != nil {
+		t.Fatalf("Table.Write() error = %v", err)
+	}
+	got, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, table) {
+		t.Errorf("Read(Table.Write()) = %v, want %v", got, table)
+	}
+}
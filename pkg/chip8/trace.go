@@ -0,0 +1,67 @@
+package chip8
+
+import (
+	"fmt"
+	"io"
+)
+
+// Tracer writes a line for every instruction executed, with the state of the machine before its execution, and a
+// marker at the start of every frame. The columns are aligned so that the traces of two runs can be compared with diff:
+//
+//	-- frame 1 --
+//	0200 6005 LD V0, 0x05        V=00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 I=0000 SP=0 DT=00 ST=00
+type Tracer struct {
+	w io.Writer
+	// The addresses of the instructions traced, inclusive.
+	start, end uint16
+	frame      int
+	err        error
+}
+
+// NewTracer return a tracer writing to w every instruction executed.
+func NewTracer(w io.Writer) *Tracer {
+	return &Tracer{w: w, start: 0, end: 0xFFFF}
+}
+
+// SetRange restricts the trace to the instructions whose address is between start and end, inclusive.
+// The frame markers are still written.
+func (t *Tracer) SetRange(start, end uint16) {
+	t.start, t.end = start, end
+}
+
+// Err return the first error encountered while writing, the tracer stops writing after it.
+func (t *Tracer) Err() error {
+	return t.err
+}
+
+// startFrame writes the marker of a new frame.
+func (t *Tracer) startFrame() {
+	t.frame++
+	t.printf("-- frame %d --\n", t.frame)
+}
+
+// instruction writes the instruction at the program counter and the state of the machine.
+func (t *Tracer) instruction(c *Chip8) {
+	if c.pc < t.start || c.pc > t.end || int(c.pc)+1 >= len(c.memory) {
+		return
+	}
+
+	op := uint16(c.memory[c.pc])<<8 | uint16(c.memory[c.pc+1])
+	mnemonic, _, err := c.Disassemble(c.pc)
+	if err != nil {
+		mnemonic = "?"
+	}
+	t.printf("%04X %04X %-18s V=% X I=%04X SP=%X DT=%02X ST=%02X\n", c.pc, op, mnemonic, c.v[:], c.i, c.sp, c.dt, c.st)
+}
+
+func (t *Tracer) printf(format string, args ...interface{}) {
+	if t.err != nil {
+		return
+	}
+	_, t.err = fmt.Fprintf(t.w, format, args...)
+}
+
+// SetTracer traces the execution with t, or stops tracing if t is nil.
+func (c *Chip8) SetTracer(t *Tracer) {
+	c.tracer = t
+}
@@ -0,0 +1,48 @@
+package chip8
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTracer(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end uint16
+		want       string
+	}{
+		{"all", 0, 0xFFFF, "-- frame 1 --\n" +
+			"0200 6005 LD V0, 0x05        V=00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 I=0000 SP=0 DT=00 ST=00\n" +
+			"0202 A300 LD I, 0x300        V=05 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 I=0000 SP=0 DT=00 ST=00\n" +
+			"0204 1204 JP 0x204           V=05 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 I=0300 SP=0 DT=00 ST=00\n" +
+			"-- frame 2 --\n" +
+			"0204 1204 JP 0x204           V=05 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 I=0300 SP=0 DT=00 ST=00\n"},
+		{"range", 0x202, 0x203, "-- frame 1 --\n" +
+			"0202 A300 LD I, 0x300        V=05 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 I=0000 SP=0 DT=00 ST=00\n" +
+			"-- frame 2 --\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(PlatformOcto)
+			c.SetCyclesPerFrame(3)
+			c.LoadGame([]byte{0x60, 0x05, 0xA3, 0x00, 0x12, 0x04})
+
+			var buf bytes.Buffer
+			tracer := NewTracer(&buf)
+			tracer.SetRange(tt.start, tt.end)
+			c.SetTracer(tracer)
+
+			if _, _, err := c.GetNextFrame([16]bool{}); err != nil {
+				t.Fatalf("chip8.GetNextFrame() error = %v", err)
+			}
+			c.SetCyclesPerFrame(1)
+			if _, _, err := c.GetNextFrame([16]bool{}); err != nil {
+				t.Fatalf("chip8.GetNextFrame() error = %v", err)
+			}
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("trace =\n%v\nwant\n%v", got, tt.want)
+			}
+		})
+	}
+}